@@ -16,6 +16,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	exp "github.com/LazarenkoA/prometheus_1C_exporter/explorers"
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/tj"
 	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
 	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
 	"github.com/judwhite/go-svc"
@@ -31,6 +32,10 @@ type app struct {
 	cancel      context.CancelFunc
 	osRegistry  *prometheus.Registry
 	racRegistry *prometheus.Registry
+	scheduler   *exp.Scheduler
+	infobases   *exp.InfobaseRegistry
+	policy      *exp.SessionPolicy
+	logcfg      *tj.LogCfg
 }
 
 func (a *app) Init(_ svc.Environment) (err error) {
@@ -39,35 +44,108 @@ func (a *app) Init(_ svc.Environment) (err error) {
 
 	a.osRegistry = prometheus.NewRegistry()
 	a.racRegistry = prometheus.NewRegistry()
+	a.logcfg = tj.NewLogCfg()
+
+	// метрики о работе самого экспортера (exporter_*) отдаются только на /metrics
+	if err := exp.RegisterSelfMetrics(prometheus.DefaultRegisterer); err != nil {
+		return err
+	}
+	exp.SetRACConcurrency(a.settings.RACConcurrency)
+	exp.SetRASBreaker(a.settings.RASBreaker)
+	exp.SetRACCacheTTL(a.settings.RACCacheTTL)
+
+	if a.settings.RAC_Transport() == settings.TransportNative && a.settings.RAC_Path() == "" {
+		logger.DefaultLogger.Warnf("Для RAC.Transport: native не задан RAC.Path: команды, кроме %s, выполняются утилитой rac и будут завершаться ошибкой "+
+			"(экспортеры locks, server, counters, пользовательские экспортеры, завершение сеансов)", strings.Join(exp.NativeCommands(), ", "))
+	}
+
+	lic := new(exp.ExporterClientLic).Construct(a.settings)              // Клиентские лицензии
+	perf := new(exp.ExporterAvailablePerformance).Construct(a.settings)  // Доступная производительность
+	sJob := new(exp.ExporterCheckSheduleJob).Construct(a.settings)       // Проверка галки "блокировка регламентных заданий"
+	ses := new(exp.ExporterSessions).Construct(a.settings)               // Сеансы
+	conn := new(exp.ExporterConnects).Construct(a.settings)              // Соединения
+	currentMem := new(exp.ExporterSessionsData).Construct(a.settings)    // Текущая память сеанса
+	locks := new(exp.ExporterLocks).Construct(a.settings)                // Управляемые блокировки
+	rphost := new(exp.ExporterRphost).Construct(a.settings)              // Рабочие процессы кластера
+	server := new(exp.ExporterServer).Construct(a.settings)              // Настройки рабочих серверов
+	ibState := new(exp.ExporterInfobaseState).Construct(a.settings)      // Состояние информационных баз
+	counters := new(exp.ExporterCounters).Construct(a.settings)          // Счетчики потребления ресурсов и ограничения
+	analytics := new(exp.ExporterSessionAnalytics).Construct(a.settings) // Возраст, спящие, заблокированные и самые ресурсоемкие сеансы
+	cpu := new(exp.CPU).Construct(a.settings)                            // CPU
+	proc := new(exp.Processes).Construct(a.settings)                     // Данные CPU/память в разрезе процессов
+	disk := new(exp.ExporterDisk).Construct(a.settings)                  // Диск
+	techLog := new(exp.ExporterTJ).Construct(a.settings)                 // Технологический журнал
+	eventLog := new(exp.ExporterEventLog).Construct(a.settings)          // Журнал регистрации
+	licFiles := new(exp.ExporterLicFiles).Construct(a.settings)          // Файлы программных лицензий
+	srvInfo := new(exp.ExporterSrvInfo).Construct(a.settings)            // Состав кластеров по файлам реестра srvinfo
+
+	a.metric.AppendExporter(proc, cpu, disk, techLog, eventLog, licFiles, srvInfo, currentMem, lic, perf, sJob, ses, conn, locks, rphost, server, ibState, counters, analytics)
+	a.appendCustomExporters()
+
+	// список баз общий для всех экспортеров и не зависит от того, какие из них включены
+	a.infobases = exp.NewInfobaseRegistry(a.settings)
+	a.infobases.SetFallback(srvInfo) // если RAS недоступен, имена баз берутся из файлов реестра кластера
+	a.metric.SetInfobases(a.infobases)
+	prometheus.MustRegister(a.infobases)
+	a.racRegistry.MustRegister(a.infobases)
+
+	// правила завершения сеансов проверяются, только если заданы в настройках (в том числе после перечитывания настроек)
+	a.policy = exp.NewSessionPolicy(a.settings)
+	a.policy.SetInfobases(a.infobases)
+	prometheus.MustRegister(a.policy)
+	a.racRegistry.MustRegister(a.policy)
+
+	// расписание нужно задать до регистрации экспортеров
+	a.scheduler = exp.NewScheduler(a.settings)
+	a.scheduler.Add(a.metric.Exporters...)
 
-	lic := new(exp.ExporterClientLic).Construct(a.settings)             // Клиентские лицензии
-	perf := new(exp.ExporterAvailablePerformance).Construct(a.settings) // Доступная производительность
-	sJob := new(exp.ExporterCheckSheduleJob).Construct(a.settings)      // Проверка галки "блокировка регламентных заданий"
-	ses := new(exp.ExporterSessions).Construct(a.settings)              // Сеансы
-	conn := new(exp.ExporterConnects).Construct(a.settings)             // Соединения
-	currentMem := new(exp.ExporterSessionsData).Construct(a.settings)   // Текущая память сеанса
-	cpu := new(exp.CPU).Construct(a.settings)                           // CPU
-	proc := new(exp.Processes).Construct(a.settings)                    // Данные CPU/память в разрезе процессов
-	disk := new(exp.ExporterDisk).Construct(a.settings)                 // Диск
-
-	a.metric.AppendExporter(proc, cpu, disk, currentMem, lic, perf, sJob, ses, conn)
 	a.initHTTP()
 
 	return nil
 }
 
+// appendCustomExporters экспортеры, описанные в настройках командой rac. Имена встроенных экспортеров переопределить нельзя
+func (a *app) appendCustomExporters() {
+	for _, name := range a.settings.GetCustomExporters() {
+		if a.metric.Exists(name) {
+			logger.DefaultLogger.Errorf("Экспортер %q уже существует, пользовательский экспортер с таким именем пропущен", name)
+			continue
+		}
+
+		custom, err := new(exp.ExporterCustom).Construct(a.settings, name)
+		if err != nil {
+			logger.DefaultLogger.Error(err)
+			continue
+		}
+
+		a.metric.AppendExporter(custom)
+	}
+}
+
 func (a *app) Start() error {
 	logger.DefaultLogger.Info("Запущен сбор метрик: ", strings.Join(a.metric.Metrics, ","))
 	fmt.Println("port :", a.port)
 
-	if a.metric.Contains("shedule_job") && (a.settings.DBCredentials == nil || a.settings.DBCredentials.URL == "") {
-		return errors.New("для метрики \"shedule_job\" обязательно должен быть заполнен параметр DBCredentials")
+	// информация по базе (rac infobase info) запрашивается с учетными данными базы
+	for _, name := range []string{"shedule_job", "infobase_state"} {
+		if a.metric.Contains(name) && !a.settings.HasDBCredentials() {
+			return fmt.Errorf("для метрики %q обязательно должен быть заполнен параметр DBCredentials или Credentials", name)
+		}
+	}
+
+	// технологический журнал включается на время работы экспортера
+	if err := a.logcfg.Apply(a.settings.TechLog); err != nil {
+		logger.DefaultLogger.Error(err)
 	}
 
 	go a.settings.GetDBCredentials(a.ctx, exp.CForce)
+	go a.infobases.Start(a.ctx)
+	go a.policy.Start(a.ctx)
 	go a.gracefulShutdown()
 
 	a.register()
+	a.scheduler.Start(a.ctx)
+
 	go func() {
 		if err := a.httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.DefaultLogger.Error(err)
@@ -82,7 +160,13 @@ func (a *app) Stop() error {
 
 	defer a.cancel()
 
-	ctx, _ := context.WithTimeout(a.ctx, time.Second*10)
+	if err := a.logcfg.Rollback(); err != nil {
+		logger.DefaultLogger.Error(err)
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, time.Second*10)
+	defer cancel()
+
 	return a.httpSrv.Shutdown(ctx)
 }
 
@@ -110,6 +194,13 @@ func (a *app) reloadWatcher() {
 	*a.settings = *news
 
 	logger.InitLogger(a.settings.LogDir, a.settings.LogLevel)
+	exp.SetRACConcurrency(a.settings.RACConcurrency)
+	exp.SetRASBreaker(a.settings.RASBreaker)
+	exp.SetRACCacheTTL(a.settings.RACCacheTTL)
+
+	if err := a.logcfg.Apply(a.settings.TechLog); err != nil {
+		logger.DefaultLogger.Error(err)
+	}
 
 	a.metric.FillMetrics(a.settings)
 	a.unregisterAll()
@@ -123,8 +214,10 @@ func (a *app) initHTTP() {
 	siteMux.Handle("/metrics", promhttp.Handler())
 	siteMux.Handle("/metrics_os", promhttp.HandlerFor(a.osRegistry, promhttp.HandlerOpts{}))
 	siteMux.Handle("/metrics_rac", promhttp.HandlerFor(a.racRegistry, promhttp.HandlerOpts{}))
+	siteMux.Handle("/probe", exp.Probe(a.settings))
 	siteMux.Handle("/Continue", exp.Continue(a.metric))
 	siteMux.Handle("/Pause", exp.Pause(a.metric))
+	siteMux.Handle("/status", exp.Status())
 
 	siteMux.HandleFunc("/debug/pprof/", pprof.Index)
 	siteMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"regexp"
 	"runtime/trace"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +31,8 @@ import (
 var (
 	// Канал для передачи флага принудительного обновления данных из REST
 	CForce chan struct{}
+
+	errTimeout = errors.New("command execution was interrupted by timeout")
 )
 
 func init() {
@@ -46,25 +49,59 @@ type cmdRunner struct {
 
 // базовый класс для всех метрик
 type BaseExporter struct {
-	mx       sync.RWMutex
-	summary  IPrometheusMetric //*prometheus.SummaryVec
-	gauge    *prometheus.GaugeVec
-	settings *settings.Settings
-	ctx      context.Context
-	cancel   context.CancelFunc
-	isLocked atomic.Bool
-	logger   *zap.SugaredLogger
-	host     string
-	runner   IRunner
+	name      string
+	mx        sync.RWMutex
+	summary   IPrometheusMetric //*prometheus.SummaryVec
+	histogram IPrometheusMetric //*prometheus.HistogramVec
+	gauge     *prometheus.GaugeVec
+	// прочие метрики экспортера (например, накопительные счетчики)
+	collectors []prometheus.Collector
+	settings   *settings.Settings
+	ctx        context.Context
+	cancel     context.CancelFunc
+	isLocked   atomic.Bool
+	logger     *zap.SugaredLogger
+	host       string
+	runner     IRunner
+	// сбор без расписания выполняется на каждый Collect, а экспортер может быть зарегистрирован в нескольких реестрах
+	// (/metrics и /metrics_rac, /metrics_os), поэтому сборы экспортера выполняются по очереди
+	collectMx sync.Mutex
+
+	// фоновый сбор (см. Scheduler)
+	interval    time.Duration
+	snapshotMx  sync.RWMutex
+	snapshot    []prometheus.Metric
+	lastSuccess atomic.Int64
+	staleDesc   *prometheus.Desc
 }
 
 // базовый класс для всех метрик собираемых через RAC
 type BaseRACExporter struct {
 	BaseExporter
 
-	clusterID string
+	clusters map[string]*clusterList // ключ - settings.Cluster.String()
+}
+
+// clusterList кластеры одного RAS и время их получения
+type clusterList struct {
+	items   []*clusterInfo
+	updated time.Time
+}
+
+// clustersTTL период обновления списка кластеров RAS, кластер может быть добавлен или удален без перезапуска экспортера
+var clustersTTL = time.Hour
+
+// clusterInfo кластер найденный на RAS
+type clusterInfo struct {
+	*settings.Cluster
+
+	id   string
+	name string
 }
 
+// метки, которыми помечаются все метрики собираемые через RAC
+var clusterLabels = []string{"ras_host", "cluster_id", "cluster_name"}
+
 type Metrics struct {
 	Exporters []model.IExporter
 	Metrics   []string // метрики
@@ -75,6 +112,7 @@ func newBase(name string) BaseExporter {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return BaseExporter{
+		name:   name,
 		host:   host,
 		logger: logger.DefaultLogger.Named(name),
 		ctx:    ctx,
@@ -84,7 +122,10 @@ func newBase(name string) BaseExporter {
 }
 
 func (r *cmdRunner) Run(cmd *exec.Cmd) (string, error) {
-	timeout := time.Second * 15
+	return r.RunTimeout(cmd, defaultRACTimeout)
+}
+
+func (r *cmdRunner) RunTimeout(cmd *exec.Cmd, timeout time.Duration) (string, error) {
 	cmd.Stdout = new(bytes.Buffer)
 	cmd.Stderr = new(bytes.Buffer)
 	errch := make(chan error, 1)
@@ -103,7 +144,7 @@ func (r *cmdRunner) Run(cmd *exec.Cmd) (string, error) {
 	case <-time.After(timeout): // timeout
 		// завершаем процесс
 		cmd.Process.Kill()
-		return "", errors.New("command execution was interrupted by timeout")
+		return "", errTimeout
 	case err := <-errch:
 		if err != nil {
 			stderr := cmd.Stderr.(*bytes.Buffer).String()
@@ -126,7 +167,44 @@ func (exp *BaseExporter) run(cmd *exec.Cmd) (string, error) {
 		With("параметры", cmd.Args).
 		Debug("выполнение команды")
 
-	return exp.runner.Run(cmd)
+	args := parseRACArgs(cmd.Args[1:])
+
+	ctx := exp.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	release, err := racLimiter.acquire(ctx, exp.name)
+	if err != nil {
+		return "", errors.Wrapf(err, "команда %q не дождалась очереди", args.command)
+	}
+	defer release()
+
+	// к недоступному RAS не обращаемся, что бы не ждать таймаут на каждом запросе
+	if err := rasBreakers.allow(args.addr); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	var result string
+	if r, ok := exp.runner.(timeoutRunner); ok {
+		result, err = r.RunTimeout(cmd, exp.racTimeout())
+	} else {
+		result, err = exp.runner.Run(cmd)
+	}
+	observeRAC(args.command, time.Since(start), err)
+	rasBreakers.done(args.addr, err)
+
+	return result, err
+}
+
+// racTimeout таймаут выполнения команд rac экспортера из свойства Timeout ("30s" или число секунд)
+func (exp *BaseExporter) racTimeout() time.Duration {
+	if exp.settings == nil {
+		return defaultRACTimeout
+	}
+
+	return exp.settings.GetPropertyDuration(exp.name, "Timeout", defaultRACTimeout)
 }
 
 func (exp *BaseExporter) Stop() {
@@ -140,12 +218,8 @@ func (exp *BaseExporter) Pause(expName string) {
 	if exp.isLocked.CompareAndSwap(false, true) {
 		l.Info("Pause. Блокировка установлена")
 
-		if exp.summary != nil {
-			exp.summary.Reset()
-		}
-		if exp.gauge != nil {
-			exp.gauge.Reset()
-		}
+		exp.resetMetrics()
+		exp.clearSnapshot()
 	} else {
 		l.Debug("Pause. Уже заблокировано")
 	}
@@ -165,9 +239,120 @@ func (exp *BaseExporter) Describe(ch chan<- *prometheus.Desc) {
 	if exp.summary != nil {
 		exp.summary.Describe(ch)
 	}
+	if exp.histogram != nil {
+		exp.histogram.Describe(ch)
+	}
 	if exp.gauge != nil {
 		exp.gauge.Describe(ch)
 	}
+	for _, c := range exp.collectors {
+		c.Describe(ch)
+	}
+	if exp.isScheduled() {
+		ch <- exp.staleDesc
+	}
+}
+
+func (exp *BaseExporter) base() *BaseExporter {
+	return exp
+}
+
+// initMetrics создает векторы метрик под виды из настроек экспортера (или под переданные kinds).
+// Summary имеет имя метрики экспортера, гистограмма (классическая и/или нативная) - с суффиксом _histogram, gauge - _gauge
+func (exp *BaseExporter) initMetrics(s *settings.Settings, expName, help string, labels []string, kinds ...settings.TypeMetricKind) {
+	if len(kinds) == 0 {
+		kinds = s.GetMetricKinds(expName)
+	}
+
+	name := s.GetMetricNamePrefix() + expName
+	if slices.Contains(kinds, settings.KindSummary) {
+		exp.summary = prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Name:       name,
+				Help:       help,
+				Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+			},
+			labels,
+		)
+	}
+
+	classic, native := slices.Contains(kinds, settings.KindHistogram), slices.Contains(kinds, settings.KindNativeHistogram)
+	if classic || native {
+		opts := prometheus.HistogramOpts{
+			Name: name + "_histogram",
+			Help: help + " (Histogram)",
+		}
+		if classic {
+			// если бакеты не заданы, то prometheus использует DefBuckets
+			opts.Buckets = s.GetHistogramBuckets(expName)
+			if len(opts.Buckets) == 0 {
+				opts.Buckets = prometheus.DefBuckets
+			}
+		}
+		if native {
+			// при пустых Buckets будет только нативная гистограмма
+			opts.NativeHistogramBucketFactor = 1.1
+			opts.NativeHistogramMaxBucketNumber = 160
+			opts.NativeHistogramMinResetDuration = time.Hour
+		}
+
+		exp.histogram = prometheus.NewHistogramVec(opts, labels)
+	}
+
+	if slices.Contains(kinds, settings.KindGauge) {
+		exp.gauge = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: name + "_gauge",
+				Help: help + " (Gauge)",
+			},
+			labels,
+		)
+	}
+}
+
+// observe записывает значение во все созданные векторы метрик
+func (exp *BaseExporter) observe(value float64, lvs ...string) {
+	if exp.summary != nil {
+		exp.summary.WithLabelValues(lvs...).Observe(value)
+	}
+	if exp.histogram != nil {
+		exp.histogram.WithLabelValues(lvs...).Observe(value)
+	}
+	if exp.gauge != nil {
+		exp.gauge.WithLabelValues(lvs...).Set(value)
+	}
+}
+
+func (exp *BaseExporter) resetMetrics() {
+	if exp.summary != nil {
+		exp.summary.Reset()
+	}
+	if exp.histogram != nil {
+		exp.histogram.Reset()
+	}
+	if exp.gauge != nil {
+		exp.gauge.Reset()
+	}
+	for _, c := range exp.collectors {
+		if r, ok := c.(interface{ Reset() }); ok {
+			r.Reset()
+		}
+	}
+}
+
+func (exp *BaseExporter) collectMetrics(ch chan<- prometheus.Metric) {
+	if exp.summary != nil {
+		exp.summary.Collect(ch)
+	}
+	if exp.histogram != nil {
+		exp.histogram.Collect(ch)
+	}
+	if exp.gauge != nil {
+		exp.gauge.Collect(ch)
+	}
+	for _, c := range exp.collectors {
+		c.Collect(ch)
+	}
 }
 
 func (exp *BaseRACExporter) formatMultiResult(strIn string, outData *[]map[string]string) {
@@ -206,16 +391,32 @@ func (exp *BaseRACExporter) formatResult(strIn string) map[string]string {
 	return result
 }
 
-func (exp *BaseRACExporter) appendLogPass(param []string) []string {
-	if login := exp.settings.RAC_Login(); login != "" {
-		param = append(param, fmt.Sprintf("--cluster-user=%v", login))
-		if pwd := exp.settings.RAC_Pass(); pwd != "" {
-			param = append(param, fmt.Sprintf("--cluster-pwd=%v", pwd))
+func (exp *BaseRACExporter) appendLogPass(cl *clusterInfo, param []string) []string {
+	if cl.Login != "" {
+		param = append(param, fmt.Sprintf("--cluster-user=%v", cl.Login))
+		if cl.Pass != "" {
+			param = append(param, fmt.Sprintf("--cluster-pwd=%v", cl.Pass))
 		}
 	}
 	return param
 }
 
+// racCommand команда rac для кластера: адрес RAS, параметры, авторизация в кластере и идентификатор кластера
+func (exp *BaseRACExporter) racCommand(cl *clusterInfo, args ...string) *exec.Cmd {
+	var param []string
+
+	// если заполнен хост, то порт может быть не заполнен, если не заполнен хост, а заполнен порт, то так не будет работать, поэтому условие с портом внутри
+	if cl.Host != "" {
+		param = append(param, strings.Join(appendParam([]string{cl.Host}, cl.Port), ":"))
+	}
+
+	param = append(param, args...)
+	param = exp.appendLogPass(cl, param)
+	param = append(param, fmt.Sprintf("--cluster=%v", cl.id))
+
+	return exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)
+}
+
 func normalizeEncoding(str string) string {
 	encoding := cpd.CodepageAutoDetect([]byte(str))
 
@@ -229,42 +430,82 @@ func normalizeEncoding(str string) string {
 	return str
 }
 
-func (exp *BaseRACExporter) GetClusterID() string {
-	update := func() {
-		defer exp.logger.Debug("получен идентификатор кластера ", exp.clusterID)
-
-		var param []string
-		if exp.settings.RAC_Host() != "" {
-			param = append(param, strings.Join(appendParam([]string{exp.settings.RAC_Host()}, exp.settings.RAC_Port()), ":"))
-		}
+// GetClusters возвращает кластеры всех RAS из настроек. Список кластеров обновляется раз в clustersTTL,
+// если RAS был недоступен, то попытка получить по нему кластеры будет повторена при следующем вызове,
+// а до тех пор используется ранее полученный список
+func (exp *BaseRACExporter) GetClusters() (result []*clusterInfo) {
+	exp.mx.Lock()
+	defer exp.mx.Unlock()
 
-		param = append(param, "cluster")
-		param = append(param, "list")
+	if exp.clusters == nil {
+		exp.clusters = map[string]*clusterList{}
+	}
 
-		cmdCommand := exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)
-		cluster := make(map[string]string)
-		if result, err := exp.runner.Run(cmdCommand); err != nil {
-			exp.logger.Error(fmt.Errorf("Произошла ошибка выполнения при попытке получить идентификатор кластера: \n\t%v", err.Error())) // Если идентификатор кластера не получен, то нет смысла продолжать работу приложения
-		} else {
-			cluster = exp.formatResult(result)
+	for _, c := range exp.settings.GetClusters() {
+		if cached, ok := exp.clusters[c.String()]; !ok || time.Since(cached.updated) >= clustersTTL {
+			if list, err := exp.getClusters(c); errors.Is(err, errBreakerOpen) {
+				exp.logger.Debug(err)
+			} else if err != nil {
+				exp.logger.Error(errors.Wrapf(err, "Произошла ошибка выполнения при попытке получить список кластеров RAS %s", c.RASHostPort()))
+			} else {
+				exp.logger.Debugf("получено %d кластеров RAS %s", len(list), c.RASHostPort())
+				exp.clusters[c.String()] = &clusterList{items: list, updated: time.Now()}
+			}
 		}
 
-		if id, ok := cluster["cluster"]; !ok {
-			exp.logger.Error(errors.New("Не удалось получить идентификатор кластера"))
-		} else {
-			exp.clusterID = id
+		if cached, ok := exp.clusters[c.String()]; ok {
+			result = append(result, cached.items...)
 		}
 	}
 
-	exp.mx.Lock()
+	return result
+}
+
+func (exp *BaseRACExporter) getClusters(c *settings.Cluster) ([]*clusterInfo, error) {
+	var param []string
+	if c.Host != "" {
+		param = append(param, strings.Join(appendParam([]string{c.Host}, c.Port), ":"))
+	}
+
+	param = append(param, "cluster")
+	param = append(param, "list")
+
+	result, err := exp.run(exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...))
+	if err != nil {
+		return nil, err
+	}
 
-	if exp.clusterID == "" {
-		update() // обновляем
+	var clusters []map[string]string
+	exp.formatMultiResult(result, &clusters)
+
+	var list []*clusterInfo
+	for _, item := range clusters {
+		cl := &clusterInfo{Cluster: c, id: item["cluster"], name: strings.Trim(item["name"], "\"")}
+		if cl.id == "" {
+			continue
+		}
+		if c.Name != "" && !strings.EqualFold(c.Name, cl.name) && c.Name != cl.id {
+			continue
+		}
+
+		list = append(list, cl)
 	}
 
-	exp.mx.Unlock()
+	if len(list) == 0 {
+		return nil, errors.New("Не удалось получить идентификатор кластера")
+	}
 
-	return exp.clusterID
+	return list, nil
+}
+
+// with дополняет значения меток значениями меток кластера (порядок как в clusterLabels)
+func (cl *clusterInfo) with(lvs ...string) []string {
+	return append(lvs, cl.RASHostPort(), cl.id, cl.name)
+}
+
+// withClusterLabels дополняет список меток метками кластера
+func withClusterLabels(labels ...string) []string {
+	return append(labels, clusterLabels...)
 }
 
 func (exp *Metrics) AppendExporter(ex ...model.IExporter) {
@@ -294,6 +535,11 @@ func (exp *Metrics) Contains(name string) bool {
 	return false
 }
 
+// Exists добавлен ли экспортер с указанным именем
+func (exp *Metrics) Exists(name string) bool {
+	return len(exp.findExporter(name)) > 0
+}
+
 func (exp *Metrics) findExporter(names ...string) (result []model.IExporter) {
 	for _, name := range names {
 		for i, _ := range exp.Exporters {
@@ -7,10 +7,17 @@ import (
 	"time"
 
 	"github.com/agiledragon/gomonkey/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
 	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
 	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
 )
 
 func Test_findExporter(t *testing.T) {
@@ -130,3 +137,97 @@ func Test_GetVal(t *testing.T) {
 	v2 := GetVal[string](tmp)
 	assert.Equal(t, "dsdsd", v2)
 }
+
+func Test_initMetrics(t *testing.T) {
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: disk
+    Property:
+      MetricKinds: [Summary, Histogram, NativeHistogram, Gauge]
+      Buckets: [1, 10, 100]
+  - Name: cpu
+    Property:
+      MetricKinds: [NativeHistogram]
+`), s))
+
+	exp := &BaseExporter{logger: logger.NopLogger.Named("test")}
+	exp.initMetrics(s, "disk", "test", []string{"host"})
+	assert.NotNil(t, exp.summary)
+	assert.NotNil(t, exp.histogram)
+	assert.NotNil(t, exp.gauge)
+
+	exp.observe(5, "host1")
+
+	ch := make(chan prometheus.Metric, 10)
+	exp.collectMetrics(ch)
+	close(ch)
+
+	var metrics []*dto.Metric
+	for m := range ch {
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+		metrics = append(metrics, metric)
+	}
+	if assert.Len(t, metrics, 3) {
+		assert.NotNil(t, metrics[0].GetSummary())
+		assert.Len(t, metrics[1].GetHistogram().GetBucket(), 3)
+		assert.NotZero(t, metrics[1].GetHistogram().GetSchema())
+		assert.Equal(t, 5., metrics[2].GetGauge().GetValue())
+	}
+
+	exp.resetMetrics()
+	ch = make(chan prometheus.Metric, 10)
+	exp.collectMetrics(ch)
+	assert.Empty(t, ch)
+
+	// только нативная гистограмма, без классических бакетов
+	exp = &BaseExporter{logger: logger.NopLogger.Named("test")}
+	exp.initMetrics(s, "cpu", "test", []string{"host"})
+	assert.Nil(t, exp.summary)
+	assert.Nil(t, exp.gauge)
+	if assert.NotNil(t, exp.histogram) {
+		exp.observe(5, "host1")
+
+		m := &dto.Metric{}
+		ch := make(chan prometheus.Metric, 1)
+		exp.histogram.Collect(ch)
+		assert.NoError(t, (<-ch).Write(m))
+		assert.Empty(t, m.GetHistogram().GetBucket())
+	}
+}
+
+func Test_GetClusters(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := new(settings.Settings)
+	run := mock_models.NewMockIRunner(c)
+	exp := &BaseRACExporter{BaseExporter: newBase("test")}
+	exp.settings = s
+	exp.runner = run
+	key := s.GetClusters()[0].String()
+
+	ids := func(list []*clusterInfo) (result []string) {
+		for _, cl := range list {
+			result = append(result, cl.id)
+		}
+		return result
+	}
+
+	run.EXPECT().Run(gomock.Any()).Return("cluster : 111\nname    : \"main\"\n", nil)
+	assert.Equal(t, []string{"111"}, ids(exp.GetClusters()))
+	assert.Equal(t, []string{"111"}, ids(exp.GetClusters())) // в пределах clustersTTL rac не вызывается
+
+	// список устарел, добавленный в RAS кластер появляется без перезапуска
+	exp.clusters[key].updated = time.Now().Add(-clustersTTL)
+	run.EXPECT().Run(gomock.Any()).Return("cluster : 111\nname    : \"main\"\n\ncluster : 222\nname    : \"new\"\n", nil)
+	assert.Equal(t, []string{"111", "222"}, ids(exp.GetClusters()))
+
+	// если обновить не удалось, используется прежний список, обновление повторяется при следующем вызове
+	exp.clusters[key].updated = time.Now().Add(-clustersTTL)
+	run.EXPECT().Run(gomock.Any()).Return("", errors.New("Недостаточно прав пользователя"))
+	assert.Equal(t, []string{"111", "222"}, ids(exp.GetClusters()))
+	run.EXPECT().Run(gomock.Any()).Return("cluster : 222\nname    : \"new\"\n", nil)
+	assert.Equal(t, []string{"222"}, ids(exp.GetClusters()))
+}
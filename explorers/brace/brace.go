@@ -0,0 +1,132 @@
+// Package brace разбор скобочного формата 1С (журнал регистрации, файлы реестра кластера srvinfo и т.д.)
+package brace
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Node элемент скобочного формата 1С: {значение,"строка",{вложенный,список}}
+type Node struct {
+	Value string
+	List  []Node
+}
+
+// Item элемент списка, пустой элемент если индекс за пределами списка
+func (n Node) Item(i int) Node {
+	if i < len(n.List) {
+		return n.List[i]
+	}
+
+	return Node{}
+}
+
+func (n Node) Int() int64 {
+	v, _ := strconv.ParseInt(n.Value, 10, 64)
+	return v
+}
+
+// ReadObjects разбирает завершенные объекты верхнего уровня начиная с начала data.
+// Возвращает разобранные объекты и позицию, до которой data прочитан (незавершенный объект в конце не учитывается)
+func ReadObjects(data []byte) ([]Node, int) {
+	var result []Node
+
+	pos := 0
+	for {
+		start := indexByte(data, pos, '{')
+		if start < 0 {
+			return result, pos
+		}
+
+		obj, end, ok := ReadList(data, start)
+		if !ok {
+			return result, pos
+		}
+
+		result = append(result, obj)
+		pos = end
+	}
+}
+
+// ReadList разбирает список начиная с открывающей скобки в позиции start
+func ReadList(data []byte, start int) (Node, int, bool) {
+	var result Node
+
+	i := start + 1
+	for {
+		i = skipSpaces(data, i)
+		if i >= len(data) {
+			return Node{}, 0, false
+		}
+
+		var item Node
+		switch data[i] {
+		case '}':
+			return result, i + 1, true
+		case '{':
+			list, end, ok := ReadList(data, i)
+			if !ok {
+				return Node{}, 0, false
+			}
+			item, i = list, end
+		case '"':
+			str, end, ok := readString(data, i)
+			if !ok {
+				return Node{}, 0, false
+			}
+			item, i = Node{Value: str}, end
+		default:
+			end := i
+			for end < len(data) && data[end] != ',' && data[end] != '}' {
+				end++
+			}
+			if end >= len(data) {
+				return Node{}, 0, false
+			}
+			item, i = Node{Value: strings.TrimSpace(string(data[i:end]))}, end
+		}
+
+		result.List = append(result.List, item)
+
+		i = skipSpaces(data, i)
+		if i < len(data) && data[i] == ',' {
+			i++
+		}
+	}
+}
+
+// readString разбирает строку в кавычках, кавычка внутри строки удваивается
+func readString(data []byte, start int) (string, int, bool) {
+	var b strings.Builder
+	for i := start + 1; i < len(data); i++ {
+		if data[i] == '"' {
+			if i+1 < len(data) && data[i+1] == '"' {
+				b.WriteByte('"')
+				i++
+				continue
+			}
+			return b.String(), i + 1, true
+		}
+		b.WriteByte(data[i])
+	}
+
+	return "", 0, false
+}
+
+func skipSpaces(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\r' || data[i] == '\n' || data[i] == '\t') {
+		i++
+	}
+
+	return i
+}
+
+func indexByte(data []byte, from int, c byte) int {
+	for i := from; i < len(data); i++ {
+		if data[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
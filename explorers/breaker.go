@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+)
+
+// состояния breaker
+const (
+	breakerClosed   = "closed"    // RAS доступен
+	breakerOpen     = "open"      // обращения к RAS приостановлены
+	breakerHalfOpen = "half-open" // пауза истекла, выполняется одна пробная команда
+)
+
+var errBreakerOpen = errors.New("RAS недоступен, обращения временно приостановлены")
+
+// фрагменты текста ошибок, по которым понятно, что к RAS не удалось подключиться (а не, например, неверный пароль базы)
+var unavailableErrors = []string{
+	"server_addr=",
+	"ошибка подключения к ras",
+	"connection refused",
+	"no route to host",
+	"ошибка соединения",
+	"не удалось установить соединение",
+	"не удается установить соединение",
+}
+
+// breakers состояние доступности RAS в разрезе адресов, общее для всех экспортеров
+type breakers struct {
+	mx     sync.Mutex
+	config settings.Breaker
+	items  map[string]*breaker
+}
+
+type breaker struct {
+	addr      string
+	state     string
+	failures  int
+	backoff   time.Duration
+	openUntil time.Time
+	lastError string
+}
+
+// BreakerStatus состояние RAS для /status
+type BreakerStatus struct {
+	RAS       string    `json:"ras"`
+	State     string    `json:"state"`
+	Failures  int       `json:"failures"`
+	OpenUntil time.Time `json:"open_until,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+var rasBreakers = &breakers{items: map[string]*breaker{}}
+
+// SetRASBreaker задает параметры приостановки обращений к недоступному RAS, nil - не используется. Накопленное состояние сбрасывается
+func SetRASBreaker(config *settings.Breaker) {
+	rasBreakers.mx.Lock()
+	defer rasBreakers.mx.Unlock()
+
+	if config == nil {
+		config = new(settings.Breaker)
+	}
+	rasBreakers.config = *config
+	clear(rasBreakers.items)
+}
+
+// allow можно ли выполнить команду на RAS. После паузы пропускается только одна пробная команда
+func (b *breakers) allow(addr string) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.config.Failures <= 0 {
+		return nil
+	}
+
+	item := b.get(addr)
+	switch item.state {
+	case breakerOpen:
+		if time.Now().Before(item.openUntil) {
+			selfMetrics.breakerRejected.WithLabelValues(addr).Inc()
+			return errors.Wrapf(errBreakerOpen, "%s, до %s", addr, item.openUntil.Format(time.TimeOnly))
+		}
+
+		item.setState(breakerHalfOpen)
+		logger.DefaultLogger.With("RAS", addr).Info("пауза истекла, проверяем доступность RAS")
+		return nil
+	case breakerHalfOpen:
+		selfMetrics.breakerRejected.WithLabelValues(addr).Inc()
+		return errors.Wrapf(errBreakerOpen, "%s, выполняется проверка доступности", addr)
+	default:
+		return nil
+	}
+}
+
+// done фиксирует результат команды
+func (b *breakers) done(addr string, err error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.config.Failures <= 0 {
+		return
+	}
+
+	item := b.get(addr)
+
+	// таймаут команды не говорит о недоступности RAS (соединение установлено, но команда выполняется долго),
+	// такие ошибки учитываются отдельно метрикой exporter_rac_timeouts_total и счетчик неудач не меняют
+	if isTimeout(err) && !isUnavailable(err) && item.state == breakerClosed {
+		return
+	}
+
+	if err == nil || !isUnavailable(err) {
+		if item.state != breakerClosed {
+			logger.DefaultLogger.With("RAS", addr).Info("RAS снова доступен")
+		}
+
+		item.failures, item.backoff = 0, 0
+		item.setState(breakerClosed)
+		return
+	}
+
+	item.failures++
+	item.lastError = err.Error()
+	if item.state != breakerHalfOpen && item.failures < b.config.Failures {
+		return
+	}
+
+	// каждая неудачная проверка удваивает паузу
+	if item.backoff = item.backoff * 2; item.backoff == 0 {
+		item.backoff = b.config.Backoff
+	}
+	if b.config.MaxBackoff > 0 && item.backoff > b.config.MaxBackoff {
+		item.backoff = b.config.MaxBackoff
+	}
+
+	item.openUntil = time.Now().Add(item.backoff)
+	item.setState(breakerOpen)
+	logger.DefaultLogger.With("RAS", addr, "failures", item.failures).
+		Warnf("RAS недоступен, обращения приостановлены на %v", item.backoff)
+}
+
+func (b *breakers) get(addr string) *breaker {
+	item, ok := b.items[addr]
+	if !ok {
+		item = &breaker{addr: addr}
+		item.setState(breakerClosed)
+		b.items[addr] = item
+	}
+
+	return item
+}
+
+func (b *breakers) status() []BreakerStatus {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	result := make([]BreakerStatus, 0, len(b.items))
+	for _, item := range b.items {
+		st := BreakerStatus{RAS: item.addr, State: item.state, Failures: item.failures, LastError: item.lastError}
+		if item.state != breakerClosed {
+			st.OpenUntil = item.openUntil
+		}
+		result = append(result, st)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].RAS < result[j].RAS })
+	return result
+}
+
+func (item *breaker) setState(state string) {
+	item.state = state
+	for _, s := range []string{breakerClosed, breakerOpen, breakerHalfOpen} {
+		selfMetrics.breakerState.WithLabelValues(item.addr, s).Set(boolValue(s == state))
+	}
+}
+
+// isUnavailable ошибка означает, что к RAS не удалось подключиться. Таймауты и обрывы уже выполняющейся команды сюда не относятся
+func isUnavailable(err error) bool {
+	var netErr *net.OpError
+	if errors.As(err, &netErr) && netErr.Op == "dial" {
+		return true
+	}
+
+	text := strings.ToLower(err.Error())
+	for _, s := range unavailableErrors {
+		if strings.Contains(text, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Status состояние доступности RAS в формате json. Если обращения хотя бы к одному RAS приостановлены, возвращается код 503
+func Status() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, fmt.Sprintf("Метод %q не поддерживается", r.Method), http.StatusInternalServerError)
+			return
+		}
+
+		status := rasBreakers.status()
+
+		code := http.StatusOK
+		for _, st := range status {
+			if st.State != breakerClosed {
+				code = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			logger.DefaultLogger.Error(errors.Wrap(err, "status encode error"))
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_breaker(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	logger.InitLogger("", 4)
+
+	SetRASBreaker(&settings.Breaker{Failures: 2, Backoff: time.Millisecond * 50, MaxBackoff: time.Millisecond * 80})
+	defer SetRASBreaker(nil)
+
+	run := mock_models.NewMockIRunner(c)
+	exp := &BaseExporter{name: "test", logger: logger.NopLogger.Named("test"), runner: run}
+	cmd := func() *exec.Cmd { return exec.Command("rac", "down:1545", "session", "list", "--cluster=123") }
+	connErr := errors.New("Ошибка соединения с сервером администрирования\nserver_addr=tcp://down:1545")
+	dialErr := errors.Wrap(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("i/o timeout")}, "ошибка подключения к RAS down:1545")
+
+	status := func() []BreakerStatus {
+		w := httptest.NewRecorder()
+		Status().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+		var result []BreakerStatus
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		for _, st := range result {
+			if st.RAS == "down:1545" {
+				assert.Equal(t, st.State != breakerClosed, w.Code == http.StatusServiceUnavailable)
+				return []BreakerStatus{st}
+			}
+		}
+		return nil
+	}
+
+	t.Run("not unavailable", func(t *testing.T) {
+		run.EXPECT().Run(gomock.Any()).Return("", errors.New("Недостаточно прав пользователя")).Times(3)
+		for i := 0; i < 3; i++ {
+			exp.run(cmd())
+		}
+		assert.Equal(t, breakerClosed, status()[0].State)
+	})
+	t.Run("timeouts", func(t *testing.T) {
+		timeouts := testutil.ToFloat64(selfMetrics.racTimeouts.WithLabelValues("session list"))
+
+		// таймаут команды и обрыв уже установленного соединения не означают недоступность RAS
+		run.EXPECT().Run(gomock.Any()).Return("", errTimeout).Times(3)
+		run.EXPECT().Run(gomock.Any()).Return("", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded})
+		for i := 0; i < 4; i++ {
+			exp.run(cmd())
+		}
+
+		st := status()[0]
+		assert.Equal(t, breakerClosed, st.State)
+		assert.Zero(t, st.Failures)
+		assert.Equal(t, 4., testutil.ToFloat64(selfMetrics.racTimeouts.WithLabelValues("session list"))-timeouts)
+	})
+	t.Run("open", func(t *testing.T) {
+		rejected := testutil.ToFloat64(selfMetrics.breakerRejected.WithLabelValues("down:1545"))
+
+		// таймаут между ошибками соединения счетчик неудач не сбрасывает
+		run.EXPECT().Run(gomock.Any()).Return("", connErr)
+		run.EXPECT().Run(gomock.Any()).Return("", errTimeout)
+		run.EXPECT().Run(gomock.Any()).Return("", connErr)
+		exp.run(cmd())
+		exp.run(cmd())
+		exp.run(cmd())
+
+		_, err := exp.run(cmd()) // runner не вызывается
+		assert.ErrorIs(t, err, errBreakerOpen)
+		assert.Equal(t, breakerOpen, status()[0].State)
+		assert.Equal(t, 1., testutil.ToFloat64(selfMetrics.breakerState.WithLabelValues("down:1545", breakerOpen)))
+		assert.Equal(t, 1., testutil.ToFloat64(selfMetrics.breakerRejected.WithLabelValues("down:1545"))-rejected)
+	})
+	t.Run("half-open failed", func(t *testing.T) {
+		time.Sleep(time.Millisecond * 60)
+
+		run.EXPECT().Run(gomock.Any()).Return("", dialErr)
+		exp.run(cmd())
+
+		st := status()[0]
+		assert.Equal(t, breakerOpen, st.State)
+		// пауза удвоилась, но ограничена MaxBackoff
+		assert.WithinDuration(t, time.Now().Add(time.Millisecond*80), st.OpenUntil, time.Millisecond*20)
+	})
+	t.Run("closed", func(t *testing.T) {
+		time.Sleep(time.Millisecond * 90)
+
+		run.EXPECT().Run(gomock.Any()).Return("", nil).Times(2)
+		_, err := exp.run(cmd())
+		assert.NoError(t, err)
+		_, err = exp.run(cmd())
+		assert.NoError(t, err)
+
+		st := status()[0]
+		assert.Equal(t, breakerClosed, st.State)
+		assert.Zero(t, st.Failures)
+	})
+}
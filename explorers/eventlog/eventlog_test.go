@@ -0,0 +1,194 @@
+package eventlog
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/brace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLGF = "\xEF\xBB\xBF1CV8LOG(ver 2.0)\n" + `d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a
+
+{1,c8e2a5a4-57a2-4d2e-8b5f-2a0b6b7c8d9e,"Иванов",1},
+{4,"_$Session$_.Start",1},
+{4,"_$Data$_.Post",2},
+{5,a1b2c3d4-0000-0000-0000-000000000000,"Документ.Реализация",1},
+{3,"1CV8C",1},`
+
+const testLGP = "\xEF\xBB\xBF1CV8LOG(ver 2.0)\n" + `d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a
+
+{20250303140001,N,
+{0,0},1,1,1,1,1,I,"",0,
+{"U"},"",1,1,0,1,0,
+{0}
+},
+{20250303140002,U,
+{2444a6a0b2b90,3},1,1,1,1,2,E,"Ошибка ""проведения""
+{вторая строка}",1,
+{"R",
+{"#",cbe8b1c1-0000-0000-0000-000000000000,17:8a2f}
+},"Реализация 1",1,1,0,1,0,
+{0}
+}`
+
+func Test_readObjects(t *testing.T) {
+	objects, pos := brace.ReadObjects([]byte(testLGP))
+	require.Len(t, objects, 2)
+	assert.Equal(t, len(testLGP), pos)
+
+	assert.Equal(t, "20250303140002", objects[1].Item(0).Value)
+	assert.Equal(t, "2444a6a0b2b90", objects[1].Item(2).Item(0).Value)
+	assert.Equal(t, "Ошибка \"проведения\"\n{вторая строка}", objects[1].Item(9).Value)
+	assert.Equal(t, "17:8a2f", objects[1].Item(11).Item(1).Item(2).Value)
+
+	// незавершенная запись не разбирается
+	objects, pos = brace.ReadObjects([]byte(testLGP[:len(testLGP)-10]))
+	require.Len(t, objects, 1)
+	assert.Less(t, pos, len(testLGP)-10)
+}
+
+func Test_SQLite(t *testing.T) {
+	// testdata/1Cv8.lgd: 400 событий, события по кругу: завершение сеанса, ошибка регламентного задания,
+	// предупреждение изменения данных, начало сеанса. Страница 1024 байта, что бы дерево было многоуровневым, у записи 200 длинный комментарий
+	log := &sqliteLog{path: filepath.Join("testdata", "1Cv8.lgd")}
+
+	last, err := log.end()
+	require.NoError(t, err)
+	assert.Equal(t, int64(400), last)
+
+	events, last, err := log.read(0)
+	require.NoError(t, err)
+	require.Len(t, events, 400)
+	assert.Equal(t, int64(400), last)
+
+	assert.Equal(t, EventSessionFinish, events[0].Event)
+	assert.Equal(t, SeverityInformation, events[0].Severity)
+	assert.Equal(t, "Иванов", events[0].User)
+	assert.Equal(t, time.Date(2025, 3, 3, 14, 0, 1, 0, time.Local), events[0].Time)
+
+	assert.Equal(t, EventJobFail, events[1].Event)
+	assert.Equal(t, SeverityError, events[1].Severity)
+	assert.Equal(t, "РегламентноеЗадание.Обмен", events[1].Metadata)
+
+	assert.Equal(t, SeverityWarning, events[2].Severity)
+	assert.Equal(t, "Документ.Реализация", events[2].Metadata)
+
+	events, last, err = log.read(390)
+	require.NoError(t, err)
+	assert.Len(t, events, 10)
+	assert.Equal(t, int64(400), last)
+
+	events, last, err = log.read(400)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Equal(t, int64(400), last)
+}
+
+func Test_SQLiteWAL(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "1Cv8.lgd"))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "1Cv8.lgd")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	// платформа держит журнал открытым, новые события до checkpoint есть только в -wal файле
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("PRAGMA journal_mode=WAL")
+	require.NoError(t, err)
+	_, err = db.Exec("PRAGMA wal_autocheckpoint=0")
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO EventLog (rowID, severity, date, userCode, eventCode, metadataCodes)
+		SELECT 401, severity, date, userCode, eventCode, metadataCodes FROM EventLog WHERE rowID = 2`)
+	require.NoError(t, err)
+
+	wal, err := os.Stat(path + "-wal")
+	require.NoError(t, err)
+	require.NotZero(t, wal.Size())
+
+	log := &sqliteLog{path: path}
+	last, err := log.end()
+	require.NoError(t, err)
+	assert.Equal(t, int64(401), last)
+
+	events, last, err := log.read(400)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, int64(401), last)
+	assert.Equal(t, EventJobFail, events[0].Event)
+	assert.Equal(t, "РегламентноеЗадание.Обмен", events[0].Metadata)
+
+	// поврежденный файл - ошибка, а не паника
+	broken := filepath.Join(t.TempDir(), "1Cv8.lgd")
+	require.NoError(t, os.WriteFile(broken, append([]byte("SQLite format 3\x00"), make([]byte, 200)...), 0o644))
+	_, _, err = (&sqliteLog{path: broken}).read(0)
+	assert.Error(t, err)
+}
+
+func Test_Reader(t *testing.T) {
+	srvinfo := t.TempDir()
+	textDir := filepath.Join(srvinfo, "reg_1541", "d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a", "1Cv8Log")
+	lgdDir := filepath.Join(srvinfo, "reg_1541", "0f1e2d3c-0000-0000-0000-000000000000", "1Cv8Log")
+	require.NoError(t, os.MkdirAll(textDir, 0o755))
+	require.NoError(t, os.MkdirAll(lgdDir, 0o755))
+
+	lgd, err := os.ReadFile(filepath.Join("testdata", "1Cv8.lgd"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(lgdDir, "1Cv8.lgd"), lgd, 0o644))
+
+	lgp := filepath.Join(textDir, "20250303140000.lgp")
+	require.NoError(t, os.WriteFile(filepath.Join(textDir, "1Cv8.lgf"), []byte(testLGF), 0o644))
+	require.NoError(t, os.WriteFile(lgp, []byte(testLGP[:len(testLGP)-10]), 0o644))
+
+	state := filepath.Join(srvinfo, "state.json")
+
+	// существующие события при первом запуске пропускаются
+	reader := NewReader([]string{srvinfo}, state)
+	events, err := reader.Poll()
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// запись дописана, после перезапуска читается с сохраненной позиции
+	require.NoError(t, os.WriteFile(lgp, []byte(testLGP), 0o644))
+	reader = NewReader([]string{srvinfo}, state)
+	events, err = reader.Poll()
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a", events[0].Infobase)
+		assert.Equal(t, SeverityError, events[0].Severity)
+		assert.Equal(t, "_$Data$_.Post", events[0].Event)
+		assert.Equal(t, "Иванов", events[0].User)
+		assert.Equal(t, "Документ.Реализация", events[0].Metadata)
+	}
+
+	// журнал новой базы читается с начала
+	newDir := filepath.Join(srvinfo, "reg_1541", "11111111-0000-0000-0000-000000000000", "1Cv8Log")
+	require.NoError(t, os.MkdirAll(newDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(newDir, "1Cv8.lgd"), lgd, 0o644))
+	events, err = reader.Poll()
+	require.NoError(t, err)
+	assert.Len(t, events, 400)
+
+	// удаленные базы забываются
+	require.NoError(t, os.RemoveAll(newDir))
+	_, err = reader.Poll()
+	require.NoError(t, err)
+	assert.NotContains(t, reader.state, newDir)
+
+	// база, появившаяся пока экспортер был остановлен, после перезапуска читается с конца, как при первом запуске
+	require.NoError(t, os.MkdirAll(newDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(newDir, "1Cv8.lgd"), lgd, 0o644))
+	reader = NewReader([]string{srvinfo}, state)
+	events, err = reader.Poll()
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Contains(t, reader.state, newDir)
+}
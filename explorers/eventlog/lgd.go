@@ -0,0 +1,185 @@
+package eventlog
+
+import (
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+)
+
+// максимальное количество событий, читаемых из 1Cv8.lgd за один проход, остальные дочитаются в следующий раз
+const maxRows = 100000
+
+// секунды от 0001-01-01 до 1970-01-01, дата в 1Cv8.lgd хранится в десятитысячных долях секунды от 0001-01-01
+const epochOffset = 62135596800
+
+// важность в 1Cv8.lgd хранится номером значения перечисления УровеньЖурналаРегистрации
+var lgdSeverity = map[int64]Severity{
+	0: SeverityInformation,
+	1: SeverityWarning,
+	2: SeverityError,
+	3: SeverityNote,
+}
+
+// sqliteLog журнал в формате SQLite (1Cv8.lgd). База открывается только на чтение, записи из -wal файла,
+// еще не перенесенные платформой в основной файл, читаются средствами SQLite
+type sqliteLog struct {
+	path string
+}
+
+func (l *sqliteLog) open() (*sql.DB, error) {
+	abs, err := filepath.Abs(l.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ошибка открытия %q", l.path)
+	}
+
+	// в URI нужен абсолютный путь, в windows вида /C:/...
+	path := filepath.ToSlash(abs)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	// платформа пишет журнал параллельно с чтением, при блокировке ждем, а не возвращаем ошибку сразу
+	dsn := (&url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro&_pragma=busy_timeout(5000)"}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ошибка открытия %q", l.path)
+	}
+
+	return db, nil
+}
+
+// end последний записанный rowid, используется при первом запуске, что бы не учитывать старые события
+func (l *sqliteLog) end() (int64, error) {
+	db, err := l.open()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var rowid sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(rowid) FROM EventLog").Scan(&rowid); err != nil {
+		return 0, errors.Wrapf(err, "ошибка чтения %q", l.path)
+	}
+
+	return rowid.Int64, nil
+}
+
+// read читает события с rowid больше after, возвращает события и rowid последнего прочитанного
+func (l *sqliteLog) read(after int64) ([]*Event, int64, error) {
+	db, err := l.open()
+	if err != nil {
+		return nil, after, err
+	}
+	defer db.Close()
+
+	// чтение в одной транзакции, что бы события и справочники кодов были из одного состояния журнала
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, after, errors.Wrapf(err, "ошибка чтения %q", l.path)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT rowid, date, severity, userCode, eventCode, CAST(metadataCodes AS TEXT)
+		FROM EventLog WHERE rowid > ? ORDER BY rowid LIMIT ?`, after, maxRows)
+	if err != nil {
+		return nil, after, errors.Wrapf(err, "ошибка чтения %q", l.path)
+	}
+	defer rows.Close()
+
+	type record struct {
+		time                  time.Time
+		severity              Severity
+		user, event, metadata int64
+	}
+
+	var records []record
+	last := after
+	for rows.Next() {
+		var rowid, date, severity, user, event sql.NullInt64
+		var metadata sql.NullString
+		if err := rows.Scan(&rowid, &date, &severity, &user, &event, &metadata); err != nil {
+			return nil, after, errors.Wrapf(err, "ошибка чтения %q", l.path)
+		}
+
+		records = append(records, record{
+			time:     lgdTime(date.Int64),
+			severity: lgdSeverity[severity.Int64],
+			user:     user.Int64,
+			event:    event.Int64,
+			metadata: firstCode(metadata.String),
+		})
+		last = rowid.Int64
+	}
+	if err := rows.Err(); err != nil {
+		return nil, after, errors.Wrapf(err, "ошибка чтения %q", l.path)
+	}
+
+	if len(records) == 0 {
+		return nil, after, nil
+	}
+
+	users, err := l.codes(tx, "UserCodes")
+	if err != nil {
+		return nil, after, err
+	}
+	events, err := l.codes(tx, "EventCodes")
+	if err != nil {
+		return nil, after, err
+	}
+	metadata, err := l.codes(tx, "MetadataCodes")
+	if err != nil {
+		return nil, after, err
+	}
+
+	result := make([]*Event, 0, len(records))
+	for _, r := range records {
+		result = append(result, &Event{
+			Time:     r.time,
+			Severity: r.severity,
+			Event:    events[r.event],
+			User:     users[r.user],
+			Metadata: metadata[r.metadata],
+		})
+	}
+
+	return result, last, nil
+}
+
+// codes словарь вида код - имя
+func (l *sqliteLog) codes(tx *sql.Tx, table string) (map[int64]string, error) {
+	rows, err := tx.Query("SELECT code, name FROM " + table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ошибка чтения %q", l.path)
+	}
+	defer rows.Close()
+
+	result := map[int64]string{}
+	for rows.Next() {
+		var code sql.NullInt64
+		var name sql.NullString
+		if err := rows.Scan(&code, &name); err != nil {
+			return nil, errors.Wrapf(err, "ошибка чтения %q", l.path)
+		}
+		result[code.Int64] = name.String
+	}
+
+	return result, errors.Wrapf(rows.Err(), "ошибка чтения %q", l.path)
+}
+
+func lgdTime(v int64) time.Time {
+	// дата записана по местному времени сервера
+	t := time.Unix(v/10000-epochOffset, 0).UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+}
+
+// firstCode код первого объекта метаданных, в 1Cv8.lgd их может быть несколько через запятую
+func firstCode(v string) int64 {
+	code, _ := strconv.ParseInt(strings.TrimSpace(strings.Split(v, ",")[0]), 10, 64)
+	return code
+}
@@ -0,0 +1,218 @@
+// Package eventlog чтение журнала регистрации 1С (текстовый формат 1Cv8.lgf + *.lgp и SQLite формат 1Cv8.lgd)
+package eventlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Severity важность события
+type Severity string
+
+const (
+	SeverityInformation Severity = "I"
+	SeverityWarning     Severity = "W"
+	SeverityError       Severity = "E"
+	SeverityNote        Severity = "N"
+)
+
+// системные события журнала
+const (
+	EventSessionStart  = "_$Session$_.Start"
+	EventSessionFinish = "_$Session$_.Finish"
+	EventJobFail       = "_$Job$_.Fail"
+)
+
+// Event событие журнала регистрации
+type Event struct {
+	Infobase string // GUID информационной базы (имя каталога в srvinfo)
+	Time     time.Time
+	Severity Severity
+	Event    string
+	User     string
+	Metadata string
+}
+
+// logState позиция чтения журнала одной базы
+type logState struct {
+	Offsets map[string]int64 `json:"offsets,omitempty"` // текстовый формат, ключ - имя .lgp файла
+	RowID   int64            `json:"rowid,omitempty"`   // формат SQLite
+}
+
+// Reader читает новые события журналов регистрации всех баз из каталогов srvinfo (srvinfo/reg_<порт>/<GUID базы>/1Cv8Log).
+// Позиции чтения сохраняются в statePath, что бы после перезапуска продолжить с того же места
+type Reader struct {
+	mx        sync.Mutex
+	dirs      []string
+	statePath string
+	state     map[string]*logState
+	text      map[string]*textLog
+	started   bool
+}
+
+func NewReader(dirs []string, statePath string) *Reader {
+	return &Reader{
+		dirs:      dirs,
+		statePath: statePath,
+		state:     map[string]*logState{},
+		text:      map[string]*textLog{},
+	}
+}
+
+// Poll читает события, записанные с прошлого вызова
+func (r *Reader) Poll() ([]*Event, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	logs, err := r.logs()
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.started {
+		r.started = true
+
+		// журналы, по которым нет сохраненных позиций (первый запуск или база появилась пока экспортер был остановлен),
+		// читаем с конца, что бы не учитывать старые события
+		if err := r.loadState(); err != nil {
+			clear(r.state)
+		}
+		for _, dir := range logs {
+			if _, ok := r.state[dir]; !ok {
+				r.skip(dir)
+			}
+		}
+	}
+
+	var result []*Event
+	var lastErr error
+	for _, dir := range logs {
+		events, err := r.read(dir)
+		if err != nil {
+			lastErr = err
+		}
+
+		infobase := filepath.Base(filepath.Dir(dir))
+		for _, e := range events {
+			e.Infobase = infobase
+		}
+		result = append(result, events...)
+	}
+
+	// удаленные базы больше не отслеживаем
+	for dir := range r.state {
+		if !slices.Contains(logs, dir) {
+			delete(r.state, dir)
+			delete(r.text, dir)
+		}
+	}
+
+	if err := r.saveState(); err != nil {
+		lastErr = err
+	}
+
+	return result, lastErr
+}
+
+func (r *Reader) logs() ([]string, error) {
+	var result []string
+	for _, dir := range r.dirs {
+		logs, err := filepath.Glob(filepath.Join(dir, "reg_*", "*", "1Cv8Log"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ошибка получения каталогов журнала регистрации из %q", dir)
+		}
+		result = append(result, logs...)
+	}
+
+	slices.Sort(result)
+	return result, nil
+}
+
+func (r *Reader) logState(dir string) *logState {
+	if r.state[dir] == nil {
+		r.state[dir] = &logState{}
+	}
+	if r.state[dir].Offsets == nil {
+		r.state[dir].Offsets = map[string]int64{}
+	}
+
+	return r.state[dir]
+}
+
+func (r *Reader) textLog(dir string) *textLog {
+	if _, ok := r.text[dir]; !ok {
+		r.text[dir] = newTextLog(dir)
+	}
+
+	return r.text[dir]
+}
+
+// skip переносит позицию чтения журнала в конец
+func (r *Reader) skip(dir string) {
+	state := r.logState(dir)
+
+	if lgd := filepath.Join(dir, "1Cv8.lgd"); fileExists(lgd) {
+		if rowid, err := (&sqliteLog{path: lgd}).end(); err == nil {
+			state.RowID = rowid
+		}
+		return
+	}
+
+	if offsets, err := r.textLog(dir).end(); err == nil {
+		state.Offsets = offsets
+	}
+}
+
+func (r *Reader) read(dir string) ([]*Event, error) {
+	state := r.logState(dir)
+
+	// формат журнала можно сменить в конфигураторе, поэтому определяем его при каждом чтении
+	if lgd := filepath.Join(dir, "1Cv8.lgd"); fileExists(lgd) {
+		events, last, err := (&sqliteLog{path: lgd}).read(state.RowID)
+		state.RowID = last
+		return events, err
+	}
+
+	if !fileExists(filepath.Join(dir, "1Cv8.lgf")) {
+		return nil, nil
+	}
+
+	return r.textLog(dir).read(state.Offsets)
+}
+
+func (r *Reader) loadState() error {
+	if r.statePath == "" {
+		return errors.New("не задан файл состояния")
+	}
+
+	data, err := os.ReadFile(r.statePath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &r.state)
+}
+
+func (r *Reader) saveState() error {
+	if r.statePath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r.state)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(os.WriteFile(r.statePath, data, 0o644), "ошибка сохранения состояния чтения журнала регистрации")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
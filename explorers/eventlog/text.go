@@ -0,0 +1,228 @@
+package eventlog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/brace"
+	"github.com/pkg/errors"
+)
+
+const (
+	// максимальный объем, читаемый из одного файла за один проход
+	maxChunk = 64 << 20
+	// объем конца файла, в котором ищется последняя запись при первом запуске
+	maxTail = 1 << 20
+)
+
+// начало записи .lgp: {yyyyMMddHHmmss,
+var reRecord = regexp.MustCompile(`(?m)^\{\d{14},`)
+
+// типы записей словаря 1Cv8.lgf
+const (
+	dictUsers    = 1
+	dictEvents   = 4
+	dictMetadata = 5
+)
+
+// dictionary словари журнала: коды пользователей, событий и объектов метаданных
+type dictionary struct {
+	users    map[int64]string
+	events   map[int64]string
+	metadata map[int64]string
+}
+
+func newDictionary() *dictionary {
+	return &dictionary{
+		users:    map[int64]string{},
+		events:   map[int64]string{},
+		metadata: map[int64]string{},
+	}
+}
+
+// textLog журнал в текстовом формате: словари в 1Cv8.lgf, события в файлах yyyyMMddHHmmss.lgp
+type textLog struct {
+	dir  string
+	dict *dictionary
+	// позиция чтения 1Cv8.lgf, словарь только дописывается, поэтому читаем его с места остановки
+	dictOffset int64
+}
+
+func newTextLog(dir string) *textLog {
+	return &textLog{dir: dir, dict: newDictionary()}
+}
+
+func (l *textLog) files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.lgp"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "ошибка получения файлов журнала из %q", l.dir)
+	}
+
+	// имена файлов yyyyMMddHHmmss.lgp, сортировка по имени это сортировка по времени
+	slices.Sort(files)
+	return files, nil
+}
+
+// end размеры существующих файлов, используется при первом запуске, что бы не учитывать старые события
+func (l *textLog) end() (map[string]int64, error) {
+	files, err := l.files()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]int64{}
+	for _, f := range files {
+		if offset, err := fileEnd(f); err == nil {
+			result[filepath.Base(f)] = offset
+		}
+	}
+
+	return result, nil
+}
+
+// fileEnd позиция окончания последней завершенной записи файла .lgp (последняя запись может дописываться)
+func fileEnd(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	start := max(info.Size()-maxTail, 0)
+	data := make([]byte, info.Size()-start)
+	if _, err := f.ReadAt(data, start); err != nil {
+		return 0, err
+	}
+
+	bounds := reRecord.FindAllIndex(data, -1)
+	if len(bounds) == 0 {
+		return info.Size(), nil
+	}
+
+	last := bounds[len(bounds)-1][0]
+	if _, end, ok := brace.ReadList(data, last); ok {
+		return start + int64(end), nil
+	}
+
+	return start + int64(last), nil
+}
+
+// read читает новые события начиная с позиций offsets (ключ - имя файла), позиции обновляются
+func (l *textLog) read(offsets map[string]int64) ([]*Event, error) {
+	if err := l.readDictionary(); err != nil {
+		return nil, err
+	}
+
+	files, err := l.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Event
+	var lastErr error
+	for _, f := range files {
+		name := filepath.Base(f)
+
+		objects, offset, err := readFile(f, offsets[name])
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		offsets[name] = offset
+
+		for _, obj := range objects {
+			result = append(result, l.event(obj))
+		}
+	}
+
+	// удаленные файлы больше не отслеживаем
+	for name := range offsets {
+		if !slices.Contains(files, filepath.Join(l.dir, name)) {
+			delete(offsets, name)
+		}
+	}
+
+	return result, lastErr
+}
+
+func (l *textLog) readDictionary() error {
+	path := filepath.Join(l.dir, "1Cv8.lgf")
+	if info, err := os.Stat(path); err == nil && info.Size() < l.dictOffset {
+		// файл пересоздан
+		l.dict = newDictionary()
+		l.dictOffset = 0
+	}
+
+	objects, offset, err := readFile(path, l.dictOffset)
+	if err != nil {
+		return err
+	}
+	l.dictOffset = offset
+
+	// {1,guid,"Имя",код} - пользователи, {4,"Имя",код} - события, {5,guid,"Имя",код} - метаданные
+	for _, obj := range objects {
+		code := obj.Item(len(obj.List) - 1).Int()
+		switch obj.Item(0).Int() {
+		case dictUsers:
+			l.dict.users[code] = obj.Item(2).Value
+		case dictEvents:
+			l.dict.events[code] = obj.Item(1).Value
+		case dictMetadata:
+			l.dict.metadata[code] = obj.Item(2).Value
+		}
+	}
+
+	return nil
+}
+
+// event формирует событие из записи .lgp:
+// {дата,статус транзакции,{транзакция},пользователь,компьютер,приложение,соединение,событие,важность,"комментарий",метаданные,{данные},...}
+func (l *textLog) event(obj brace.Node) *Event {
+	t, _ := time.ParseInLocation("20060102150405", obj.Item(0).Value, time.Local)
+
+	return &Event{
+		Time:     t,
+		Severity: Severity(obj.Item(8).Value),
+		Event:    l.dict.events[obj.Item(7).Int()],
+		User:     l.dict.users[obj.Item(3).Int()],
+		Metadata: l.dict.metadata[obj.Item(10).Int()],
+	}
+}
+
+// readFile разбирает завершенные объекты файла начиная с offset, возвращает позицию окончания последнего из них
+func readFile(path string, offset int64) ([]brace.Node, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, offset, errors.Wrapf(err, "ошибка открытия файла %q", path)
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() < offset {
+		offset = 0 // файл перезаписан
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, errors.Wrapf(err, "ошибка позиционирования в файле %q", path)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxChunk))
+	if err != nil {
+		return nil, offset, errors.Wrapf(err, "ошибка чтения файла %q", path)
+	}
+
+	objects, pos := brace.ReadObjects(data)
+	if len(objects) == 0 && len(data) == maxChunk {
+		return nil, offset, errors.Errorf("запись журнала больше %d байт в файле %q", maxChunk, path)
+	}
+
+	return objects, offset + int64(pos), nil
+}
@@ -1,11 +1,8 @@
 package exporter
 
 import (
-	"fmt"
-	"os/exec"
 	"runtime/trace"
 	"strconv"
-	"strings"
 
 	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
 	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
@@ -20,50 +17,44 @@ func (exp *ExporterAvailablePerformance) Construct(s *settings.Settings) *Export
 	exp.BaseExporter = newBase(exp.GetName())
 	exp.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + exp.GetName()
-	exp.summary = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:        labelName,
-			Help:        "Доступная производительность хоста",
-			Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-			ConstLabels: prometheus.Labels{"ras_host": s.GetRASHostPort()},
-		},
-		[]string{"host", "cluster", "pid", "type"},
-	)
+	exp.initMetrics(s, exp.GetName(), "Доступная производительность хоста", withClusterLabels("host", "pid", "type"))
 
 	exp.settings = s
+	exp.runner = newRunner(s)
 	return exp
 }
 
-func (exp *ExporterAvailablePerformance) getValue() {
+func (exp *ExporterAvailablePerformance) getValue() (err error) {
 	defer trace.StartRegion(exp.ctx, "AvailablePerformance.getValue").End()
 
 	exp.logger.Info("получение данных экспортера")
 
-	if data, err := exp.getData(); err == nil {
-		exp.logger.Debugf("Количество данных: %d", len(data))
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		data, e := exp.getData(cl)
+		if e != nil {
+			exp.logger.Error(e)
+			err = e
+			continue
+		}
 
-		exp.summary.Reset()
+		exp.logger.Debugf("Количество данных: %d", len(data))
 		for _, item := range data {
-			exp.summary.WithLabelValues(item["host"].(string), item["cluster"].(string), item["pid"].(string), item["type"].(string)).Observe(item["value"].(float64))
+			exp.observe(item["value"].(float64), cl.with(item["host"].(string), item["pid"].(string), item["type"].(string))...)
 		}
-	} else {
-		exp.summary.Reset()
-		exp.logger.Error(err)
 	}
+
+	return err
 }
 
-func (exp *ExporterAvailablePerformance) getData() (result []map[string]interface{}, err error) {
+func (exp *ExporterAvailablePerformance) getData(cl *clusterInfo) (result []map[string]interface{}, err error) {
 
 	// /opt/1C/v8.3/x86_64/rac process --cluster=ee5adb9a-14fa-11e9-7589-005056032522 list
-	var procData []map[string]string
-	if sourceData, err := exp.readData(); err != nil {
+	procData, err := exp.readData(cl)
+	if err != nil {
 		return result, err
-	} else {
-		exp.formatMultiResult(sourceData, &procData)
 	}
 
-	clusterID := exp.GetClusterID()
 	for _, item := range procData {
 		tmp := make(map[string]float64)
 
@@ -94,11 +85,10 @@ func (exp *ExporterAvailablePerformance) getData() (result []map[string]interfac
 
 		for k, v := range tmp {
 			result = append(result, map[string]interface{}{
-				"host":    item["host"],
-				"pid":     item["pid"],
-				"cluster": clusterID,
-				"type":    k,
-				"value":   v,
+				"host":  item["host"],
+				"pid":   item["pid"],
+				"type":  k,
+				"value": v,
 			})
 		}
 	}
@@ -106,20 +96,10 @@ func (exp *ExporterAvailablePerformance) getData() (result []map[string]interfac
 	return result, nil
 }
 
-func (exp *ExporterAvailablePerformance) readData() (string, error) {
-	var param []string
-	if exp.settings.RAC_Host() != "" {
-		param = append(param, strings.Join(appendParam([]string{exp.settings.RAC_Host()}, exp.settings.RAC_Port()), ":"))
-	}
-
-	param = append(param, "process", "list")
-	param = exp.appendLogPass(param)
-	param = append(param, fmt.Sprintf("--cluster=%v", exp.GetClusterID()))
-
-	cmdCommand := exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)
-	if result, err := exp.run(cmdCommand); err != nil {
+func (exp *ExporterAvailablePerformance) readData(cl *clusterInfo) ([]map[string]string, error) {
+	if result, err := exp.query(cl, "process", "list"); err != nil {
 		exp.logger.Error(err)
-		return "", err
+		return nil, err
 	} else {
 		return result, nil
 	}
@@ -132,8 +112,7 @@ func (exp *ExporterAvailablePerformance) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	exp.summary.Collect(ch)
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *ExporterAvailablePerformance) GetName() string {
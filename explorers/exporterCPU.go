@@ -26,15 +26,7 @@ func (exp *CPU) Construct(s *settings.Settings) *CPU {
 	exp.BaseExporter = newBase(exp.GetName())
 	exp.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + exp.GetName()
-	exp.summary = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:       labelName,
-			Help:       "Метрики CPU общий процент загрузки процессора",
-			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-		},
-		[]string{"host"},
-	)
+	exp.initMetrics(s, exp.GetName(), "Метрики CPU общий процент загрузки процессора", []string{"host"})
 
 	exp.settings = s
 	exp.hInfo = new(hardwareInfo)
@@ -42,7 +34,7 @@ func (exp *CPU) Construct(s *settings.Settings) *CPU {
 	return exp
 }
 
-func (exp *CPU) getValue() {
+func (exp *CPU) getValue() error {
 	defer trace.StartRegion(exp.ctx, "CPU.getValue").End()
 
 	exp.logger.Info("получение данных экспортера")
@@ -50,13 +42,15 @@ func (exp *CPU) getValue() {
 	percentage, err := exp.hInfo.TotalCPUPercent(0, false)
 	if err != nil {
 		exp.logger.Error(errors.Wrap(err, "get cpu data error"))
-		return
+		return err
 	}
 
-	exp.summary.Reset()
+	exp.resetMetrics()
 	if len(percentage) == 1 {
-		exp.summary.WithLabelValues(exp.host).Observe(percentage[0])
+		exp.observe(percentage[0], exp.host)
 	}
+
+	return nil
 }
 
 func (exp *CPU) Collect(ch chan<- prometheus.Metric) {
@@ -66,8 +60,7 @@ func (exp *CPU) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	exp.summary.Collect(ch)
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *CPU) GetName() string {
@@ -2,11 +2,9 @@ package exporter
 
 import (
 	"fmt"
-	"os/exec"
 	"runtime/trace"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
 	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
@@ -19,13 +17,11 @@ import (
 
 type ExporterCheckSheduleJob struct {
 	BaseRACExporter
-}
 
-var (
-	baseList        []map[string]string
-	mx              sync.RWMutex
-	fillBaseListRun sync.Mutex
-)
+	// список баз кластеров, задается приложением (SetInfobases)
+	infobases   *InfobaseRegistry
+	unsubscribe func()
+}
 
 func (exp *ExporterCheckSheduleJob) Construct(s *settings.Settings) *ExporterCheckSheduleJob {
 	exp.BaseExporter = newBase(exp.GetName())
@@ -34,60 +30,89 @@ func (exp *ExporterCheckSheduleJob) Construct(s *settings.Settings) *ExporterChe
 	labelName := s.GetMetricNamePrefix() + exp.GetName()
 	exp.gauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name:        labelName,
-			ConstLabels: prometheus.Labels{"ras_host": s.GetRASHostPort()},
-			Help:        "Состояние галки \"блокировка регламентных заданий\": если галка установлена - значение будет 1; иначе 0; или метрика будет отсутствовать",
+			Name: labelName,
+			Help: "Состояние галки \"блокировка регламентных заданий\": если галка установлена - значение будет 1; иначе 0; или метрика будет отсутствовать",
 		},
-		[]string{"base"},
+		withClusterLabels("base"),
 	)
 
 	exp.settings = s
-
-	// Получаем список баз в кластере
-	go exp.fillBaseList()
+	exp.runner = newRunner(s)
 
 	return exp
 }
 
-func (exp *ExporterCheckSheduleJob) getValue() {
+// SetInfobases задает реестр баз, по которому экспортер определяет имена баз
+func (exp *ExporterCheckSheduleJob) SetInfobases(r *InfobaseRegistry) {
+	if exp.unsubscribe != nil {
+		exp.unsubscribe()
+	}
+
+	exp.infobases = r
+
+	// значения удаленных баз больше не обновляются, убираем их, что бы не отдавать устаревшие данные
+	exp.unsubscribe = r.Subscribe(func(ev InfobaseEvent) {
+		if ev.Removed && exp.gauge != nil {
+			exp.gauge.DeletePartialMatch(prometheus.Labels{"base": ev.Name, "cluster_id": ev.Cluster})
+		}
+	})
+}
+
+func (exp *ExporterCheckSheduleJob) getValue() error {
 	defer trace.StartRegion(exp.ctx, "CheckSheduleJob.getValue").End()
 
 	exp.logger.Info("получение данных экспортера")
 
-	if listCheck, err := exp.getData(); err == nil {
-		//exp.gauge.Reset()
-		for key, value := range listCheck {
-			exp.gauge.WithLabelValues(key).Set(lo.If(value, 1.).Else(0.))
-		}
-	} else {
+	listCheck, err := exp.getData()
+	if err != nil {
 		exp.gauge.Reset()
 		exp.logger.Error(err)
+		return err
+	}
+
+	//exp.gauge.Reset()
+	for _, db := range listCheck {
+		value := strings.ToLower(db.info["scheduled-jobs-deny"]) != "off"
+		exp.gauge.WithLabelValues(db.cluster.with(db.name)...).Set(lo.If(value, 1.).Else(0.))
 	}
+
+	return nil
+}
+
+type dbinfo struct {
+	cluster    *clusterInfo
+	guid, name string
+	info       map[string]string // вывод rac infobase info
 }
 
-func (exp *ExporterCheckSheduleJob) getData() (data map[string]bool, err error) {
+func (exp *ExporterCheckSheduleJob) getData() (data []*dbinfo, err error) {
 	exp.logger.Debug("Получение данных")
 
-	data = make(map[string]bool)
+	clusters := map[string]*clusterInfo{}
+	for _, cl := range exp.GetClusters() {
+		clusters[cl.id] = cl
+	}
 
-	// проверяем блокировку рег. заданий по каждой базе
+	// получаем информацию по каждой базе
 	// информация по базе получается довольно долго, особенно если в кластере много баз (например тестовый контур), поэтому делаем через пул воркеров
-	type dbinfo struct {
-		guid, name string
-		value      bool
+	// воркеров не больше, чем мест в очереди rac, остальные все равно ждали бы очередь
+
+	workers := 10
+	if limit := racLimiter.limit(); limit > 0 {
+		workers = min(workers, limit)
 	}
 
 	chanIn := make(chan *dbinfo, 5)
 	chanOut := make(chan *dbinfo)
 	wg := new(sync.WaitGroup)
-	for i := 0; i < 10; i++ {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
 			for db := range chanIn {
-				if baseinfo, err := exp.getInfoBase(db.guid, db.name); err == nil {
-					db.value = strings.ToLower(baseinfo["scheduled-jobs-deny"]) != "off"
+				if baseinfo, err := exp.getInfoBase(db.cluster, db.guid, db.name); err == nil {
+					db.info = baseinfo
 					chanOut <- db
 				} else {
 					exp.logger.Error(err)
@@ -102,125 +127,71 @@ func (exp *ExporterCheckSheduleJob) getData() (data map[string]bool, err error)
 	}()
 
 	go func() {
-		mx.RLock()
-		defer mx.RUnlock()
+		for _, item := range exp.listInfobases() {
+			cl, ok := clusters[item.Cluster]
+			if !ok {
+				continue
+			}
 
-		for _, item := range baseList {
-			exp.logger.Debugf("Запрашиваем информацию для базы %s", item["name"])
-			chanIn <- &dbinfo{name: item["name"], guid: item["infobase"]}
+			exp.logger.Debugf("Запрашиваем информацию для базы %s", item.Name)
+			chanIn <- &dbinfo{cluster: cl, name: item.Name, guid: item.GUID}
 		}
 		close(chanIn)
 	}()
 
 	for db := range chanOut {
-		data[db.name] = db.value
+		data = append(data, db)
 	}
 
 	return data, nil
 }
 
-func (exp *ExporterCheckSheduleJob) getInfoBase(baseGuid, basename string) (map[string]string, error) {
+func (exp *ExporterCheckSheduleJob) getInfoBase(cl *clusterInfo, baseGuid, basename string) (map[string]string, error) {
 	login, pass := exp.settings.GetLogPass(basename)
 	if login == "" {
-		CForce <- struct{}{} // принудительно запрашиваем данные из REST
+		// принудительно обновляем учетные данные, если обновление уже запрошено повторно не ждем
+		select {
+		case CForce <- struct{}{}:
+		default:
+		}
 		return nil, fmt.Errorf("для базы %s не определен пользователь", basename)
 	}
 
-	var param []string
-	if exp.settings.RAC_Host() != "" {
-		param = append(param, strings.Join(appendParam([]string{exp.settings.RAC_Host()}, exp.settings.RAC_Port()), ":"))
-	}
-
-	param = append(param, "infobase")
-	param = append(param, "info")
-	param = exp.appendLogPass(param)
-
-	param = append(param, fmt.Sprintf("--cluster=%v", exp.GetClusterID()))
-	param = append(param, fmt.Sprintf("--infobase=%v", baseGuid))
-	param = append(param, fmt.Sprintf("--infobase-user=%v", login))
-	param = append(param, fmt.Sprintf("--infobase-pwd=%v", pass))
-
-	exp.logger.With("param", param).Debugf("Получаем информацию для базы %q", basename)
-	if result, err := exp.run(exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)); err != nil {
+	// infobase info - самая долгая команда rac, ее результат нужен и shedule_job, и infobase_state,
+	// поэтому он берется из общего кеша и в пределах RACCacheTTL команда по базе выполняется один раз
+	exp.logger.Debugf("Получаем информацию для базы %q", basename)
+	baseInfo, err := exp.query(cl, "infobase", "info",
+		fmt.Sprintf("--infobase=%v", baseGuid),
+		fmt.Sprintf("--infobase-user=%v", login),
+		fmt.Sprintf("--infobase-pwd=%v", pass))
+	if err != nil {
 		exp.logger.Error(err)
 		return map[string]string{}, err
-	} else {
-		var baseInfo []map[string]string
-		exp.formatMultiResult(result, &baseInfo)
-		if len(baseInfo) > 0 {
-			return baseInfo[0], nil
-		} else {
-			return nil, errors.New(fmt.Sprintf("Не удалось получить информацию по базе %q", basename))
-		}
 	}
-}
-
-func (exp *ExporterCheckSheduleJob) findBaseName(ref string) string {
-	mx.RLock()
-	defer mx.RUnlock()
 
-	for _, b := range baseList {
-		if b["infobase"] == ref {
-			return b["name"]
-		}
+	if len(baseInfo) > 0 {
+		return baseInfo[0], nil
+	} else {
+		return nil, errors.New(fmt.Sprintf("Не удалось получить информацию по базе %q", basename))
 	}
-
-	return ""
 }
 
-func (exp *ExporterCheckSheduleJob) fillBaseList() {
-	// fillBaseList вызывается из нескольких мест, но нам достаточно одной горутины, остальные пусть встают в очередь
-	// если завершится текущий экспортер, то стартанет следующий
-	fillBaseListRun.Lock()
-	defer fillBaseListRun.Unlock()
-
-	// редко, но все же список баз может быть изменен, поэтому делаем обновление периодическим, чтобы не приходилось перезапускать экспортер
-	t := time.NewTicker(time.Hour)
-	defer t.Stop()
-
-	for {
-		exp.logger.Info("получаем список баз")
-		if err := exp.getListInfobase(); err != nil {
-			exp.logger.Error(errors.Wrap(err, "ошибка получения списка баз"))
-			t.Reset(time.Minute) // если была ошибка пробуем через минуту, если ошибка пропала, то вернем часовой интервал
-		} else {
-			t.Reset(time.Hour)
-		}
-
-		select {
-		case <-t.C:
-		case <-exp.ctx.Done():
-			exp.logger.Debug("context is done")
-			return
-		}
+func (exp *ExporterCheckSheduleJob) findBaseName(ref string) string {
+	if exp.infobases == nil {
+		return ""
 	}
 
+	b, _ := exp.infobases.Find(ref)
+	return b.Name
 }
 
-func (exp *ExporterCheckSheduleJob) getListInfobase() error {
-	mx.Lock()
-	defer mx.Unlock()
-
-	var param []string
-	if exp.settings.RAC_Host() != "" {
-		param = append(param, strings.Join(appendParam([]string{exp.settings.RAC_Host()}, exp.settings.RAC_Port()), ":"))
+func (exp *ExporterCheckSheduleJob) listInfobases() []Infobase {
+	if exp.infobases == nil {
+		exp.logger.Warn("не задан реестр баз")
+		return nil
 	}
 
-	param = append(param, "infobase")
-	param = append(param, "summary")
-	param = append(param, "list")
-	param = exp.appendLogPass(param)
-	param = append(param, fmt.Sprintf("--cluster=%v", exp.GetClusterID()))
-
-	if result, err := exp.run(exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)); err != nil {
-		return err
-	} else {
-		exp.mx.Lock()
-		exp.formatMultiResult(result, &baseList)
-		exp.mx.Unlock()
-	}
-
-	return nil
+	return exp.infobases.List()
 }
 
 func (exp *ExporterCheckSheduleJob) Collect(ch chan<- prometheus.Metric) {
@@ -230,8 +201,7 @@ func (exp *ExporterCheckSheduleJob) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	exp.gauge.Collect(ch)
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *ExporterCheckSheduleJob) GetName() string {
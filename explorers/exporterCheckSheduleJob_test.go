@@ -3,75 +3,40 @@ package exporter
 import (
 	"context"
 	"testing"
-	"time"
 
 	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
-	"github.com/agiledragon/gomonkey/v2"
-	"github.com/pkg/errors"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
 	"github.com/stretchr/testify/assert"
 )
 
-func Test_fillBaseList(t *testing.T) {
+func Test_findBaseName(t *testing.T) {
 	objectCSJ := new(ExporterCheckSheduleJob)
 	objectCSJ.ctx, objectCSJ.cancel = context.WithCancel(context.Background())
 	objectCSJ.logger = logger.NopLogger.Named("test")
 
-	t.Run("error", func(t *testing.T) {
-		delay := time.NewTicker(time.Second * 200)
-		p := gomonkey.ApplyFunc(time.NewTicker, func(d time.Duration) *time.Ticker { return delay })
-		defer p.Reset()
-
-		p.ApplyPrivateMethod(objectCSJ, "getListInfobase", func(_ *ExporterCheckSheduleJob) error {
-			return errors.New("error")
-		})
-
-		go objectCSJ.fillBaseList()
-
-		time.Sleep(time.Second)
-		objectCSJ.cancel()
-	})
-
-	t.Run("pass", func(t *testing.T) {
-		delay := time.NewTicker(time.Millisecond * 200)
-		p := gomonkey.ApplyFunc(time.NewTicker, func(d time.Duration) *time.Ticker { return delay })
-		defer p.Reset()
+	// реестр не задан
+	assert.Equal(t, "", objectCSJ.findBaseName("test"))
 
-		p.ApplyPrivateMethod(objectCSJ, "getListInfobase", func(_ *ExporterCheckSheduleJob) error {
-			return nil
-		})
-
-		go objectCSJ.fillBaseList()
-
-		time.Sleep(time.Second)
-		objectCSJ.cancel()
-	})
-}
-
-func Test_findBaseName(t *testing.T) {
-	objectCSJ := new(ExporterCheckSheduleJob)
-	objectCSJ.ctx, objectCSJ.cancel = context.WithCancel(context.Background())
-	objectCSJ.logger = logger.NopLogger.Named("test")
-	baseList = []map[string]string{
-		{
-			"infobase": "test",
-			"name":     "nametest",
-		},
-	}
+	objectCSJ.SetInfobases(testInfobases(new(settings.Settings), Infobase{GUID: "test", Name: "nametest", Cluster: "123"}))
 
 	res := objectCSJ.findBaseName("test")
 	assert.Equal(t, "nametest", res)
 
 	res = objectCSJ.findBaseName("test2")
 	assert.Equal(t, "", res)
+}
 
-	mx.Lock()
-	go func() {
-		time.Sleep(time.Millisecond * 500)
-		mx.Unlock()
-	}()
+// testInfobases реестр с заданным списком баз
+func testInfobases(s *settings.Settings, bases ...Infobase) *InfobaseRegistry {
+	r := newInfobaseRegistry(s)
 
-	s := time.Now()
-	objectCSJ.findBaseName("test2")
+	byCluster := map[string][]*Infobase{}
+	for _, b := range bases {
+		byCluster[b.Cluster] = append(byCluster[b.Cluster], &b)
+	}
+	for cluster, list := range byCluster {
+		r.set(cluster, list)
+	}
 
-	assert.GreaterOrEqual(t, time.Since(s).Microseconds(), int64(500))
+	return r
 }
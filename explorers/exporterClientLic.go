@@ -1,8 +1,6 @@
 package exporter
 
 import (
-	"fmt"
-	"os/exec"
 	"runtime/trace"
 	"strings"
 
@@ -13,38 +11,90 @@ import (
 
 type ExporterClientLic struct {
 	BaseRACExporter
+
+	capacity          *prometheus.GaugeVec
+	capacityAll       *prometheus.GaugeVec
+	used              *prometheus.GaugeVec
+	utilization       *prometheus.GaugeVec
+	serverUtilization *prometheus.GaugeVec
+}
+
+// licSeries серия лицензий, полученная сеансами кластера
+type licSeries struct {
+	capacity int64 // max-users-cur - на сколько пользователей лицензия, по нему считается доля использования
+	used     int
+}
+
+// licSeriesServer серия лицензий, выданная одним менеджером кластера
+type licSeriesServer struct {
+	series, server string
 }
 
 func (exp *ExporterClientLic) Construct(s *settings.Settings) *ExporterClientLic {
 	exp.BaseExporter = newBase(exp.GetName())
 	exp.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + exp.GetName()
-	exp.summary = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:        labelName,
-			Help:        "Клиентские лицензии 1С",
-			Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-			ConstLabels: prometheus.Labels{"ras_host": s.GetRASHostPort()},
+	exp.initMetrics(s, exp.GetName(), "Клиентские лицензии 1С", withClusterLabels("host", "licSRV"))
+
+	name := s.GetMetricNamePrefix() + exp.GetName()
+	exp.capacity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_capacity",
+			Help: "Количество пользователей лицензии серии (max-users-cur), kind: software - программная, network_key - сетевой ключ, local_key - локальный ключ",
 		},
-		[]string{"host", "licSRV"},
+		withClusterLabels("series", "presentation", "kind", "licSRV", "pid"),
 	)
+	exp.capacityAll = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_capacity_all",
+			Help: "Общее количество пользователей серии по всем ее лицензиям (max-users-all), метки как у " + name + "_capacity",
+		},
+		withClusterLabels("series", "presentation", "kind", "licSRV", "pid"),
+	)
+	exp.used = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_used",
+			Help: "Количество сеансов кластера, получивших лицензию серии (сеансы других кластеров с той же лицензией не учитываются)",
+		},
+		withClusterLabels("series", "kind", "licSRV"),
+	)
+	exp.utilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_utilization_ratio",
+			Help: "Доля использованных лицензий серии от количества пользователей лицензии (max-users-cur), 1 - свободных лицензий нет",
+		},
+		withClusterLabels("series"),
+	)
+	exp.serverUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_server_utilization_ratio",
+			Help: "Доля использованных лицензий всех серий, выданных сервером лицензирования, от суммы max-users-cur серий",
+		},
+		withClusterLabels("licSRV"),
+	)
+	exp.collectors = []prometheus.Collector{exp.capacity, exp.capacityAll, exp.used, exp.utilization, exp.serverUtilization}
 
 	exp.settings = s
+	exp.runner = newRunner(s)
 	return exp
 }
 
-func (exp *ExporterClientLic) getValue() {
+func (exp *ExporterClientLic) getValue() (err error) {
 	defer trace.StartRegion(exp.ctx, "ClientLic.getValue").End()
 
 	exp.logger.Info("получение данных экспортера")
 
-	group := map[groupKey]int{}
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		group := map[groupKey]int{}
 
-	lic, _ := exp.getLic()
-	exp.logger.Debugf("количество лицензий %v", len(lic))
+		lic, e := exp.getLic(cl)
+		if e != nil {
+			err = e
+			continue
+		}
+		exp.logger.Debugf("количество лицензий %v", len(lic))
 
-	if len(lic) > 0 {
 		for _, item := range lic {
 			key := groupKey{host: item["host"], key: item["rmngr-address"]}
 			if strings.Trim(key.key, " ") == "" {
@@ -53,41 +103,99 @@ func (exp *ExporterClientLic) getValue() {
 			group[key]++
 		}
 
-		exp.summary.Reset()
 		for k, v := range group {
-			exp.summary.WithLabelValues(k.host, strings.Trim(k.key, "\"")).Observe(float64(v))
+			exp.observe(float64(v), cl.with(k.host, strings.Trim(k.key, "\""))...)
 		}
 
-	} else {
-		exp.summary.Reset()
+		exp.observeCapacity(cl, lic)
 	}
+
+	return err
 }
 
-func (exp *ExporterClientLic) getLic() (licData []map[string]string, err error) {
-	exp.logger.Debug("getLic start")
+// observeCapacity емкость и использование серий лицензий. Серии, которые не получил ни один сеанс, в выводе rac отсутствуют.
+// Использование считается только по сеансам опрашиваемого кластера
+func (exp *ExporterClientLic) observeCapacity(cl *clusterInfo, lic []map[string]string) {
+	series := map[string]*licSeries{}
+	byServer := map[licSeriesServer]*licSeries{}
+	for _, item := range lic {
+		name := strings.Trim(item["series"], "\"")
+		if name == "" {
+			continue
+		}
 
-	// /opt/1C/v8.3/x86_64/rac session list --licenses --cluster=5c4602fc-f704-11e8-fa8d-005056031e96
-	licData = []map[string]string{}
-	var param []string
+		server, kind := licServer(item), licKind(item)
+		capacity := atoi(item["max-users-cur"])
+		if series[name] == nil {
+			series[name] = &licSeries{capacity: capacity}
+		}
+		series[name].used++
 
-	// если заполнен хост, то порт может быть не заполнен, если не заполнен хост, а заполнен порт, то так не будет работать, поэтому условие с портом внутри
-	if exp.settings.RAC_Host() != "" {
-		param = append(param, strings.Join(appendParam([]string{exp.settings.RAC_Host()}, exp.settings.RAC_Port()), ":"))
+		key := licSeriesServer{series: name, server: server}
+		if byServer[key] == nil {
+			byServer[key] = &licSeries{capacity: capacity}
+		}
+		byServer[key].used++
+
+		labels := cl.with(name, strings.Trim(item["short-presentation"], "\""), kind, server, item["rmngr-pid"])
+		exp.capacity.WithLabelValues(labels...).Set(float64(capacity))
+		exp.capacityAll.WithLabelValues(labels...).Set(float64(atoi(item["max-users-all"])))
+		exp.used.WithLabelValues(cl.with(name, kind, server)...).Inc()
+	}
+
+	for name, s := range series {
+		if s.capacity > 0 {
+			exp.utilization.WithLabelValues(cl.with(name)...).Set(float64(s.used) / float64(s.capacity))
+		}
+	}
+
+	// серия, лицензии которой выдавали несколько менеджеров, учитывается у каждого только своими сеансами
+	servers := map[string]*licSeries{}
+	for k, s := range byServer {
+		if s.capacity <= 0 {
+			continue
+		}
+
+		if servers[k.server] == nil {
+			servers[k.server] = new(licSeries)
+		}
+		servers[k.server].used += s.used
+		servers[k.server].capacity += s.capacity
+	}
+
+	for server, t := range servers {
+		exp.serverUtilization.WithLabelValues(cl.with(server)...).Set(float64(t.used) / float64(t.capacity))
 	}
+}
+
+// licServer менеджер кластера, выдавший лицензию; если лицензия получена клиентом (не сервером) - тип лицензии
+func licServer(item map[string]string) string {
+	if server := strings.Trim(item["rmngr-address"], "\" "); server != "" {
+		return server
+	}
+
+	return item["license-type"]
+}
 
-	param = append(param, "session")
-	param = append(param, "list")
-	param = exp.appendLogPass(param)
+// licKind вид лицензии: программная, сетевой или локальный ключ защиты
+func licKind(item map[string]string) string {
+	switch {
+	case item["license-type"] == "soft":
+		return "software"
+	case item["net"] == "yes":
+		return "network_key"
+	default:
+		return "local_key"
+	}
+}
 
-	param = append(param, "--licenses")
-	param = append(param, fmt.Sprintf("--cluster=%v", exp.GetClusterID()))
+func (exp *ExporterClientLic) getLic(cl *clusterInfo) (licData []map[string]string, err error) {
+	exp.logger.Debug("getLic start")
 
-	cmdCommand := exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)
-	if result, err := exp.run(cmdCommand); err != nil {
+	// /opt/1C/v8.3/x86_64/rac session list --licenses --cluster=5c4602fc-f704-11e8-fa8d-005056031e96
+	if licData, err = exp.query(cl, "session", "list", "--licenses"); err != nil {
 		exp.logger.Error(err)
 		return []map[string]string{}, err
-	} else {
-		exp.formatMultiResult(result, &licData)
 	}
 
 	return licData, nil
@@ -100,8 +208,7 @@ func (exp *ExporterClientLic) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	exp.summary.Collect(ch)
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *ExporterClientLic) GetName() string {
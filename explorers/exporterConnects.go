@@ -1,10 +1,7 @@
 package exporter
 
 import (
-	"fmt"
-	"os/exec"
 	"runtime/trace"
-	"strings"
 
 	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
 	"github.com/pkg/errors"
@@ -19,75 +16,52 @@ func (exp *ExporterConnects) Construct(s *settings.Settings) *ExporterConnects {
 	exp.BaseExporter = newBase(exp.GetName())
 	exp.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + exp.GetName()
-	exp.summary = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:        labelName,
-			Help:        "Соединения 1С",
-			Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-			ConstLabels: prometheus.Labels{"ras_host": s.GetRASHostPort()},
-		},
-		[]string{"host", "base"},
-	)
+	exp.initMetrics(s, exp.GetName(), "Соединения 1С", withClusterLabels("host", "base"))
 
 	exp.settings = s
+	exp.runner = newRunner(s)
 	exp.ExporterCheckSheduleJob.settings = s
-	go exp.fillBaseList()
 
 	return exp
 }
 
-func (exp *ExporterConnects) getValue() {
+func (exp *ExporterConnects) getValue() (err error) {
 	defer trace.StartRegion(exp.ctx, "Connects.getValue").End()
 
 	exp.logger.Info("получение данных экспортера")
 
-	connects, err := exp.getConnects()
-	if err != nil {
-		exp.logger.Error(errors.Wrap(err, "get connects error"))
-		exp.summary.Reset()
-		return
-	}
-
-	groupByDB := map[groupKey]int{}
-	for _, item := range connects {
-		groupByDB[groupKey{
-			host: item["host"],
-			key:  exp.findBaseName(item["infobase"]),
-		}]++
-	}
-
-	exp.summary.Reset()
-
-	// с разбивкой по БД
-	for k, v := range groupByDB {
-		exp.summary.WithLabelValues(k.host, k.key).Observe(float64(v))
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		connects, e := exp.getConnects(cl)
+		if e != nil {
+			exp.logger.Error(errors.Wrap(e, "get connects error"))
+			err = e
+			continue
+		}
+
+		groupByDB := map[groupKey]int{}
+		for _, item := range connects {
+			groupByDB[groupKey{
+				host: item["host"],
+				key:  exp.findBaseName(item["infobase"]),
+			}]++
+		}
+
+		// с разбивкой по БД
+		for k, v := range groupByDB {
+			exp.observe(float64(v), cl.with(k.host, k.key)...)
+		}
 	}
 
+	return err
 }
 
-func (exp *ExporterConnects) getConnects() (connData []map[string]string, err error) {
+func (exp *ExporterConnects) getConnects(cl *clusterInfo) (connData []map[string]string, err error) {
 	defer trace.StartRegion(exp.ctx, "Connects.getConnects").End()
 
-	connData = []map[string]string{}
-
-	var param []string
-	if exp.settings.RAC_Host() != "" {
-		param = append(param, strings.Join(appendParam([]string{exp.settings.RAC_Host()}, exp.settings.RAC_Port()), ":"))
-	}
-
-	param = append(param, "connection")
-	param = append(param, "list")
-	param = exp.appendLogPass(param)
-
-	param = append(param, fmt.Sprintf("--cluster=%v", exp.GetClusterID()))
-
-	cmdCommand := exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)
-	if result, err := exp.run(cmdCommand); err != nil {
+	if connData, err = exp.query(cl, "connection", "list"); err != nil {
 		exp.logger.Error(err)
 		return []map[string]string{}, err
-	} else {
-		exp.formatMultiResult(result, &connData)
 	}
 
 	return connData, nil
@@ -100,8 +74,7 @@ func (exp *ExporterConnects) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	exp.summary.Collect(ch)
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *ExporterConnects) GetName() string {
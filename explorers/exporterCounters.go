@@ -0,0 +1,184 @@
+package exporter
+
+import (
+	"fmt"
+	"runtime/trace"
+	"strconv"
+	"strings"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counterFields показатели счетчиков потребления ресурсов (rac counter values) и ограничений (rac limit list)
+var counterFields = []string{
+	"duration",
+	"cpu-time",
+	"duration-dbms",
+	"service",
+	"memory",
+	"read",
+	"write",
+	"dbms-bytes",
+	"call",
+	"number-of-active-sessions",
+	"number-of-sessions",
+}
+
+// ExporterCounters счетчики потребления ресурсов кластера и ограничения по ним (rac counter, rac limit), платформа 8.3.15 и выше
+type ExporterCounters struct {
+	BaseRACExporter
+
+	values *prometheus.GaugeVec
+	limits *prometheus.GaugeVec
+	usage  *prometheus.GaugeVec
+}
+
+// counterInfo счетчик из rac counter list
+type counterInfo struct {
+	name, group string
+	fields      []string // показатели, которые собирает счетчик
+}
+
+func (exp *ExporterCounters) Construct(s *settings.Settings) *ExporterCounters {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	name := s.GetMetricNamePrefix() + exp.GetName()
+	exp.values = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name,
+			Help: "Значения счетчиков потребления ресурсов в разрезе объектов счетчика (пользователь, база и т.д.)",
+		},
+		withClusterLabels("counter", "group", "object", "field"),
+	)
+	exp.limits = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_limit",
+			Help: "Ограничения потребления ресурсов: порог показателя счетчика (показатели без порога не отдаются)",
+		},
+		withClusterLabels("limit", "counter", "action", "field"),
+	)
+	exp.usage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_limit_usage_ratio",
+			Help: "Отношение значения счетчика к порогу ограничения, 1 - ограничение достигнуто",
+		},
+		withClusterLabels("limit", "counter", "object", "field"),
+	)
+	exp.collectors = []prometheus.Collector{exp.values, exp.limits, exp.usage}
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+	return exp
+}
+
+func (exp *ExporterCounters) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "Counters.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		if e := exp.clusterCounters(cl); e != nil {
+			exp.logger.Error(e)
+			err = e
+		}
+	}
+
+	return err
+}
+
+func (exp *ExporterCounters) clusterCounters(cl *clusterInfo) (err error) {
+	list, err := exp.query(cl, "counter", "list")
+	if err != nil {
+		return errors.Wrap(err, "get counters error")
+	}
+
+	exp.logger.Debugf("Количество счетчиков: %d", len(list))
+
+	// значения счетчиков: счетчик -> объект -> показатель
+	values := map[string]map[string]map[string]float64{}
+	for _, item := range list {
+		counter := counterInfo{name: strings.Trim(item["name"], "\""), group: item["group"]}
+		for _, field := range counterFields {
+			// показатели, которые счетчик не собирает, помечены как not-analyze
+			if v, ok := item[field]; ok && v != "not-analyze" {
+				counter.fields = append(counter.fields, field)
+			}
+		}
+
+		data, e := exp.query(cl, "counter", "values", fmt.Sprintf("--counter=%s", counter.name))
+		if e != nil {
+			err = errors.Wrapf(e, "get counter %q values error", counter.name)
+			continue
+		}
+
+		values[counter.name] = map[string]map[string]float64{}
+		for _, row := range data {
+			object := strings.Trim(row["object"], "\"")
+			values[counter.name][object] = map[string]float64{}
+
+			for _, field := range counter.fields {
+				v, e := strconv.ParseFloat(row[field], 64)
+				if e != nil {
+					continue
+				}
+
+				values[counter.name][object][field] = v
+				exp.values.WithLabelValues(cl.with(counter.name, counter.group, object, counterField(field))...).Set(v)
+			}
+		}
+	}
+
+	limits, e := exp.query(cl, "limit", "list")
+	if e != nil {
+		return errors.Wrap(e, "get limits error")
+	}
+
+	exp.logger.Debugf("Количество ограничений: %d", len(limits))
+	for _, item := range limits {
+		limit, counter := strings.Trim(item["name"], "\""), strings.Trim(item["counter"], "\"")
+		for _, field := range counterFields {
+			// 0 - ограничение по показателю не задано
+			threshold, e := strconv.ParseFloat(item[field], 64)
+			if e != nil || threshold <= 0 {
+				continue
+			}
+
+			exp.limits.WithLabelValues(cl.with(limit, counter, item["action"], counterField(field))...).Set(threshold)
+			for object, fields := range values[counter] {
+				if v, ok := fields[field]; ok {
+					exp.usage.WithLabelValues(cl.with(limit, counter, object, counterField(field))...).Set(v / threshold)
+				}
+			}
+		}
+	}
+
+	return err
+}
+
+// counterField значение метки field: имя показателя rac в стиле имен prometheus
+func counterField(field string) string {
+	return strings.ReplaceAll(field, "-", "_")
+}
+
+func (exp *ExporterCounters) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "Counters.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterCounters) GetName() string {
+	return "counters"
+}
+
+func (exp *ExporterCounters) GetType() model.MetricType {
+	return model.TypeRAC
+}
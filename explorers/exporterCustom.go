@@ -0,0 +1,234 @@
+package exporter
+
+import (
+	"fmt"
+	"runtime/trace"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	promModel "github.com/prometheus/common/model"
+)
+
+// агрегирование записей вывода rac в пользовательском экспортере
+const (
+	aggregateNone  = ""
+	aggregateCount = "count"
+	aggregateSum   = "sum"
+	aggregateMax   = "max"
+)
+
+// ExporterCustom экспортер, полностью описанный в настройках (свойство Command экспортера):
+// выполняет команду rac и отдает поля вывода Values в разрезе полей Labels
+type ExporterCustom struct {
+	ExporterCheckSheduleJob
+
+	name      string
+	command   []string
+	labels    []string // поля вывода rac, которые становятся метками
+	values    []string // числовые поля вывода rac
+	aggregate string
+}
+
+func (exp *ExporterCustom) Construct(s *settings.Settings, name string) (*ExporterCustom, error) {
+	exp.name = name
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	exp.command = strings.Fields(strings.Join(s.GetPropertyStrings(name, "Command"), " "))
+	exp.labels = s.GetPropertyStrings(name, "Labels")
+	exp.values = s.GetPropertyStrings(name, "Values")
+	exp.aggregate = strings.ToLower(fmt.Sprint(s.GetProperty(name, "Aggregate", aggregateNone)))
+
+	if len(exp.command) == 0 {
+		return nil, fmt.Errorf("для экспортера %q не задана команда rac (Command)", name)
+	}
+	switch exp.aggregate {
+	case aggregateNone, aggregateCount:
+	case aggregateSum, aggregateMax:
+		if len(exp.values) == 0 {
+			return nil, fmt.Errorf("для экспортера %q с Aggregate: %s не заданы поля Values", name, exp.aggregate)
+		}
+	default:
+		return nil, fmt.Errorf("для экспортера %q задан неизвестный Aggregate %q, допустимо count, sum, max", name, exp.aggregate)
+	}
+
+	// без полей значений считается количество записей
+	if len(exp.values) == 0 {
+		exp.aggregate = aggregateCount
+	}
+
+	// некорректное имя метрики или метки приводит к панике при регистрации, поэтому такой экспортер пропускается
+	if !promModel.IsValidLegacyMetricName(s.GetMetricNamePrefix() + name) {
+		return nil, fmt.Errorf("имя экспортера %q не может быть именем метрики prometheus (допустимы латинские буквы, цифры, _ и :)", name)
+	}
+
+	labels := make([]string, 0, len(exp.labels)+1)
+	for _, l := range exp.labels {
+		label := strings.ReplaceAll(l, "-", "_")
+		switch {
+		case !promModel.LabelName(label).IsValidLegacy() || strings.HasPrefix(label, "__"):
+			return nil, fmt.Errorf("для экспортера %q поле %q в Labels не может быть именем метки prometheus", name, l)
+		case label == "field" || slices.Contains(clusterLabels, label):
+			return nil, fmt.Errorf("для экспортера %q поле %q в Labels совпадает с зарезервированной меткой %q", name, l, label)
+		case slices.Contains(labels, label):
+			return nil, fmt.Errorf("для экспортера %q поле %q в Labels указано повторно", name, l)
+		}
+		labels = append(labels, label)
+	}
+	if exp.aggregate != aggregateCount {
+		labels = append(labels, "field")
+	}
+
+	help := fmt.Sprint(s.GetProperty(name, "Help", "rac "+strings.Join(exp.command, " ")))
+
+	// по умолчанию значения отдаются как gauge, другие виды задаются свойством MetricKinds
+	var kinds []settings.TypeMetricKind
+	if len(s.GetPropertyStrings(name, "MetricKinds")) == 0 {
+		kinds = []settings.TypeMetricKind{settings.KindGauge}
+	}
+	exp.initMetrics(s, name, help, withClusterLabels(labels...), kinds...)
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+
+	return exp, nil
+}
+
+func (exp *ExporterCustom) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "Custom.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		data, e := exp.query(cl, exp.command...)
+		if e != nil {
+			exp.logger.Error(errors.Wrapf(e, "rac %s error", strings.Join(exp.command, " ")))
+			err = e
+			continue
+		}
+
+		exp.logger.Debugf("Количество записей: %d", len(data))
+		exp.observeRecords(cl, data)
+	}
+
+	return err
+}
+
+func (exp *ExporterCustom) observeRecords(cl *clusterInfo, data []map[string]string) {
+	type groupValue struct {
+		lvs    []string
+		values map[string]float64
+		count  int
+	}
+
+	groups := map[string]*groupValue{}
+	var order []string
+	for _, item := range data {
+		lvs := exp.labelValues(item)
+
+		if exp.aggregate == aggregateNone {
+			for _, field := range exp.values {
+				if v, ok := racValue(item[field]); ok {
+					exp.observe(v, cl.with(append(lvs, field)...)...)
+				}
+			}
+			continue
+		}
+
+		key := strings.Join(lvs, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = &groupValue{lvs: lvs, values: map[string]float64{}}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		g.count++
+		for _, field := range exp.values {
+			v, ok := racValue(item[field])
+			if !ok {
+				continue
+			}
+
+			if current, exists := g.values[field]; exp.aggregate == aggregateMax && exists {
+				g.values[field] = max(current, v)
+			} else if exp.aggregate == aggregateMax {
+				g.values[field] = v
+			} else {
+				g.values[field] += v
+			}
+		}
+	}
+
+	for _, key := range order {
+		g := groups[key]
+		if exp.aggregate == aggregateCount {
+			exp.observe(float64(g.count), cl.with(g.lvs...)...)
+			continue
+		}
+
+		for _, field := range exp.values {
+			if v, ok := g.values[field]; ok {
+				exp.observe(v, cl.with(append(g.lvs, field)...)...)
+			}
+		}
+	}
+}
+
+// labelValues значения меток записи, идентификатор базы (поле infobase) заменяется на имя базы
+func (exp *ExporterCustom) labelValues(item map[string]string) []string {
+	lvs := make([]string, 0, len(exp.labels)+1)
+	for _, l := range exp.labels {
+		v := strings.Trim(item[l], "\"")
+		if l == "infobase" {
+			if name := exp.findBaseName(v); name != "" {
+				v = name
+			}
+		}
+		lvs = append(lvs, v)
+	}
+
+	return lvs
+}
+
+// racValue числовое представление поля вывода rac: число, yes/on - 1, no/off - 0
+func racValue(v string) (float64, bool) {
+	v = strings.Trim(v, "\" ")
+
+	switch strings.ToLower(v) {
+	case "yes", "on", "true":
+		return 1, true
+	case "no", "off", "false":
+		return 0, true
+	}
+
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return n, true
+	}
+
+	return 0, false
+}
+
+func (exp *ExporterCustom) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "Custom.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterCustom) GetName() string {
+	return exp.name
+}
+
+func (exp *ExporterCustom) GetType() model.MetricType {
+	return model.TypeRAC
+}
@@ -25,15 +25,7 @@ func (exp *ExporterDisk) Construct(s *settings.Settings) *ExporterDisk {
 	exp.BaseExporter = newBase(exp.GetName())
 	exp.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + exp.GetName()
-	exp.summary = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:       labelName,
-			Help:       "Показатели дисков",
-			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-		},
-		[]string{"host", "disk", "metrics"},
-	)
+	exp.initMetrics(s, exp.GetName(), "Показатели дисков", []string{"host", "disk", "metrics"})
 
 	exp.settings = s
 	exp.hInfo = new(hardwareInfo)
@@ -41,7 +33,7 @@ func (exp *ExporterDisk) Construct(s *settings.Settings) *ExporterDisk {
 	return exp
 }
 
-func (exp *ExporterDisk) getValue() {
+func (exp *ExporterDisk) getValue() error {
 	defer trace.StartRegion(exp.ctx, "Disk.getValue").End()
 
 	exp.logger.Info("получение данных экспортера")
@@ -49,18 +41,19 @@ func (exp *ExporterDisk) getValue() {
 	dInfo, err := exp.hInfo.IOCounters()
 	if err != nil {
 		exp.logger.Error(errors.Wrap(err, "IOCounters error"))
-		return
+		return err
 	}
 
-	exp.summary.Reset()
+	exp.resetMetrics()
 	for k, v := range dInfo {
-		exp.summary.WithLabelValues(exp.host, k, "WeightedIO").Observe(float64(v.WeightedIO))
-		exp.summary.WithLabelValues(exp.host, k, "IopsInProgress").Observe(float64(v.IopsInProgress))
-		exp.summary.WithLabelValues(exp.host, k, "ReadCount").Observe(float64(v.ReadCount))
-		exp.summary.WithLabelValues(exp.host, k, "WriteCount").Observe(float64(v.WriteCount))
-		exp.summary.WithLabelValues(exp.host, k, "IoTime").Observe(float64(v.IoTime))
+		exp.observe(float64(v.WeightedIO), exp.host, k, "WeightedIO")
+		exp.observe(float64(v.IopsInProgress), exp.host, k, "IopsInProgress")
+		exp.observe(float64(v.ReadCount), exp.host, k, "ReadCount")
+		exp.observe(float64(v.WriteCount), exp.host, k, "WriteCount")
+		exp.observe(float64(v.IoTime), exp.host, k, "IoTime")
 	}
 
+	return nil
 }
 
 func (exp *ExporterDisk) Collect(ch chan<- prometheus.Metric) {
@@ -70,8 +63,7 @@ func (exp *ExporterDisk) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	exp.summary.Collect(ch)
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *ExporterDisk) GetName() string {
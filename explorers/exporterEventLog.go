@@ -0,0 +1,131 @@
+package exporter
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/trace"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/eventlog"
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//go:generate mockgen -source=$GOFILE -package=mock_models -destination=./mock/mockEventLog.go
+type IEventLogReader interface {
+	Poll() ([]*eventlog.Event, error)
+}
+
+// ExporterEventLog метрики по журналу регистрации информационных баз
+type ExporterEventLog struct {
+	ExporterCheckSheduleJob
+
+	reader   IEventLogReader
+	events   *prometheus.CounterVec
+	users    *prometheus.CounterVec
+	metadata *prometheus.CounterVec
+	sessions *prometheus.CounterVec
+	jobs     *prometheus.CounterVec
+}
+
+var severityLabels = map[eventlog.Severity]string{
+	eventlog.SeverityError:   "error",
+	eventlog.SeverityWarning: "warning",
+}
+
+func (exp *ExporterEventLog) Construct(s *settings.Settings) *ExporterEventLog {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	labelName := s.GetMetricNamePrefix() + exp.GetName()
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: labelName + name, Help: help}, append([]string{"host", "base"}, labels...))
+	}
+
+	exp.events = counter("_events_total", "Количество ошибок и предупреждений журнала регистрации в разрезе событий", "severity", "event")
+	exp.users = counter("_user_events_total", "Количество ошибок и предупреждений журнала регистрации в разрезе пользователей", "severity", "user")
+	exp.metadata = counter("_metadata_events_total", "Количество ошибок и предупреждений журнала регистрации в разрезе объектов метаданных", "severity", "metadata")
+	exp.sessions = counter("_sessions_total", "Количество начатых (start) и завершенных (finish) сеансов", "action")
+	exp.jobs = counter("_job_failures_total", "Количество ошибок выполнения регламентных заданий", "job")
+	exp.collectors = []prometheus.Collector{exp.events, exp.users, exp.metadata, exp.sessions, exp.jobs}
+
+	dirs := s.GetPropertyStrings(exp.GetName(), "SrvInfo")
+	if len(dirs) == 0 {
+		dirs = []string{defaultSrvInfo()}
+	}
+
+	exp.reader = eventlog.NewReader(dirs, fmt.Sprint(s.GetProperty(exp.GetName(), "StateFile", "")))
+	exp.settings = s
+	exp.runner = newRunner(s)
+	exp.ExporterCheckSheduleJob.settings = s
+
+	return exp
+}
+
+func (exp *ExporterEventLog) getValue() error {
+	defer trace.StartRegion(exp.ctx, "EventLog.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	// даже при ошибке чтения журнала одной из баз учитываем то, что удалось прочитать
+	events, err := exp.reader.Poll()
+	if err != nil {
+		exp.logger.Error(errors.Wrap(err, "ошибка чтения журнала регистрации"))
+	}
+
+	exp.logger.Debugf("прочитано событий: %d", len(events))
+	for _, e := range events {
+		base := exp.findBaseName(e.Infobase)
+		if base == "" {
+			base = e.Infobase
+		}
+
+		switch e.Event {
+		case eventlog.EventSessionStart:
+			exp.sessions.WithLabelValues(exp.host, base, "start").Inc()
+		case eventlog.EventSessionFinish:
+			exp.sessions.WithLabelValues(exp.host, base, "finish").Inc()
+		case eventlog.EventJobFail:
+			exp.jobs.WithLabelValues(exp.host, base, e.Metadata).Inc()
+		}
+
+		severity, ok := severityLabels[e.Severity]
+		if !ok {
+			continue
+		}
+
+		exp.events.WithLabelValues(exp.host, base, severity, e.Event).Inc()
+		exp.users.WithLabelValues(exp.host, base, severity, e.User).Inc()
+		exp.metadata.WithLabelValues(exp.host, base, severity, e.Metadata).Inc()
+	}
+
+	return err
+}
+
+func (exp *ExporterEventLog) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "EventLog.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterEventLog) GetName() string {
+	return "event_log"
+}
+
+func (exp *ExporterEventLog) GetType() model.MetricType {
+	return model.TypeOS
+}
+
+// defaultSrvInfo каталог srvinfo при установке платформы по умолчанию
+func defaultSrvInfo() string {
+	if runtime.GOOS == "windows" {
+		return `C:\Program Files\1cv8\srvinfo`
+	}
+
+	return "/home/usr1cv8/.1cv8/1C/1cv8"
+}
@@ -0,0 +1,207 @@
+package exporter
+
+import (
+	"runtime/trace"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// свойства rac infobase info, которые публикуются по умолчанию
+var defaultInfobaseProperties = []string{
+	"sessions-deny",
+	"scheduled-jobs-deny",
+	"denied-from",
+	"denied-to",
+	"permission-code",
+	"license-distribution",
+	"security-level",
+	"dbms",
+	"db-server",
+	"external-session-manager-required",
+	"reserve-working-processes",
+}
+
+// строковые свойства публикуются метками метрики infobase_state_info, остальные - значениями метрики infobase_state
+var infobaseInfoProperties = []string{
+	"name",
+	"descr",
+	"dbms",
+	"db-server",
+	"db-name",
+	"db-user",
+	"locale",
+	"denied-message",
+	"denied-parameter",
+	"external-session-manager-connection-string",
+	"security-profile-name",
+	"safe-mode-security-profile-name",
+}
+
+// секретные свойства: публикуется только признак заполненности
+var infobaseSecretProperties = []string{"permission-code", "db-pwd"}
+
+// ExporterInfobaseState состояние информационных баз (rac infobase info)
+type ExporterInfobaseState struct {
+	ExporterCheckSheduleJob
+
+	properties []string
+	infoLabels []string
+	state      *prometheus.GaugeVec
+	info       *prometheus.GaugeVec
+}
+
+func (exp *ExporterInfobaseState) Construct(s *settings.Settings) *ExporterInfobaseState {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	exp.properties = s.GetPropertyStrings(exp.GetName(), "Properties")
+	if len(exp.properties) == 0 {
+		exp.properties = defaultInfobaseProperties
+	}
+
+	var labels []string
+	for _, p := range exp.properties {
+		if slices.Contains(infobaseInfoProperties, p) {
+			exp.infoLabels = append(exp.infoLabels, p)
+			labels = append(labels, strings.ReplaceAll(p, "-", "_"))
+		}
+	}
+
+	labelName := s.GetMetricNamePrefix() + exp.GetName()
+	exp.state = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: labelName,
+			Help: "Состояние информационной базы: on/yes/allow - 1, off/no/deny - 0, даты (denied-from, denied-to) - unix time, " +
+				"permission-code - 1 если код задан, sessions-deny-active - 1 если блокировка сеансов действует сейчас",
+		},
+		withClusterLabels("base", "property"),
+	)
+	exp.info = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: labelName + "_info",
+			Help: "Строковые свойства информационной базы, значение всегда 1",
+		},
+		withClusterLabels(append([]string{"base"}, labels...)...),
+	)
+	exp.collectors = []prometheus.Collector{exp.state, exp.info}
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+	exp.ExporterCheckSheduleJob.settings = s
+
+	return exp
+}
+
+func (exp *ExporterInfobaseState) getValue() error {
+	defer trace.StartRegion(exp.ctx, "InfobaseState.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	data, err := exp.getData()
+	exp.resetMetrics()
+	if err != nil {
+		exp.logger.Error(err)
+		return err
+	}
+
+	for _, db := range data {
+		for _, p := range exp.properties {
+			if slices.Contains(exp.infoLabels, p) {
+				continue
+			}
+
+			if v, ok := infobaseValue(p, db.info[p]); ok {
+				exp.state.WithLabelValues(db.cluster.with(db.name, p)...).Set(v)
+			}
+		}
+
+		// блокировка сеансов действует, если установлена и текущее время попадает в интервал (пустая граница - без ограничения)
+		if slices.Contains(exp.properties, "sessions-deny") {
+			exp.state.WithLabelValues(db.cluster.with(db.name, "sessions-deny-active")...).Set(boolValue(sessionsDenyActive(db.info, time.Now())))
+		}
+
+		if len(exp.infoLabels) > 0 {
+			lvs := []string{db.name}
+			for _, p := range exp.infoLabels {
+				lvs = append(lvs, strings.Trim(db.info[p], "\""))
+			}
+			exp.info.WithLabelValues(db.cluster.with(lvs...)...).Set(1)
+		}
+	}
+
+	return nil
+}
+
+// infobaseValue числовое представление свойства базы
+func infobaseValue(property, v string) (float64, bool) {
+	v = strings.Trim(v, "\"")
+
+	if slices.Contains(infobaseSecretProperties, property) {
+		return boolValue(v != ""), true
+	}
+
+	// незаданная граница блокировки публикуется нулем
+	if property == "denied-from" || property == "denied-to" {
+		if t, ok := infobaseTime(v); ok {
+			return float64(t.Unix()), true
+		}
+		return 0, true
+	}
+
+	switch strings.ToLower(v) {
+	case "on", "yes", "allow":
+		return 1, true
+	case "off", "no", "deny":
+		return 0, true
+	}
+
+	if t, ok := infobaseTime(v); ok {
+		return float64(t.Unix()), true
+	}
+
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return n, true
+	}
+
+	return 0, false
+}
+
+func sessionsDenyActive(info map[string]string, now time.Time) bool {
+	if strings.ToLower(info["sessions-deny"]) != "on" {
+		return false
+	}
+
+	if from, ok := infobaseTime(info["denied-from"]); ok && now.Before(from) {
+		return false
+	}
+	if to, ok := infobaseTime(info["denied-to"]); ok && now.After(to) {
+		return false
+	}
+
+	return true
+}
+
+func infobaseTime(v string) (time.Time, bool) {
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", v, time.Local)
+	// rac выводит незаданную дату как 0001-01-01T00:00:00
+	return t, err == nil && t.Year() > 1
+}
+
+func (exp *ExporterInfobaseState) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "InfobaseState.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterInfobaseState) GetName() string {
+	return "infobase_state"
+}
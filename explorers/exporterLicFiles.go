@@ -0,0 +1,433 @@
+package exporter
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/trace"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// изменения файлов лицензий между сканированиями, значения метки change
+const (
+	licFileAdded    = "added"
+	licFileRemoved  = "removed"
+	licFileModified = "modified"
+)
+
+// LicInfo сведения о программной лицензии
+type LicInfo struct {
+	Series       string // регистрационный номер
+	Product      string
+	Users        int64
+	Restrictions string // ограничения (платформа, ОС и т.д.)
+}
+
+// повторный запрос сведений о лицензии после ошибки, ring запускает JVM, поэтому не чаще чем раз в час
+const licInspectRetry = time.Hour
+
+// ILicInspector получение сведений о лицензиях каталога. Файл лицензии зашифрован, поэтому сведения
+// получаются утилитой лицензирования (ring)
+type ILicInspector interface {
+	// List имена лицензий каталога (pin-регистрационный номер), ключ - имя файла
+	List(dir string) (map[string]string, error)
+	Inspect(dir, name string) (LicInfo, error)
+}
+
+type licFile struct {
+	dir, name string
+	hash      string
+	info      LicInfo
+	inspected bool      // сведения получены
+	checkedAt time.Time // время запроса сведений, при ошибке повторный запрос через licInspectRetry
+}
+
+// ExporterLicFiles файлы программных лицензий в каталогах лицензий: сведения о лицензиях, количество пользователей
+// и изменения файлов (добавлен, удален, изменен) с прошлого сканирования
+type ExporterLicFiles struct {
+	BaseExporter
+
+	dirs      []string
+	inspector ILicInspector
+	filesMx   sync.Mutex
+	files     map[string]*licFile // ключ - полный путь к файлу, nil - сканирования еще не было
+
+	info     *prometheus.GaugeVec
+	capacity *prometheus.GaugeVec
+	changed  *prometheus.GaugeVec
+	changes  *prometheus.CounterVec
+}
+
+func (exp *ExporterLicFiles) Construct(s *settings.Settings) *ExporterLicFiles {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	name := s.GetMetricNamePrefix() + exp.GetName()
+	exp.info = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_info",
+			Help: "Файлы программных лицензий, значение - количество пользователей лицензии (0 - если не удалось определить)",
+		},
+		[]string{"dir", "file", "series", "product", "restrictions"},
+	)
+	exp.capacity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_capacity",
+			Help: "Количество пользователей программных лицензий серии по всем файлам",
+		},
+		[]string{"series", "product"},
+	)
+	exp.changed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_changed",
+			Help: "Файлы лицензий, измененные при последнем изменении состава: added, removed, modified (держится до следующего изменения)",
+		},
+		[]string{"dir", "file", "change"},
+	)
+	exp.changes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: name + "_changes_total",
+			Help: "Количество изменений файлов лицензий",
+		},
+		[]string{"dir", "change"},
+	)
+	exp.collectors = []prometheus.Collector{exp.info, exp.capacity, exp.changed, exp.changes}
+
+	exp.dirs = s.GetPropertyStrings(exp.GetName(), "Dirs")
+	if len(exp.dirs) == 0 {
+		exp.dirs = defaultLicDirs()
+	}
+	ring := s.GetPropertyStrings(exp.GetName(), "Ring")
+	if len(ring) == 0 {
+		ring = append(ring, findRing())
+	}
+	if ring[0] != "" {
+		exp.logger.Infof("сведения о лицензиях получаются утилитой %s", ring[0])
+		exp.inspector = &ringInspector{path: ring[0], timeout: s.GetPropertyDuration(exp.GetName(), "Timeout", time.Second*30)}
+	} else {
+		exp.logger.Warn("утилита лицензирования ring не найдена, сведения о лицензиях (регистрационный номер, продукт, количество пользователей) " +
+			"получены не будут, отдается только состав файлов. Путь к ring задается свойством Ring")
+	}
+
+	exp.settings = s
+	return exp
+}
+
+// defaultLicDirs каталоги программных лицензий платформы
+func defaultLicDirs() []string {
+	if runtime.GOOS == "windows" {
+		return []string{filepath.Join(os.Getenv("ProgramData"), "1C", "licenses")}
+	}
+
+	return []string{"/var/1C/licenses"}
+}
+
+// ringGlobs стандартные пути установки утилиты лицензирования ring (в имени каталога версия компонента)
+var ringGlobs = func() []string {
+	if runtime.GOOS == "windows" {
+		return []string{
+			filepath.Join(os.Getenv("ProgramFiles"), "1C", "1CE", "components", "1c-enterprise-ring-*", "ring.cmd"),
+			filepath.Join(os.Getenv("ProgramFiles(x86)"), "1C", "1CE", "components", "1c-enterprise-ring-*", "ring.cmd"),
+		}
+	}
+
+	return []string{"/opt/1C/1CE/components/1c-enterprise-ring-*/ring"}
+}()
+
+// findRing путь к утилите ring: последняя версия из стандартного каталога установки или ring из PATH
+func findRing() string {
+	for _, pattern := range ringGlobs {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return slices.MaxFunc(matches, compareVersions)
+		}
+	}
+
+	if path, err := exec.LookPath("ring"); err == nil {
+		return path
+	}
+
+	return ""
+}
+
+var reDigits = regexp.MustCompile(`\d+`)
+
+// compareVersions сравнение строк с номером версии по числам, что бы 0.11 было больше 0.9
+func compareVersions(a, b string) int {
+	va, vb := reDigits.FindAllString(a, -1), reDigits.FindAllString(b, -1)
+	for i := 0; i < min(len(va), len(vb)); i++ {
+		na, _ := strconv.ParseInt(va[i], 10, 64)
+		nb, _ := strconv.ParseInt(vb[i], 10, 64)
+		if c := cmp.Compare(na, nb); c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(va), len(vb))
+}
+
+func (exp *ExporterLicFiles) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "LicFiles.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	// экспортер отдается и в /metrics, и в /metrics_os, сравнение с прошлым сканированием должно выполняться по очереди
+	exp.filesMx.Lock()
+	defer exp.filesMx.Unlock()
+
+	current := map[string]*licFile{}
+	for _, dir := range exp.dirs {
+		if e := exp.scan(dir, current); e != nil {
+			exp.logger.Error(e)
+			err = e
+
+			// недоступный каталог не считаем удалением файлов, оставляем результат прошлого сканирования
+			for path, f := range exp.files {
+				if f.dir == dir {
+					current[path] = f
+				}
+			}
+		}
+	}
+
+	type change struct {
+		file   *licFile
+		change string
+	}
+
+	var changes []change
+	if exp.files != nil {
+		for path, f := range current {
+			if prev, ok := exp.files[path]; !ok {
+				changes = append(changes, change{f, licFileAdded})
+			} else if prev.hash != f.hash {
+				changes = append(changes, change{f, licFileModified})
+			}
+		}
+		for path, f := range exp.files {
+			if _, ok := current[path]; !ok {
+				changes = append(changes, change{f, licFileRemoved})
+			}
+		}
+	}
+	exp.files = current
+
+	// признаки изменений держатся до следующего изменения, а не до следующего сканирования: экспортер отдается и в /metrics,
+	// и в /metrics_os, и запрос по одному адресу не должен забирать изменения у другого
+	if len(changes) > 0 {
+		exp.changed.Reset()
+		for _, c := range changes {
+			exp.change(c.file, c.change)
+		}
+	}
+
+	exp.info.Reset()
+	exp.capacity.Reset()
+	for _, f := range current {
+		exp.info.WithLabelValues(f.dir, f.name, f.info.Series, f.info.Product, f.info.Restrictions).Set(float64(f.info.Users))
+		if f.info.Series != "" {
+			exp.capacity.WithLabelValues(f.info.Series, f.info.Product).Add(float64(f.info.Users))
+		}
+	}
+
+	return err
+}
+
+func (exp *ExporterLicFiles) change(f *licFile, change string) {
+	exp.logger.With("dir", f.dir, "file", f.name, "change", change).Warn("изменен состав файлов лицензий")
+	exp.changed.WithLabelValues(f.dir, f.name, change).Set(1)
+	exp.changes.WithLabelValues(f.dir, change).Inc()
+}
+
+func (exp *ExporterLicFiles) scan(dir string, result map[string]*licFile) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "каталог лицензий %q недоступен", dir)
+	}
+
+	var pending []*licFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".lic") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			exp.logger.Error(errors.Wrapf(err, "ошибка чтения файла лицензии %q", path))
+			continue
+		}
+
+		sum := sha256.Sum256(data)
+		f := &licFile{dir: dir, name: entry.Name(), hash: hex.EncodeToString(sum[:])}
+
+		// сведения о лицензии запрашиваются только для новых и измененных файлов, после ошибки - не чаще licInspectRetry
+		if prev, ok := exp.files[path]; ok && prev.hash == f.hash && (prev.inspected || exp.inspector == nil || time.Since(prev.checkedAt) < licInspectRetry) {
+			f.info, f.inspected, f.checkedAt = prev.info, prev.inspected, prev.checkedAt
+		} else if exp.inspector != nil {
+			pending = append(pending, f)
+		}
+
+		result[path] = f
+	}
+
+	if len(pending) > 0 {
+		exp.inspect(dir, pending)
+	}
+
+	return nil
+}
+
+// inspect сведения о лицензиях файлов каталога: список лицензий каталога запрашивается один раз, затем сведения по каждой лицензии
+func (exp *ExporterLicFiles) inspect(dir string, files []*licFile) {
+	names, listErr := exp.inspector.List(dir)
+	if listErr != nil {
+		exp.logger.Error(errors.Wrapf(listErr, "не удалось получить список лицензий каталога %q", dir))
+	}
+
+	for _, f := range files {
+		f.checkedAt = time.Now()
+		if listErr != nil {
+			continue
+		}
+
+		name, ok := names[f.name]
+		if !ok {
+			exp.logger.Errorf("лицензия файла %q не найдена в списке лицензий каталога %q", f.name, dir)
+			continue
+		}
+
+		info, err := exp.inspector.Inspect(dir, name)
+		if err != nil {
+			exp.logger.Error(errors.Wrapf(err, "не удалось получить сведения о лицензии %q", name))
+			continue
+		}
+
+		f.info, f.inspected = info, true
+	}
+}
+
+func (exp *ExporterLicFiles) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "LicFiles.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterLicFiles) GetName() string {
+	return "lic_files"
+}
+
+func (exp *ExporterLicFiles) GetType() model.MetricType {
+	return model.TypeOS
+}
+
+// ringInspector сведения о лицензиях утилитой ring: ring license list --path <каталог> и ring license info --name <лицензия> --path <каталог>
+type ringInspector struct {
+	path    string
+	timeout time.Duration
+}
+
+var (
+	reLicUsers = regexp.MustCompile(`(?i)(\d+)\s*(рабоч|польз|user|seat)`)
+	// строка списка лицензий: 018106628354391-800017327 (file name: "018106628354391-800017327.lic")
+	reLicListItem = regexp.MustCompile(`(?i)^\s*(\S+)\s*\((?:file name|имя файла):\s*"?([^")]+)"?\)`)
+)
+
+func (r *ringInspector) List(dir string) (map[string]string, error) {
+	out, err := r.run("license", "list", "--path", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLicList(out), nil
+}
+
+func (r *ringInspector) Inspect(dir, name string) (LicInfo, error) {
+	out, err := r.run("license", "info", "--name", name, "--path", dir)
+	if err != nil {
+		return LicInfo{}, err
+	}
+
+	return parseLicInfo(out), nil
+}
+
+func (r *ringInspector) run(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, r.path, args...).CombinedOutput()
+	if err != nil {
+		return "", errors.Wrap(err, strings.TrimSpace(string(out)))
+	}
+
+	return string(out), nil
+}
+
+// parseLicList разбор вывода ring license list, ключ - имя файла, значение - имя лицензии
+func parseLicList(out string) map[string]string {
+	result := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if m := reLicListItem.FindStringSubmatch(scanner.Text()); m != nil {
+			result[strings.TrimSpace(m[2])] = m[1]
+		}
+	}
+
+	return result
+}
+
+// parseLicInfo разбор вывода утилиты лицензирования вида "Поле: значение", названия полей зависят от языка утилиты
+func parseLicInfo(out string) LicInfo {
+	var info LicInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.Trim(strings.TrimSpace(value), "\"")
+		switch {
+		case strings.Contains(key, "регистрационный номер"), strings.Contains(key, "registration number"), key == "series", key == "серия":
+			info.Series = value
+		case strings.Contains(key, "продукт"), strings.Contains(key, "product"):
+			info.Product = value
+		case strings.Contains(key, "ограничени"), strings.Contains(key, "restriction"):
+			info.Restrictions = value
+		case strings.Contains(key, "количество"), strings.Contains(key, "users"):
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				info.Users = n
+			}
+		}
+	}
+
+	// количество пользователей обычно указано только в названии продукта: "Клиентская лицензия на 50 рабочих мест"
+	if info.Users == 0 {
+		if m := reLicUsers.FindStringSubmatch(info.Product); m != nil {
+			info.Users, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+	}
+
+	return info
+}
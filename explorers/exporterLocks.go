@@ -0,0 +1,163 @@
+package exporter
+
+import (
+	"runtime/trace"
+	"strings"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ExporterLocks управляемые блокировки кластера (rac lock list)
+type ExporterLocks struct {
+	ExporterSessions
+
+	count   *prometheus.GaugeVec
+	holders *prometheus.GaugeVec
+	oldest  *prometheus.GaugeVec
+}
+
+type lockKey struct {
+	base, typ, mode string
+}
+
+type holderKey struct {
+	base, id, user string
+}
+
+func (exp *ExporterLocks) Construct(s *settings.Settings) *ExporterLocks {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	labelName := s.GetMetricNamePrefix() + exp.GetName()
+	exp.count = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: labelName,
+			Help: "Количество управляемых блокировок в разрезе базы, типа и режима блокировки",
+		},
+		withClusterLabels("base", "type", "mode"),
+	)
+	// метка id - номер сеанса, как в sessions_data, по ней метрики можно соединить
+	exp.holders = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: labelName + "_session",
+			Help: "Количество управляемых блокировок, удерживаемых сеансом",
+		},
+		withClusterLabels("base", "id", "user"),
+	)
+	exp.oldest = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: labelName + "_oldest_seconds",
+			Help: "Время удержания самой старой блокировки базы (в секундах)",
+		},
+		withClusterLabels("base"),
+	)
+	exp.collectors = []prometheus.Collector{exp.count, exp.holders, exp.oldest}
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+	exp.ExporterCheckSheduleJob.settings = s
+
+	return exp
+}
+
+func (exp *ExporterLocks) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "Locks.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		if e := exp.collectLocks(cl); e != nil {
+			exp.logger.Error(errors.Wrap(e, "get locks error"))
+			err = e
+		}
+	}
+
+	return err
+}
+
+func (exp *ExporterLocks) collectLocks(cl *clusterInfo) error {
+	locks, err := exp.query(cl, "lock", "list")
+	if err != nil {
+		return err
+	}
+	if len(locks) == 0 {
+		return nil
+	}
+
+	// в блокировке есть только идентификатор сеанса, база и номер сеанса берутся из списка сеансов
+	ses, err := exp.getSessions(cl)
+	if err != nil {
+		return err
+	}
+
+	sessions := make(map[string]map[string]string, len(ses))
+	for _, item := range ses {
+		sessions[item["session"]] = item
+	}
+
+	count := map[lockKey]int{}
+	holders := map[holderKey]int{}
+	oldest := map[string]time.Time{}
+	for _, lock := range locks {
+		session := sessions[lock["session"]]
+		base := exp.findBaseName(session["infobase"])
+		typ, mode := lockType(strings.Trim(lock["descr"], "\""))
+
+		count[lockKey{base: base, typ: typ, mode: mode}]++
+		if session != nil {
+			holders[holderKey{base: base, id: session["session-id"], user: session["user-name"]}]++
+		}
+
+		if locked, err := time.ParseInLocation("2006-01-02T15:04:05", lock["locked"], time.Local); err == nil {
+			if v, ok := oldest[base]; !ok || locked.Before(v) {
+				oldest[base] = locked
+			}
+		}
+	}
+
+	for k, v := range count {
+		exp.count.WithLabelValues(cl.with(k.base, k.typ, k.mode)...).Set(float64(v))
+	}
+	for k, v := range holders {
+		exp.holders.WithLabelValues(cl.with(k.base, k.id, k.user)...).Set(float64(v))
+	}
+	for base, locked := range oldest {
+		exp.oldest.WithLabelValues(cl.with(base)...).Set(max(time.Since(locked).Seconds(), 0))
+	}
+
+	return nil
+}
+
+// lockType тип (то, что перед скобкой: БД, Сеанс...) и режим блокировки из описания вида "БД(сеанс ,Справочник.Контрагенты,Разделяемая)"
+func lockType(descr string) (string, string) {
+	typ, _, _ := strings.Cut(descr, "(")
+	typ = strings.TrimSpace(typ)
+
+	lower := strings.ToLower(descr)
+	switch {
+	case strings.Contains(lower, "shared") || strings.Contains(lower, "разделяем"):
+		return typ, "shared"
+	case strings.Contains(lower, "exclusive") || strings.Contains(lower, "исключительн"):
+		return typ, "exclusive"
+	default:
+		return typ, "other"
+	}
+}
+
+func (exp *ExporterLocks) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "Locks.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterLocks) GetName() string {
+	return "locks"
+}
@@ -26,22 +26,14 @@ func (cpu *Processes) Construct(s *settings.Settings) *Processes {
 	cpu.BaseExporter = newBase(cpu.GetName())
 	cpu.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + cpu.GetName()
-	cpu.summary = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:       labelName,
-			Help:       "Метрики CPU/памяти в разрезе процессов",
-			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-		},
-		[]string{"host", "pid", "procName", "metrics"},
-	)
+	cpu.initMetrics(s, cpu.GetName(), "Метрики CPU/памяти в разрезе процессов", []string{"host", "pid", "procName", "metrics"})
 
 	cpu.hInfo = new(hardwareInfo)
 	cpu.settings = s
 	return cpu
 }
 
-func (cpu *Processes) getValue() {
+func (cpu *Processes) getValue() error {
 	defer trace.StartRegion(cpu.ctx, "Processes.getValue").End()
 
 	cpu.logger.Info("получение данных экспортера")
@@ -49,10 +41,10 @@ func (cpu *Processes) getValue() {
 	processes, err := cpu.hInfo.Processes()
 	if err != nil {
 		cpu.logger.Error(errors.Wrap(err, "get processes error"))
-		return
+		return err
 	}
 
-	cpu.summary.Reset()
+	cpu.resetMetrics()
 	for _, p := range processes {
 		var memInfo process.MemoryInfoStat
 
@@ -63,12 +55,14 @@ func (cpu *Processes) getValue() {
 		}
 
 		if procName, err := p.Name(); err == nil {
-			cpu.summary.WithLabelValues(cpu.host, strconv.Itoa(int(p.Pid)), procName, "cpu").Observe(cpuPercent)
-			cpu.summary.WithLabelValues(cpu.host, strconv.Itoa(int(p.Pid)), procName, "memoryPercent").Observe(float64(memPercent))
-			cpu.summary.WithLabelValues(cpu.host, strconv.Itoa(int(p.Pid)), procName, "memoryRSS").Observe(float64(memInfo.RSS))
-			cpu.summary.WithLabelValues(cpu.host, strconv.Itoa(int(p.Pid)), procName, "memoryVMS").Observe(float64(memInfo.VMS))
+			cpu.observe(cpuPercent, cpu.host, strconv.Itoa(int(p.Pid)), procName, "cpu")
+			cpu.observe(float64(memPercent), cpu.host, strconv.Itoa(int(p.Pid)), procName, "memoryPercent")
+			cpu.observe(float64(memInfo.RSS), cpu.host, strconv.Itoa(int(p.Pid)), procName, "memoryRSS")
+			cpu.observe(float64(memInfo.VMS), cpu.host, strconv.Itoa(int(p.Pid)), procName, "memoryVMS")
 		}
 	}
+
+	return nil
 }
 
 func (cpu *Processes) Collect(ch chan<- prometheus.Metric) {
@@ -78,8 +72,7 @@ func (cpu *Processes) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	cpu.getValue()
-	cpu.summary.Collect(ch)
+	cpu.collect(ch, cpu.getValue)
 }
 
 func (cpu *Processes) GetName() string {
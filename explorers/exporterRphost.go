@@ -0,0 +1,186 @@
+package exporter
+
+import (
+	"runtime/trace"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ExporterRphost состояние рабочих процессов кластера (rac process list) с учетом перезапусков
+type ExporterRphost struct {
+	ExporterAvailablePerformance
+
+	values         *prometheus.GaugeVec
+	startedAt      *prometheus.GaugeVec
+	restarts       *prometheus.CounterVec
+	memoryRecycles *prometheus.CounterVec
+
+	// процессы прошлого сбора в разрезе кластера и хоста
+	known map[string]map[processID]processState
+}
+
+// processID процесс идентифицируется по pid и времени запуска, pid может быть переиспользован ОС
+type processID struct {
+	pid, startedAt string
+}
+
+type processState struct {
+	// процесс превышал допустимый объем памяти (max-memory-size кластера)
+	memoryExcess bool
+}
+
+func (exp *ExporterRphost) Construct(s *settings.Settings) *ExporterRphost {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	labelName := s.GetMetricNamePrefix() + exp.GetName()
+	exp.values = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: labelName,
+			Help: "Показатели рабочих процессов: memorysize, connections, selectionsize, enable, running, use, reserve, turnoff",
+		},
+		withClusterLabels("host", "pid", "port", "type"),
+	)
+	exp.startedAt = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: labelName + "_started_at_seconds",
+			Help: "Время запуска рабочего процесса (unix time)",
+		},
+		withClusterLabels("host", "pid", "port"),
+	)
+	exp.restarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: labelName + "_restarts_total",
+			Help: "Количество перезапусков рабочих процессов",
+		},
+		withClusterLabels("host"),
+	)
+	exp.memoryRecycles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: labelName + "_memory_recycles_total",
+			Help: "Количество рабочих процессов, перезапущенных из-за превышения допустимого объема памяти",
+		},
+		withClusterLabels("host"),
+	)
+	exp.collectors = []prometheus.Collector{exp.values, exp.startedAt, exp.restarts, exp.memoryRecycles}
+	exp.known = map[string]map[processID]processState{}
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+	return exp
+}
+
+func (exp *ExporterRphost) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "Rphost.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	// счетчики перезапусков накопительные, сбрасываются только текущие показатели
+	exp.values.Reset()
+	exp.startedAt.Reset()
+
+	for _, cl := range exp.GetClusters() {
+		procData, e := exp.readData(cl)
+		if e != nil {
+			err = e
+			continue
+		}
+
+		exp.logger.Debugf("Количество процессов: %d", len(procData))
+		current := map[string]map[processID]processState{}
+		for _, item := range procData {
+			host, pid, port := item["host"], item["pid"], item["port"]
+
+			set := func(typ string, v float64) {
+				exp.values.WithLabelValues(cl.with(host, pid, port, typ)...).Set(v)
+			}
+
+			set("memorysize", float64(atoi(item["memory-size"])))
+			set("connections", float64(atoi(item["connections"])))
+			set("selectionsize", float64(atoi(item["selection-size"])))
+			set("enable", boolValue(item["is-enable"] == "yes"))
+			set("running", boolValue(item["running"] == "yes"))
+			set("use", boolValue(item["use"] == "used" || item["use"] == "used-as-reserve"))
+			set("reserve", boolValue(item["use"] == "used-as-reserve" || item["reserve"] == "yes"))
+			// процесс выключается: еще работает, но новые соединения на него не назначаются
+			set("turnoff", boolValue(item["running"] == "yes" && item["is-enable"] == "no"))
+
+			startedAt, e := time.ParseInLocation("2006-01-02T15:04:05", item["started-at"], time.Local)
+			if e == nil {
+				exp.startedAt.WithLabelValues(cl.with(host, pid, port)...).Set(float64(startedAt.Unix()))
+			}
+
+			key := cl.id + "/" + host
+			if current[key] == nil {
+				current[key] = map[processID]processState{}
+			}
+			current[key][processID{pid: pid, startedAt: item["started-at"]}] = processState{memoryExcess: atoi(item["memory-excess-time"]) > 0}
+		}
+
+		exp.detectRestarts(cl, current)
+	}
+
+	return err
+}
+
+// detectRestarts сравнивает процессы с прошлым сбором: процесс, замененный новым на том же хосте, считается перезапущенным
+func (exp *ExporterRphost) detectRestarts(cl *clusterInfo, current map[string]map[processID]processState) {
+	for key, processes := range current {
+		host := key[len(cl.id)+1:]
+
+		previous, ok := exp.known[key]
+		exp.known[key] = processes
+		if !ok {
+			continue // первый сбор по хосту
+		}
+
+		var started, stopped int
+		var recycled []processID
+		for id := range processes {
+			if _, ok := previous[id]; !ok {
+				started++
+			}
+		}
+		for id, state := range previous {
+			if _, ok := processes[id]; !ok {
+				stopped++
+				if state.memoryExcess {
+					recycled = append(recycled, id)
+				}
+			}
+		}
+
+		if restarts := min(started, stopped); restarts > 0 {
+			exp.restarts.WithLabelValues(cl.with(host)...).Add(float64(restarts))
+		}
+
+		for _, id := range recycled {
+			exp.logger.With("host", host, "pid", id.pid, "started-at", id.startedAt).Warn("рабочий процесс перезапущен из-за превышения допустимого объема памяти")
+			exp.memoryRecycles.WithLabelValues(cl.with(host)...).Inc()
+		}
+	}
+}
+
+func (exp *ExporterRphost) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "Rphost.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterRphost) GetName() string {
+	return "rphost"
+}
+
+func boolValue(v bool) float64 {
+	if v {
+		return 1
+	}
+
+	return 0
+}
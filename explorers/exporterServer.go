@@ -0,0 +1,103 @@
+package exporter
+
+import (
+	"runtime/trace"
+	"strings"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// serverLimits поля rac server list, которые отдаются как показатели, и значения метки type
+var serverLimits = map[string]string{
+	"temporary-allowed-total-memory":            "temporary_allowed_total_memory",
+	"temporary-allowed-total-memory-time-limit": "temporary_allowed_total_memory_time_limit",
+	"critical-total-memory":                     "critical_total_memory",
+	"safe-working-processes-memory-limit":       "safe_working_processes_memory_limit",
+	"safe-call-memory-limit":                    "safe_call_memory_limit",
+	"memory-limit":                              "memory_limit",
+	"infobases-limit":                           "infobases_per_process",
+	"connections-limit":                         "connections_per_process",
+	"cluster-port":                              "cluster_port",
+}
+
+// ExporterServer настройки рабочих серверов кластера (rac server list)
+type ExporterServer struct {
+	BaseRACExporter
+
+	limits *prometheus.GaugeVec
+}
+
+func (exp *ExporterServer) Construct(s *settings.Settings) *ExporterServer {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	// метка host совпадает с host рабочих процессов (rphost), что бы лимиты можно было сравнить с памятью процессов
+	exp.limits = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: s.GetMetricNamePrefix() + exp.GetName(),
+			Help: "Настройки рабочих серверов: лимиты памяти (в байтах), количество баз и соединений на процесс, диапазон портов",
+		},
+		withClusterLabels("host", "server", "type"),
+	)
+	exp.collectors = []prometheus.Collector{exp.limits}
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+	return exp
+}
+
+func (exp *ExporterServer) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "Server.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		servers, e := exp.query(cl, "server", "list")
+		if e != nil {
+			exp.logger.Error(errors.Wrap(e, "get servers error"))
+			err = e
+			continue
+		}
+
+		exp.logger.Debugf("Количество серверов: %d", len(servers))
+		for _, item := range servers {
+			host, name := item["agent-host"], strings.Trim(item["name"], "\"")
+
+			for field, typ := range serverLimits {
+				if v, ok := item[field]; ok {
+					exp.limits.WithLabelValues(cl.with(host, name, typ)...).Set(float64(atoi(v)))
+				}
+			}
+
+			// диапазон портов рабочих процессов в виде 1560:1591
+			if from, to, ok := strings.Cut(item["port-range"], ":"); ok {
+				exp.limits.WithLabelValues(cl.with(host, name, "port_range_start")...).Set(float64(atoi(from)))
+				exp.limits.WithLabelValues(cl.with(host, name, "port_range_end")...).Set(float64(atoi(to)))
+			}
+		}
+	}
+
+	return err
+}
+
+func (exp *ExporterServer) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "Server.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterServer) GetName() string {
+	return "server"
+}
+
+func (exp *ExporterServer) GetType() model.MetricType {
+	return model.TypeRAC
+}
@@ -0,0 +1,243 @@
+package exporter
+
+import (
+	"cmp"
+	"runtime/trace"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sessionTopResources показатели rac session list, по которым отбираются самые ресурсоемкие сеансы, и значения метки resource
+var sessionTopResources = map[string]string{
+	"memory-current":   "memory_current",
+	"cpu-time-current": "cpu_time_current",
+}
+
+// ExporterSessionAnalytics производные показатели сеансов (rac session list): возраст сеансов, спящие, заблокированные
+// и долгие вызовы в разрезе хоста и базы, а так же несколько самых ресурсоемких сеансов кластера
+type ExporterSessionAnalytics struct {
+	ExporterSessions
+
+	longCall time.Duration // длительность текущего вызова, после которой он считается долгим
+	topN     int
+
+	age        *ageHistogram
+	hibernated *prometheus.GaugeVec
+	blocked    *prometheus.GaugeVec
+	longCalls  *prometheus.GaugeVec
+	top        *prometheus.GaugeVec
+}
+
+func (exp *ExporterSessionAnalytics) Construct(s *settings.Settings) *ExporterSessionAnalytics {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	exp.longCall = s.GetPropertyDuration(exp.GetName(), "LongCall", time.Minute)
+	exp.topN = s.GetPropertyInt(exp.GetName(), "TopN", 5)
+
+	buckets := s.GetHistogramBuckets(exp.GetName())
+	if len(buckets) == 0 {
+		// от минуты до суток
+		buckets = []float64{60, 300, 900, 3600, 4 * 3600, 8 * 3600, 24 * 3600}
+	}
+
+	name := s.GetMetricNamePrefix() + exp.GetName()
+	exp.age = newAgeHistogram(prometheus.NewDesc(
+		name+"_age_seconds",
+		"Распределение возраста текущих сеансов (от started-at), в секундах. Снимок на момент сбора, не накопительная гистограмма",
+		withClusterLabels("host", "base"), nil,
+	), buckets)
+	exp.hibernated = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_hibernated",
+			Help: "Количество спящих сеансов",
+		},
+		withClusterLabels("host", "base"),
+	)
+	exp.blocked = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_blocked",
+			Help: "Количество сеансов, ожидающих блокировку: by=dbms - блокировка СУБД (blocked-by-dbms), by=ls - управляемая блокировка (blocked-by-ls)",
+		},
+		withClusterLabels("host", "base", "by"),
+	)
+	exp.longCalls = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_long_calls",
+			Help: "Количество сеансов, текущий вызов которых выполняется дольше LongCall",
+		},
+		withClusterLabels("host", "base"),
+	)
+	exp.top = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_top",
+			Help: "Самые ресурсоемкие сеансы кластера (TopN по каждому показателю resource): memory_current - байт, cpu_time_current - мс",
+		},
+		withClusterLabels("host", "base", "user", "app_id", "id", "resource"),
+	)
+	exp.collectors = []prometheus.Collector{exp.age, exp.hibernated, exp.blocked, exp.longCalls, exp.top}
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+	exp.ExporterCheckSheduleJob.settings = s
+
+	return exp
+}
+
+func (exp *ExporterSessionAnalytics) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "SessionAnalytics.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	exp.resetMetrics()
+	for _, cl := range exp.GetClusters() {
+		ses, e := exp.getSessions(cl)
+		if e != nil {
+			exp.logger.Error(errors.Wrap(e, "getSessions error"))
+			err = e
+			continue
+		}
+
+		exp.observeSessions(cl, ses, time.Now())
+	}
+
+	return err
+}
+
+func (exp *ExporterSessionAnalytics) observeSessions(cl *clusterInfo, ses []map[string]string, now time.Time) {
+	for _, item := range ses {
+		host, base := item["host"], exp.findBaseName(item["infobase"])
+
+		// метрики-счетчики создаются для всех хостов и баз с сеансами, что бы отсутствие проблем отдавалось нулем
+		hibernated := exp.hibernated.WithLabelValues(cl.with(host, base)...)
+		longCalls := exp.longCalls.WithLabelValues(cl.with(host, base)...)
+		blockedDBMS := exp.blocked.WithLabelValues(cl.with(host, base, "dbms")...)
+		blockedLS := exp.blocked.WithLabelValues(cl.with(host, base, "ls")...)
+
+		if startedAt, e := time.ParseInLocation("2006-01-02T15:04:05", item["started-at"], time.Local); e == nil {
+			exp.age.observe(max(now.Sub(startedAt).Seconds(), 0), cl.with(host, base)...)
+		}
+		if strings.EqualFold(item["hibernate"], "yes") {
+			hibernated.Inc()
+		}
+		// номер блокирующего сеанса, 0 - сеанс не заблокирован
+		if atoi(item["blocked-by-dbms"]) > 0 {
+			blockedDBMS.Inc()
+		}
+		if atoi(item["blocked-by-ls"]) > 0 {
+			blockedLS.Inc()
+		}
+		// duration-current в миллисекундах
+		if d := atoi(item["duration-current"]); d > 0 && time.Duration(d)*time.Millisecond > exp.longCall {
+			longCalls.Inc()
+		}
+	}
+
+	for field, resource := range sessionTopResources {
+		for _, item := range topSessions(ses, field, exp.topN) {
+			exp.top.WithLabelValues(cl.with(item["host"], exp.findBaseName(item["infobase"]), item["user-name"], item["app-id"], item["session-id"], resource)...).
+				Set(float64(atoi(item[field])))
+		}
+	}
+}
+
+// topSessions n сеансов с наибольшим ненулевым значением показателя field
+func topSessions(ses []map[string]string, field string, n int) []map[string]string {
+	result := slices.DeleteFunc(slices.Clone(ses), func(item map[string]string) bool {
+		return atoi(item[field]) <= 0
+	})
+	slices.SortStableFunc(result, func(a, b map[string]string) int {
+		return cmp.Compare(atoi(b[field]), atoi(a[field]))
+	})
+
+	return result[:min(n, len(result))]
+}
+
+// ageHistogram гистограмма возраста сеансов, построенная по одному снимку rac session list.
+// Обычная HistogramVec накапливает наблюдения, а сеансы при каждом сборе одни и те же, поэтому гистограмма строится заново
+// на каждый сбор и отдается как константная: count и бакеты - количество сеансов сейчас, а не счетчики, rate() к ним не применим
+type ageHistogram struct {
+	mx      sync.Mutex
+	desc    *prometheus.Desc
+	buckets []float64
+	series  map[string]*ageSeries
+}
+
+type ageSeries struct {
+	labels []string
+	count  uint64
+	sum    float64
+	counts map[float64]uint64 // накопленное количество по верхней границе бакета
+}
+
+func newAgeHistogram(desc *prometheus.Desc, buckets []float64) *ageHistogram {
+	return &ageHistogram{desc: desc, buckets: buckets, series: map[string]*ageSeries{}}
+}
+
+func (h *ageHistogram) observe(v float64, labels ...string) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	key := strings.Join(labels, "\x00")
+	ser, ok := h.series[key]
+	if !ok {
+		ser = &ageSeries{labels: labels, counts: make(map[float64]uint64, len(h.buckets))}
+		for _, b := range h.buckets {
+			ser.counts[b] = 0
+		}
+		h.series[key] = ser
+	}
+
+	ser.count++
+	ser.sum += v
+	for _, b := range h.buckets {
+		if v <= b {
+			ser.counts[b]++
+		}
+	}
+}
+
+func (h *ageHistogram) Reset() {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.series = map[string]*ageSeries{}
+}
+
+func (h *ageHistogram) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+func (h *ageHistogram) Collect(ch chan<- prometheus.Metric) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	for _, ser := range h.series {
+		ch <- prometheus.MustNewConstHistogram(h.desc, ser.count, ser.sum, ser.counts, ser.labels...)
+	}
+}
+
+func (exp *ExporterSessionAnalytics) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "SessionAnalytics.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterSessionAnalytics) GetName() string {
+	return "session_analytics"
+}
+
+func (exp *ExporterSessionAnalytics) GetType() model.MetricType {
+	return model.TypeRAC
+}
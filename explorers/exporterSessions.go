@@ -1,18 +1,14 @@
 package exporter
 
 import (
-	"fmt"
-	"os/exec"
 	"runtime/trace"
 	"slices"
-	"strings"
 	"sync"
-	"time"
 
 	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
 	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -20,8 +16,7 @@ import (
 type ExporterSessions struct {
 	ExporterCheckSheduleJob
 
-	mx    sync.RWMutex
-	cache *expirable.LRU[string, []map[string]string]
+	mx sync.RWMutex
 }
 
 type labelValuesMap map[string]int
@@ -30,116 +25,91 @@ func (exp *ExporterSessions) Construct(s *settings.Settings) *ExporterSessions {
 	exp.BaseExporter = newBase(exp.GetName())
 	exp.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + exp.GetName()
+	// gauge у сессий свой - в разрезе app-id, поэтому создается отдельно
+	kinds := s.GetMetricKinds(exp.GetName())
+	exp.initMetrics(s, exp.GetName(), "Сессии 1С", withClusterLabels("host", "base"), lo.Without(kinds, settings.KindGauge)...)
 
-	if slices.Contains(s.MetricKinds.Session, settings.KindSummary) {
-		exp.summary = prometheus.NewSummaryVec(
-			prometheus.SummaryOpts{
-				Name:        labelName,
-				Help:        "Сессии 1С",
-				Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-				ConstLabels: prometheus.Labels{"ras_host": s.GetRASHostPort()},
-			},
-			[]string{"host", "base"},
-		)
-	}
-
-	if slices.Contains(s.MetricKinds.Session, settings.KindGauge) {
+	if slices.Contains(kinds, settings.KindGauge) {
 		exp.gauge = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name:        labelName + "_gauge",
-				Help:        "Сессии 1С (Gauge)",
-				ConstLabels: prometheus.Labels{"ras_host": s.GetRASHostPort()},
+				Name: s.GetMetricNamePrefix() + exp.GetName() + "_gauge",
+				Help: "Сессии 1С (Gauge)",
 			},
-			[]string{"host", "base", "app-id"},
+			withClusterLabels("host", "base", "app-id"),
 		)
 	}
 
 	exp.settings = s
+	exp.runner = newRunner(s)
 	exp.ExporterCheckSheduleJob.settings = s
-	exp.cache = expirable.NewLRU[string, []map[string]string](5, nil, time.Second*5)
 
-	go exp.fillBaseList()
 	return exp
 }
 
-func (exp *ExporterSessions) getValue() {
+func (exp *ExporterSessions) getValue() (err error) {
 	defer trace.StartRegion(exp.ctx, "Sessions.getValue").End()
 
 	exp.logger.Info("получение данных экспортера")
 
-	ses, err := exp.getSessions()
-	if err != nil {
-		exp.logger.Error(errors.Wrap(err, "getSessions error"))
-		return
-	}
+	exp.resetMetrics()
 
-	if exp.summary != nil {
-		groupByDB := map[groupKey]int{}
-		for _, item := range ses {
-			groupByDB[groupKey{host: item["host"], key: exp.findBaseName(item["infobase"])}]++
+	for _, cl := range exp.GetClusters() {
+		ses, e := exp.getSessions(cl)
+		if e != nil {
+			exp.logger.Error(errors.Wrap(e, "getSessions error"))
+			err = e
+			continue
 		}
 
-		exp.summary.Reset()
+		if exp.summary != nil || exp.histogram != nil {
+			groupByDB := map[groupKey]int{}
+			for _, item := range ses {
+				groupByDB[groupKey{host: item["host"], key: exp.findBaseName(item["infobase"])}]++
+			}
 
-		// с разбивкой по БД
-		for infobaseName, v := range groupByDB {
-			exp.summary.WithLabelValues(infobaseName.host, infobaseName.key).Observe(float64(v))
+			// с разбивкой по БД
+			for infobaseName, v := range groupByDB {
+				lvs := cl.with(infobaseName.host, infobaseName.key)
+				if exp.summary != nil {
+					exp.summary.WithLabelValues(lvs...).Observe(float64(v))
+				}
+				if exp.histogram != nil {
+					exp.histogram.WithLabelValues(lvs...).Observe(float64(v))
+				}
+			}
 		}
-	}
 
-	if exp.gauge != nil {
-		groupByAppID := make(map[groupKey]labelValuesMap)
-		for _, item := range ses {
-			key := groupKey{host: item["host"], key: exp.findBaseName(item["infobase"])}
+		if exp.gauge != nil {
+			groupByAppID := make(map[groupKey]labelValuesMap)
+			for _, item := range ses {
+				key := groupKey{host: item["host"], key: exp.findBaseName(item["infobase"])}
 
-			appIdValues := groupByAppID[key]
-			if appIdValues == nil {
-				groupByAppID[key] = make(labelValuesMap)
+				appIdValues := groupByAppID[key]
+				if appIdValues == nil {
+					groupByAppID[key] = make(labelValuesMap)
+				}
+				groupByAppID[key][item["app-id"]]++
 			}
-			groupByAppID[key][item["app-id"]]++
-		}
 
-		exp.gauge.Reset()
-		for infobaseName, labelValues := range groupByAppID {
-			for appid, v := range labelValues {
-				exp.gauge.WithLabelValues(infobaseName.host, infobaseName.key, appid).Set(float64(v))
+			for infobaseName, labelValues := range groupByAppID {
+				for appid, v := range labelValues {
+					exp.gauge.WithLabelValues(cl.with(infobaseName.host, infobaseName.key, appid)...).Set(float64(v))
+				}
 			}
 		}
 	}
-
+	return err
 }
 
-func (exp *ExporterSessions) getSessions() (sesData []map[string]string, err error) {
+func (exp *ExporterSessions) getSessions(cl *clusterInfo) (sesData []map[string]string, err error) {
 	defer trace.StartRegion(exp.ctx, "Sessions.getSessions").End()
 
-	exp.mx.Lock()
-	defer exp.mx.Unlock()
-
-	if v, ok := exp.cache.Get("result"); ok {
-		exp.logger.Debug("данные получены из кеша")
-		return v, nil
-	}
-
-	var param []string
-	if exp.settings.RAC_Host() != "" {
-		param = append(param, strings.Join(appendParam([]string{exp.settings.RAC_Host()}, exp.settings.RAC_Port()), ":"))
-	}
-
-	param = append(param, "session", "list")
-	param = exp.appendLogPass(param)
-
-	param = append(param, fmt.Sprintf("--cluster=%v", exp.GetClusterID()))
-
-	cmdCommand := exec.CommandContext(exp.ctx, exp.settings.RAC_Path(), param...)
-	if result, err := exp.run(cmdCommand); err != nil {
+	// список сеансов нужен нескольким экспортерам (session, sessions_data, locks), команда выполняется один раз за период кеша
+	if sesData, err = exp.query(cl, "session", "list"); err != nil {
 		exp.logger.Error(err)
 		return []map[string]string{}, err
-	} else {
-		exp.formatMultiResult(result, &sesData)
 	}
 
-	exp.cache.Add("result", sesData)
 	return sesData, nil
 }
 
@@ -150,13 +120,7 @@ func (exp *ExporterSessions) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	if exp.summary != nil {
-		exp.summary.Collect(ch)
-	}
-	if exp.gauge != nil {
-		exp.gauge.Collect(ch)
-	}
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *ExporterSessions) GetName() string {
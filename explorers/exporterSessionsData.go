@@ -7,12 +7,12 @@ import (
 
 	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
 	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type sessionsData struct {
+	cluster             *clusterInfo
 	basename            string
 	host                string
 	appid               string
@@ -37,143 +37,162 @@ type sessionsData struct {
 type ExporterSessionsData struct {
 	ExporterSessions
 
-	buff map[string]*sessionsData
+	window      time.Duration // период, за который отдаются максимумы показателей
+	windowStart time.Time
+	buff        map[string]*sessionsData // показатели текущего периода
+	prev        map[string]*sessionsData // показатели предыдущего периода
 }
 
 func (exp *ExporterSessionsData) Construct(s *settings.Settings) *ExporterSessionsData {
 	exp.BaseExporter = newBase(exp.GetName())
 	exp.logger.Info("Создание объекта")
 
-	labelName := s.GetMetricNamePrefix() + exp.GetName()
-	exp.summary = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:        labelName,
-			Help:        "Показатели сессий из кластера 1С",
-			Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-			ConstLabels: prometheus.Labels{"ras_host": s.GetRASHostPort()},
-		},
-		[]string{"host", "base", "user", "id", "datatype", "appid"},
-	)
+	exp.initMetrics(s, exp.GetName(), "Показатели сессий из кластера 1С", withClusterLabels("host", "base", "user", "id", "datatype", "appid"))
 
+	exp.window = s.GetPropertyDuration(exp.GetName(), "Window", time.Minute)
 	exp.buff = map[string]*sessionsData{}
 	exp.settings = s
+	exp.runner = newRunner(s)
 	exp.ExporterCheckSheduleJob.settings = s
-	exp.cache = expirable.NewLRU[string, []map[string]string](5, nil, time.Second*5)
-	go exp.fillBaseList() // в данном экспортере нужен список баз
-
-	// эта метрика содержит показатели memory-current, write-current и прочие current
-	// прометей может приходить за данными довольно редко, раз в 15 секунд, или раз в минуту, как правило серверный вызов 1С проходит быстрее и такие показатели не будут прочитаны
-	// показатели нужно собирать довольно часто, чаще чем приходит прометей за данными, их просто накапливаем в буфер, потом отдаем прометею когда он придет
-	go exp.collectingMetrics(time.Second * 5)
 
 	return exp
 }
 
-func (exp *ExporterSessionsData) collectingMetrics(delay time.Duration) {
-	defer trace.StartRegion(exp.ctx, "SessionsData.collectingMetrics").End()
-
-	for {
-		ses, _ := exp.getSessions()
-		for _, item := range ses {
-			appid := item["app-id"]
-			host := item["host"]
-			user := item["user-name"]
-			memorytotal := item["memory-total"]
-			memorycurrent := item["memory-current"]
-			readcurrent := item["read-current"]
-			readtotal := item["read-total"]
-			writecurrent := item["write-current"]
-			writetotal := item["write-total"]
-			durationcurrent := item["duration-current"]
-			durationcurrentdbms := item["duration-current-dbms"]
-			if durationcurrentdbms == "" {
-				durationcurrentdbms = item["duration current-dbms"]
-			}
-			durationall := item["duration-all"]
-			durationalldbms := item["duration-all-dbms"]
-			cputimecurrent := item["cpu-time-current"]
-			cputimetotal := item["cpu-time-total"]
-			dbmsbytesall := item["dbms-bytes-all"]
-			callsall := item["calls-all"]
-			sessionid := item["session-id"]
-
-			exp.mx.Lock()
-			if v, ok := exp.buff[sessionid]; !ok {
-				exp.buff[sessionid] = &sessionsData{
-					basename:            exp.findBaseName(item["infobase"]),
-					appid:               appid,
-					host:                host,
-					user:                user,
-					memorytotal:         atoi(memorytotal),
-					memorycurrent:       atoi(memorycurrent),
-					readcurrent:         atoi(readcurrent),
-					readtotal:           atoi(readtotal),
-					writecurrent:        atoi(writecurrent),
-					writetotal:          atoi(writetotal),
-					durationcurrent:     atoi(durationcurrent),
-					durationcurrentdbms: atoi(durationcurrentdbms),
-					durationall:         atoi(durationall),
-					durationalldbms:     atoi(durationalldbms),
-					cputimecurrent:      atoi(cputimecurrent),
-					cputimetotal:        atoi(cputimetotal),
-					dbmsbytesall:        atoi(dbmsbytesall),
-					callsall:            atoi(callsall),
-					sessionid:           sessionid,
-				}
-			} else {
-				v.memorycurrent = max(v.memorycurrent, atoi(memorycurrent))
-				v.readcurrent = max(v.readcurrent, atoi(readcurrent))
-				v.cputimecurrent = max(v.cputimecurrent, atoi(cputimecurrent))
-				v.durationcurrentdbms = max(v.durationcurrentdbms, atoi(durationcurrentdbms))
-				v.durationcurrent = max(v.durationcurrent, atoi(durationcurrent))
-				v.writecurrent = max(v.writecurrent, atoi(writecurrent))
-				v.dbmsbytesall = max(v.dbmsbytesall, atoi(dbmsbytesall))
-				v.cputimetotal = max(v.cputimetotal, atoi(cputimetotal))
-				v.durationalldbms = max(v.durationalldbms, atoi(durationalldbms))
-				v.durationall = max(v.durationall, atoi(durationall))
-				v.writetotal = max(v.writetotal, atoi(writetotal))
-				v.readtotal = max(v.readtotal, atoi(readtotal))
-				v.memorytotal = max(v.memorytotal, atoi(memorytotal))
-				v.callsall = max(v.callsall, atoi(callsall))
-				exp.buff[sessionid] = v
-			}
-			exp.mx.Unlock()
-		}
+// getValue собирает показатели сессий в буфер и переносит в метрики максимумы за период.
+// Эта метрика содержит показатели memory-current, write-current и прочие current
+// прометей может приходить за данными довольно редко, раз в 15 секунд, или раз в минуту, как правило серверный вызов 1С проходит быстрее и такие показатели не будут прочитаны
+// показатели нужно собирать довольно часто (этим занимается Scheduler, по умолчанию раз в 5 секунд), чаще чем приходит прометей за данными, их накапливаем в буфер,
+// а в снимок метрик попадают максимумы за текущий и предыдущий период Window, поэтому пик виден все время, пока он не старше периода
+func (exp *ExporterSessionsData) getValue() (err error) {
+	defer trace.StartRegion(exp.ctx, "SessionsData.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
 
-		select {
-		case <-time.After(delay):
-		case <-exp.ctx.Done():
-			return
+	exp.rotate(time.Now())
+	for _, cl := range exp.GetClusters() {
+		if e := exp.collectSessions(cl); e != nil {
+			err = e
 		}
 	}
+
+	// даже при ошибке одного из кластеров отдаем то, что удалось собрать
+	exp.publish()
+	return err
 }
 
-func (exp *ExporterSessionsData) getValue() {
-	defer trace.StartRegion(exp.ctx, "SessionsData.getValue").End()
+// rotate начинает новый период накопления. Без фонового сбора показатели собираются в момент запроса и не накапливаются
+func (exp *ExporterSessionsData) rotate(now time.Time) {
+	exp.mx.Lock()
+	defer exp.mx.Unlock()
 
-	exp.logger.Info("получение данных экспортера")
+	switch {
+	case !exp.isScheduled():
+		exp.buff, exp.prev = map[string]*sessionsData{}, nil
+	case now.Sub(exp.windowStart) >= exp.window:
+		exp.buff, exp.prev = map[string]*sessionsData{}, exp.buff
+		exp.windowStart = now
+	}
+}
+
+func (exp *ExporterSessionsData) collectSessions(cl *clusterInfo) error {
+	ses, err := exp.getSessions(cl)
+	if err != nil {
+		return err
+	}
 
+	for _, item := range ses {
+		durationcurrentdbms := item["duration-current-dbms"]
+		if durationcurrentdbms == "" {
+			durationcurrentdbms = item["duration current-dbms"]
+		}
+
+		data := &sessionsData{
+			cluster:             cl,
+			basename:            exp.findBaseName(item["infobase"]),
+			appid:               item["app-id"],
+			host:                item["host"],
+			user:                item["user-name"],
+			memorytotal:         atoi(item["memory-total"]),
+			memorycurrent:       atoi(item["memory-current"]),
+			readcurrent:         atoi(item["read-current"]),
+			readtotal:           atoi(item["read-total"]),
+			writecurrent:        atoi(item["write-current"]),
+			writetotal:          atoi(item["write-total"]),
+			durationcurrent:     atoi(item["duration-current"]),
+			durationcurrentdbms: atoi(durationcurrentdbms),
+			durationall:         atoi(item["duration-all"]),
+			durationalldbms:     atoi(item["duration-all-dbms"]),
+			cputimecurrent:      atoi(item["cpu-time-current"]),
+			cputimetotal:        atoi(item["cpu-time-total"]),
+			dbmsbytesall:        atoi(item["dbms-bytes-all"]),
+			callsall:            atoi(item["calls-all"]),
+			sessionid:           item["session-id"],
+		}
+
+		exp.mx.Lock()
+		// номер сеанса уникален только в рамках кластера, поэтому ключ - идентификатор сеанса
+		if v, ok := exp.buff[item["session"]]; ok {
+			v.merge(data)
+		} else {
+			exp.buff[item["session"]] = data
+		}
+		exp.mx.Unlock()
+	}
+
+	return nil
+}
+
+// merge оставляет максимальные значения показателей
+func (v *sessionsData) merge(o *sessionsData) {
+	v.memorycurrent = max(v.memorycurrent, o.memorycurrent)
+	v.readcurrent = max(v.readcurrent, o.readcurrent)
+	v.cputimecurrent = max(v.cputimecurrent, o.cputimecurrent)
+	v.durationcurrentdbms = max(v.durationcurrentdbms, o.durationcurrentdbms)
+	v.durationcurrent = max(v.durationcurrent, o.durationcurrent)
+	v.writecurrent = max(v.writecurrent, o.writecurrent)
+	v.dbmsbytesall = max(v.dbmsbytesall, o.dbmsbytesall)
+	v.cputimetotal = max(v.cputimetotal, o.cputimetotal)
+	v.durationalldbms = max(v.durationalldbms, o.durationalldbms)
+	v.durationall = max(v.durationall, o.durationall)
+	v.writetotal = max(v.writetotal, o.writetotal)
+	v.readtotal = max(v.readtotal, o.readtotal)
+	v.memorytotal = max(v.memorytotal, o.memorytotal)
+	v.callsall = max(v.callsall, o.callsall)
+}
+
+// publish переносит накопленные за текущий и предыдущий период показатели в метрики, буфер при этом не очищается
+func (exp *ExporterSessionsData) publish() {
 	exp.mx.Lock()
 	defer exp.mx.Unlock()
 
-	exp.summary.Reset()
-	for k, v := range exp.buff {
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "memorytotal", v.appid).Observe(float64(v.memorytotal))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "memorycurrent", v.appid).Observe(float64(v.memorycurrent))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "readcurrent", v.appid).Observe(float64(v.readcurrent))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "readtotal", v.appid).Observe(float64(v.readtotal))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "writecurrent", v.appid).Observe(float64(v.writecurrent))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "writetotal", v.appid).Observe(float64(v.writetotal))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "durationcurrent", v.appid).Observe(float64(v.durationcurrent))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "durationcurrentdbms", v.appid).Observe(float64(v.durationcurrentdbms))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "durationall", v.appid).Observe(float64(v.durationall))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "durationalldbms", v.appid).Observe(float64(v.durationalldbms))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "cputimecurrent", v.appid).Observe(float64(v.cputimecurrent))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "cputimetotal", v.appid).Observe(float64(v.cputimetotal))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "dbmsbytesall", v.appid).Observe(float64(v.dbmsbytesall))
-		exp.summary.WithLabelValues(v.host, v.basename, v.user, v.sessionid, "callsall", v.appid).Observe(float64(v.callsall))
-
-		delete(exp.buff, k)
+	data := make(map[string]*sessionsData, len(exp.buff))
+	for _, buff := range []map[string]*sessionsData{exp.prev, exp.buff} {
+		for k, v := range buff {
+			if d, ok := data[k]; ok {
+				d.merge(v)
+			} else {
+				c := *v
+				data[k] = &c
+			}
+		}
+	}
+
+	exp.resetMetrics()
+	for _, v := range data {
+		exp.observe(float64(v.memorytotal), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "memorytotal", v.appid)...)
+		exp.observe(float64(v.memorycurrent), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "memorycurrent", v.appid)...)
+		exp.observe(float64(v.readcurrent), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "readcurrent", v.appid)...)
+		exp.observe(float64(v.readtotal), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "readtotal", v.appid)...)
+		exp.observe(float64(v.writecurrent), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "writecurrent", v.appid)...)
+		exp.observe(float64(v.writetotal), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "writetotal", v.appid)...)
+		exp.observe(float64(v.durationcurrent), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "durationcurrent", v.appid)...)
+		exp.observe(float64(v.durationcurrentdbms), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "durationcurrentdbms", v.appid)...)
+		exp.observe(float64(v.durationall), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "durationall", v.appid)...)
+		exp.observe(float64(v.durationalldbms), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "durationalldbms", v.appid)...)
+		exp.observe(float64(v.cputimecurrent), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "cputimecurrent", v.appid)...)
+		exp.observe(float64(v.cputimetotal), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "cputimetotal", v.appid)...)
+		exp.observe(float64(v.dbmsbytesall), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "dbmsbytesall", v.appid)...)
+		exp.observe(float64(v.callsall), v.cluster.with(v.host, v.basename, v.user, v.sessionid, "callsall", v.appid)...)
 	}
 }
 
@@ -184,8 +203,7 @@ func (exp *ExporterSessionsData) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	exp.getValue()
-	exp.summary.Collect(ch)
+	exp.collect(ch, exp.getValue)
 }
 
 func (exp *ExporterSessionsData) GetName() string {
@@ -0,0 +1,149 @@
+package exporter
+
+import (
+	"runtime/trace"
+	"strconv"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/srvinfo"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ExporterSrvInfo состав кластеров из файлов реестра srvinfo (1CV8Clst.lst): базы, сервер СУБД, рабочие серверы,
+// менеджеры кластера и требования назначения функциональности.
+// Доступ к RAS не нужен, поэтому список баз используется и как запасной источник имен баз (см. InfobaseRegistry.SetFallback)
+type ExporterSrvInfo struct {
+	BaseExporter
+
+	reader *srvinfo.Reader
+
+	cluster   *prometheus.GaugeVec
+	infobase  *prometheus.GaugeVec
+	server    *prometheus.GaugeVec
+	manager   *prometheus.GaugeVec
+	rule      *prometheus.GaugeVec
+	infobases *prometheus.GaugeVec
+}
+
+func (exp *ExporterSrvInfo) Construct(s *settings.Settings) *ExporterSrvInfo {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	name := s.GetMetricNamePrefix() + exp.GetName()
+	exp.cluster = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_cluster_info",
+			Help: "Кластеры из файлов реестра srvinfo, значение всегда 1",
+		},
+		[]string{"reg", "cluster", "name", "host", "port"},
+	)
+	exp.infobase = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_infobase_info",
+			Help: "Информационные базы из файлов реестра srvinfo, значение всегда 1",
+		},
+		[]string{"reg", "cluster", "name", "guid", "descr", "dbms", "db_server", "db_name"},
+	)
+	exp.server = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_server_info",
+			Help: "Рабочие серверы кластера из файлов реестра srvinfo, значение всегда 1",
+		},
+		[]string{"reg", "cluster", "name", "host", "port", "port_range"},
+	)
+	exp.manager = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_manager_info",
+			Help: "Менеджеры кластера из файлов реестра srvinfo, server - идентификатор рабочего сервера, значение всегда 1",
+		},
+		[]string{"reg", "cluster", "descr", "host", "port", "server"},
+	)
+	exp.rule = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_assignment_rule",
+			Help: "Требования назначения функциональности рабочих серверов из файлов реестра srvinfo, значение - приоритет. " +
+				"object_type и rule_type в том виде, как они записаны в файле, infobase пустой - для всех баз",
+		},
+		[]string{"reg", "cluster", "server", "object_type", "infobase", "rule_type", "application"},
+	)
+	exp.infobases = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: name + "_infobases",
+			Help: "Количество информационных баз кластера по файлу реестра srvinfo",
+		},
+		[]string{"reg", "cluster"},
+	)
+	exp.collectors = []prometheus.Collector{exp.cluster, exp.infobase, exp.server, exp.manager, exp.rule, exp.infobases}
+
+	dirs := s.GetPropertyStrings(exp.GetName(), "SrvInfo")
+	if len(dirs) == 0 {
+		dirs = []string{defaultSrvInfo()}
+	}
+
+	exp.reader = srvinfo.NewReader(dirs)
+	exp.settings = s
+
+	return exp
+}
+
+func (exp *ExporterSrvInfo) getValue() error {
+	defer trace.StartRegion(exp.ctx, "SrvInfo.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	// даже при ошибке чтения одного из файлов отдаем то, что удалось прочитать
+	clusters, err := exp.reader.Clusters()
+	if err != nil {
+		exp.logger.Error(err)
+	}
+
+	exp.resetMetrics()
+	for _, cl := range clusters {
+		exp.cluster.WithLabelValues(cl.Reg, cl.GUID, cl.Name, cl.Host, strconv.FormatInt(cl.Port, 10)).Set(1)
+		exp.infobases.WithLabelValues(cl.Reg, cl.GUID).Set(float64(len(cl.Infobases)))
+
+		for _, ib := range cl.Infobases {
+			exp.infobase.WithLabelValues(cl.Reg, cl.GUID, ib.Name, ib.GUID, ib.Descr, ib.DBMS, ib.DBServer, ib.DBName).Set(1)
+		}
+		for _, srv := range cl.Servers {
+			exp.server.WithLabelValues(cl.Reg, cl.GUID, srv.Name, srv.Host, strconv.FormatInt(srv.Port, 10), srv.PortRange).Set(1)
+			for _, r := range srv.Rules {
+				exp.rule.WithLabelValues(cl.Reg, cl.GUID, srv.GUID, r.ObjectType, r.Infobase, strconv.FormatInt(r.RuleType, 10), r.Application).Set(float64(r.Priority))
+			}
+		}
+		for _, m := range cl.Managers {
+			exp.manager.WithLabelValues(cl.Reg, cl.GUID, m.Descr, m.Host, strconv.FormatInt(m.Port, 10), m.Server).Set(1)
+		}
+	}
+
+	return err
+}
+
+// FindInfobase база из файлов реестра по идентификатору, идентификатор кластера в файле совпадает с идентификатором в rac
+func (exp *ExporterSrvInfo) FindInfobase(guid string) (Infobase, bool) {
+	ib, cluster, ok := exp.reader.Find(guid)
+	if !ok {
+		return Infobase{}, false
+	}
+
+	return Infobase{GUID: ib.GUID, Name: ib.Name, Descr: ib.Descr, Cluster: cluster}, true
+}
+
+func (exp *ExporterSrvInfo) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "SrvInfo.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterSrvInfo) GetName() string {
+	return "srvinfo"
+}
+
+func (exp *ExporterSrvInfo) GetType() model.MetricType {
+	return model.TypeOS
+}
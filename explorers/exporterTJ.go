@@ -0,0 +1,124 @@
+package exporter
+
+import (
+	"fmt"
+	"runtime/trace"
+	"slices"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/tj"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// максимальная длина значения метки context, контекст 1С может быть очень длинным
+const maxContextLen = 100
+
+//go:generate mockgen -source=$GOFILE -package=mock_models -destination=./mock/mockTJ.go
+type ITJReader interface {
+	Poll() ([]*tj.Event, error)
+}
+
+// ExporterTJ метрики по событиям технологического журнала
+type ExporterTJ struct {
+	BaseExporter
+
+	reader    ITJReader
+	events    []string // если не задан - учитываются все события
+	count     *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+}
+
+func (exp *ExporterTJ) Construct(s *settings.Settings) *ExporterTJ {
+	exp.BaseExporter = newBase(exp.GetName())
+	exp.logger.Info("Создание объекта")
+
+	labels := []string{"host", "base", "event", "context"}
+	labelName := s.GetMetricNamePrefix() + exp.GetName()
+
+	buckets := s.GetHistogramBuckets(exp.GetName())
+	if len(buckets) == 0 {
+		buckets = []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}
+	}
+
+	exp.count = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: labelName + "_events_total",
+			Help: "Количество событий технологического журнала",
+		},
+		labels,
+	)
+	exp.durations = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    labelName + "_event_duration_seconds",
+			Help:    "Длительность событий технологического журнала",
+			Buckets: buckets,
+		},
+		labels,
+	)
+	exp.collectors = []prometheus.Collector{exp.count, exp.durations}
+
+	// если каталоги не заданы, читаем журнал, который настраивает сам экспортер (секция TechLog)
+	dirs := s.GetPropertyStrings(exp.GetName(), "Dirs")
+	if len(dirs) == 0 && s.TechLog != nil && s.TechLog.Location != "" {
+		dirs = []string{s.TechLog.Location}
+	}
+
+	exp.events = s.GetPropertyStrings(exp.GetName(), "Events")
+	exp.reader = tj.NewTailer(dirs, fmt.Sprint(s.GetProperty(exp.GetName(), "StateFile", "")))
+	exp.settings = s
+
+	return exp
+}
+
+func (exp *ExporterTJ) getValue() error {
+	defer trace.StartRegion(exp.ctx, "TJ.getValue").End()
+
+	exp.logger.Info("получение данных экспортера")
+
+	// даже при ошибке чтения одного из файлов учитываем то, что удалось прочитать
+	events, err := exp.reader.Poll()
+	if err != nil {
+		exp.logger.Error(errors.Wrap(err, "ошибка чтения технологического журнала"))
+	}
+
+	exp.logger.Debugf("прочитано событий: %d", len(events))
+	for _, e := range events {
+		if len(exp.events) > 0 && !slices.Contains(exp.events, e.Name) {
+			continue
+		}
+
+		lvs := []string{exp.host, e.Infobase(), e.Name, truncate(e.Context(), maxContextLen)}
+		exp.count.WithLabelValues(lvs...).Inc()
+		exp.durations.WithLabelValues(lvs...).Observe(e.Duration.Seconds())
+	}
+
+	return err
+}
+
+func (exp *ExporterTJ) Collect(ch chan<- prometheus.Metric) {
+	defer trace.StartRegion(exp.ctx, "TJ.Collect").End()
+
+	if exp.isLocked.Load() {
+		return
+	}
+
+	exp.collect(ch, exp.getValue)
+}
+
+func (exp *ExporterTJ) GetName() string {
+	return "tj"
+}
+
+func (exp *ExporterTJ) GetType() model.MetricType {
+	return model.TypeOS
+}
+
+func truncate(str string, n int) string {
+	if r := []rune(str); len(r) > n {
+		return string(r[:n])
+	}
+
+	return str
+}
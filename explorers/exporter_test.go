@@ -1,22 +1,27 @@
 package exporter
 
 import (
-	"context"
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/eventlog"
 	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/tj"
 	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/golang/mock/gomock"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
 	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/process"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/exp/maps"
 	"gopkg.in/yaml.v2"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -167,7 +172,7 @@ func Test_Exporter(t *testing.T) {
 
 		exp := new(ExporterAvailablePerformance).Construct(settings)
 		exp.summary = summaryMock
-		exp.clusterID = "123"
+		exp.clusters = testClusters(settings, "123")
 		exp.runner = run
 
 		t.Run("error", func(t *testing.T) {
@@ -181,11 +186,11 @@ func Test_Exporter(t *testing.T) {
 			observer.EXPECT().Observe(gomock.Any()).Times(5)
 
 			run.EXPECT().Run(gomock.Any()).Return(testDataAvailablePerformance(), nil)
-			summaryMock.EXPECT().WithLabelValues("xxxx-win", "123", "3200", "avgservercalltime").Return(observer)
-			summaryMock.EXPECT().WithLabelValues("xxxx-win", "123", "3200", "available").Return(observer)
-			summaryMock.EXPECT().WithLabelValues("xxxx-win", "123", "3200", "avgcalltime").Return(observer)
-			summaryMock.EXPECT().WithLabelValues("xxxx-win", "123", "3200", "avgdbcalltime").Return(observer)
-			summaryMock.EXPECT().WithLabelValues("xxxx-win", "123", "3200", "avglockcalltime").Return(observer)
+			summaryMock.EXPECT().WithLabelValues("xxxx-win", "3200", "avgservercalltime", ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues("xxxx-win", "3200", "available", ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues("xxxx-win", "3200", "avgcalltime", ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues("xxxx-win", "3200", "avgdbcalltime", ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues("xxxx-win", "3200", "avglockcalltime", ":1545", "123", "test").Return(observer)
 
 			out := make(chan prometheus.Metric)
 			exp.Collect(out)
@@ -204,9 +209,10 @@ func Test_Exporter(t *testing.T) {
 		exp.mx.Lock()
 		exp.BaseExporter.mx.Lock()
 		exp.summary = summaryMock
-		exp.clusterID = "123"
+		exp.clusters = testClusters(settings, "123")
 		exp.buff = map[string]*sessionsData{
 			"1": {
+				cluster:             exp.clusters[":1545/"].items[0],
 				basename:            "test",
 				user:                "test",
 				memorytotal:         10,
@@ -243,28 +249,739 @@ func Test_Exporter(t *testing.T) {
 				assert.True(t, contains)
 			}).Times(14)
 
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "memorytotal", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "memorycurrent", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "readcurrent", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "readtotal", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "writecurrent", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "writetotal", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationcurrent", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationcurrentdbms", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationall", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationalldbms", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "cputimecurrent", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "cputimetotal", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "dbmsbytesall", gomock.Any()).Return(observer)
-			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "callsall", gomock.Any()).Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "memorytotal", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "memorycurrent", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "readcurrent", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "readtotal", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "writecurrent", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "writetotal", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationcurrent", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationcurrentdbms", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationall", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "durationalldbms", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "cputimecurrent", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "cputimetotal", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "dbmsbytesall", gomock.Any(), ":1545", "123", "test").Return(observer)
+			summaryMock.EXPECT().WithLabelValues(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "callsall", gomock.Any(), ":1545", "123", "test").Return(observer)
 
+			exp.publish()
 			out := make(chan prometheus.Metric)
-			exp.Collect(out)
+			go exp.collectMetrics(out)
 			<-out
 		})
 	})
 }
 
+func Test_ExporterTJ(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: tj
+    Property:
+      Events: [CALL, EXCP]
+`), s))
+
+	reader := mock_models.NewMockITJReader(c)
+	exp := new(ExporterTJ).Construct(s)
+	exp.reader = reader
+	exp.host = "srv"
+
+	reader.EXPECT().Poll().Return([]*tj.Event{
+		{Name: "CALL", Duration: time.Second, Props: map[string]string{"p:processName": "hrm", "Context": "Форма\nМодуль : 1"}},
+		{Name: "CALL", Duration: time.Second * 3, Props: map[string]string{"p:processName": "hrm", "Context": "Форма\nМодуль : 1"}},
+		{Name: "TLOCK", Duration: time.Second},
+	}, errors.New("ошибка чтения одного из файлов"))
+
+	ch := make(chan prometheus.Metric, 10)
+	exp.Collect(ch)
+	close(ch)
+
+	var metrics []*dto.Metric
+	for m := range ch {
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+		metrics = append(metrics, metric)
+	}
+
+	// TLOCK отфильтрован, CALL сгруппированы
+	if assert.Len(t, metrics, 2) {
+		assert.Equal(t, 2., metrics[0].GetCounter().GetValue())
+		assert.Equal(t, uint64(2), metrics[1].GetHistogram().GetSampleCount())
+		assert.Equal(t, 4., metrics[1].GetHistogram().GetSampleSum())
+	}
+}
+
+func Test_ExporterEventLog(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	reader := mock_models.NewMockIEventLogReader(c)
+	exp := new(ExporterEventLog).Construct(&settings.Settings{})
+	exp.SetInfobases(testInfobases(exp.settings, Infobase{GUID: "d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a", Name: "hrm", Cluster: "123"}))
+	exp.reader = reader
+	exp.host = "srv"
+
+	reader.EXPECT().Poll().Return([]*eventlog.Event{
+		{Infobase: "d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a", Severity: eventlog.SeverityInformation, Event: eventlog.EventSessionStart, User: "Иванов"},
+		{Infobase: "d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a", Severity: eventlog.SeverityError, Event: eventlog.EventJobFail, Metadata: "РегламентноеЗадание.Обмен"},
+		{Infobase: "d3b9d7c5-9c4b-4f0a-9b4a-1f2e3d4c5b6a", Severity: eventlog.SeverityError, Event: eventlog.EventJobFail, Metadata: "РегламентноеЗадание.Обмен"},
+		{Infobase: "unknown", Severity: eventlog.SeverityWarning, Event: "_$Data$_.Update", User: "Иванов", Metadata: "Документ.Реализация"},
+	}, nil)
+
+	ch := make(chan prometheus.Metric, 20)
+	exp.Collect(ch)
+	close(ch)
+
+	values := map[string]float64{}
+	for m := range ch {
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+
+		var lvs []string
+		for _, l := range metric.GetLabel() {
+			lvs = append(lvs, l.GetValue())
+		}
+		values[strings.Join(lvs, ",")] = metric.GetCounter().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{
+		// events
+		"hrm,_$Job$_.Fail,srv,error":          2,
+		"unknown,_$Data$_.Update,srv,warning": 1,
+		// users
+		"hrm,srv,error,":             2,
+		"unknown,srv,warning,Иванов": 1,
+		// metadata
+		"hrm,srv,РегламентноеЗадание.Обмен,error": 2,
+		"unknown,srv,Документ.Реализация,warning": 1,
+		// sessions
+		"start,hrm,srv": 1,
+		// jobs
+		"hrm,srv,РегламентноеЗадание.Обмен": 2,
+	}, values)
+}
+
+func Test_ExporterLocks(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	run := mock_models.NewMockIRunner(c)
+	exp := new(ExporterLocks).Construct(s)
+	exp.SetInfobases(testInfobases(s, Infobase{GUID: "ib1", Name: "hrm", Cluster: "123"}))
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	locked := time.Now().Add(-time.Minute).Format("2006-01-02T15:04:05")
+	run.EXPECT().Run(gomock.Any()).DoAndReturn(func(cmd *exec.Cmd) (string, error) {
+		switch parseRACArgs(cmd.Args[1:]).command {
+		case "lock list":
+			return `connection : 00000000-0000-0000-0000-000000000000
+session    : s1
+object     : 00000000-0000-0000-0000-000000000000
+locked     : ` + locked + `
+descr      : "БД(сеанс ,Справочник.Контрагенты,Разделяемая)"
+
+connection : 00000000-0000-0000-0000-000000000000
+session    : s1
+object     : 00000000-0000-0000-0000-000000000000
+locked     : 2999-01-01T00:00:00
+descr      : "БД(сеанс ,Документ.Реализация,Исключительная)"
+
+connection : 00000000-0000-0000-0000-000000000000
+session    : s2
+object     : 00000000-0000-0000-0000-000000000000
+locked     : 2999-01-01T00:00:00
+descr      : "БД(сеанс ,Справочник.Контрагенты,Разделяемая)"
+`, nil
+		case "session list":
+			return `session    : s1
+session-id : 7
+infobase   : ib1
+user-name  : Иванов
+
+session    : s2
+session-id : 8
+infobase   : ib1
+user-name  : Петров
+`, nil
+		}
+		return "", errors.New("unexpected command")
+	}).Times(2)
+
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, 2., values["locks{hrm,123,test,shared,:1545,БД}"])
+	assert.Equal(t, 1., values["locks{hrm,123,test,exclusive,:1545,БД}"])
+	assert.Equal(t, 2., values["locks_session{hrm,123,test,7,:1545,Иванов}"])
+	assert.Equal(t, 1., values["locks_session{hrm,123,test,8,:1545,Петров}"])
+	assert.InDelta(t, 60., values["locks_oldest_seconds{hrm,123,test,:1545}"], 5)
+}
+
+func Test_ExporterRphost(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	run := mock_models.NewMockIRunner(c)
+	exp := new(ExporterRphost).Construct(s)
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	process := func(pid, startedAt, excess string) string {
+		return `process            : 6a147c59-9825-4ae7-b47e-7e63fce20c78
+host               : srv
+port               : 1561
+pid                : ` + pid + `
+is-enable          : yes
+running            : yes
+started-at         : ` + startedAt + `
+use                : used
+connections        : 5
+memory-size        : 1024
+memory-excess-time : ` + excess + `
+selection-size     : 10
+reserve            : no
+`
+	}
+
+	run.EXPECT().Run(gomock.Any()).Return(process("100", "2025-03-03T10:00:00", "0")+"\n"+process("200", "2025-03-03T10:00:00", "30"), nil)
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, 1024., values["rphost{123,test,srv,100,1561,:1545,memorysize}"])
+	assert.Equal(t, 5., values["rphost{123,test,srv,100,1561,:1545,connections}"])
+	assert.Equal(t, 1., values["rphost{123,test,srv,100,1561,:1545,use}"])
+	assert.Equal(t, 0., values["rphost{123,test,srv,100,1561,:1545,turnoff}"])
+	assert.Equal(t, float64(time.Date(2025, 3, 3, 10, 0, 0, 0, time.Local).Unix()), values["rphost_started_at_seconds{123,test,srv,100,1561,:1545}"])
+	assert.NotContains(t, values, "rphost_restarts_total{123,test,srv,:1545}")
+
+	// процесс 200 превышал память и заменен новым
+	run.EXPECT().Run(gomock.Any()).Return(process("100", "2025-03-03T10:00:00", "0")+"\n"+process("300", "2025-03-03T11:00:00", "0"), nil)
+	values = metricValues(t, exp.Collect)
+	assert.Contains(t, values, "rphost{123,test,srv,300,1561,:1545,memorysize}")
+	assert.NotContains(t, values, "rphost{123,test,srv,200,1561,:1545,memorysize}")
+	assert.Equal(t, 1., values["rphost_restarts_total{123,test,srv,:1545}"])
+	assert.Equal(t, 1., values["rphost_memory_recycles_total{123,test,srv,:1545}"])
+}
+
+func Test_ExporterServer(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	run := mock_models.NewMockIRunner(c)
+	exp := new(ExporterServer).Construct(s)
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	run.EXPECT().Run(gomock.Any()).Return(`server                                    : 5a6fbd0c-4e5c-4bd6-9c9e-9a0f3c8d2b10
+agent-host                                : srv
+agent-port                                : 1540
+port-range                                : 1560:1591
+name                                      : "Центральный сервер"
+using                                     : main
+dedicate-managers                         : none
+infobases-limit                           : 8
+memory-limit                              : 0
+connections-limit                         : 128
+safe-working-processes-memory-limit       : 8589934592
+safe-call-memory-limit                    : 0
+cluster-port                              : 1541
+critical-total-memory                     : 17179869184
+temporary-allowed-total-memory            : 12884901888
+temporary-allowed-total-memory-time-limit : 300
+`, nil)
+
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, 8589934592., values["server{123,test,srv,:1545,Центральный сервер,safe_working_processes_memory_limit}"])
+	assert.Equal(t, 17179869184., values["server{123,test,srv,:1545,Центральный сервер,critical_total_memory}"])
+	assert.Equal(t, 12884901888., values["server{123,test,srv,:1545,Центральный сервер,temporary_allowed_total_memory}"])
+	assert.Equal(t, 8., values["server{123,test,srv,:1545,Центральный сервер,infobases_per_process}"])
+	assert.Equal(t, 128., values["server{123,test,srv,:1545,Центральный сервер,connections_per_process}"])
+	assert.Equal(t, 1560., values["server{123,test,srv,:1545,Центральный сервер,port_range_start}"])
+	assert.Equal(t, 1591., values["server{123,test,srv,:1545,Центральный сервер,port_range_end}"])
+	assert.Len(t, values, 11)
+}
+
+func Test_ExporterSessionAnalytics(t *testing.T) {
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: session_analytics
+    Property:
+      LongCall: 30s
+      TopN: 1
+`), s))
+
+	exp := new(ExporterSessionAnalytics).Construct(s)
+	exp.SetInfobases(testInfobases(s, Infobase{GUID: "ib1", Name: "hrm", Cluster: "123"}))
+	cl := testClusters(s, "123")[s.GetClusters()[0].String()].items[0]
+
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.Local)
+	exp.observeSessions(cl, []map[string]string{
+		{"host": "srv", "infobase": "ib1", "session-id": "1", "user-name": "Иванов", "app-id": "1CV8C", "started-at": "2025-03-03T11:59:30",
+			"hibernate": "no", "blocked-by-dbms": "0", "blocked-by-ls": "2", "duration-current": "45000", "memory-current": "1000", "cpu-time-current": "10"},
+		{"host": "srv", "infobase": "ib1", "session-id": "2", "user-name": "Петров", "app-id": "1CV8C", "started-at": "2025-03-03T10:00:00",
+			"hibernate": "yes", "blocked-by-dbms": "0", "blocked-by-ls": "0", "duration-current": "0", "memory-current": "5000", "cpu-time-current": "0"},
+		{"host": "srv", "infobase": "ib1", "session-id": "3", "user-name": "Сидоров", "app-id": "BackgroundJob", "started-at": "2025-03-03T11:00:00",
+			"hibernate": "no", "blocked-by-dbms": "1", "blocked-by-ls": "0", "duration-current": "1000", "memory-current": "0", "cpu-time-current": "20"},
+	}, now)
+
+	values := metricValues(t, exp.collectMetrics)
+	assert.Equal(t, 1., values["session_analytics_hibernated{hrm,123,test,srv,:1545}"])
+	assert.Equal(t, 1., values["session_analytics_blocked{hrm,dbms,123,test,srv,:1545}"])
+	assert.Equal(t, 1., values["session_analytics_blocked{hrm,ls,123,test,srv,:1545}"])
+	assert.Equal(t, 1., values["session_analytics_long_calls{hrm,123,test,srv,:1545}"])
+	assert.Equal(t, 5000., values["session_analytics_top{1CV8C,hrm,123,test,srv,2,:1545,memory_current,Петров}"])
+	assert.Equal(t, 20., values["session_analytics_top{BackgroundJob,hrm,123,test,srv,3,:1545,cpu_time_current,Сидоров}"])
+	assert.Len(t, values, 7)
+
+	ch := make(chan prometheus.Metric, 1)
+	exp.age.Collect(ch)
+	metric := &dto.Metric{}
+	assert.NoError(t, (<-ch).Write(metric))
+	assert.Equal(t, uint64(3), metric.GetHistogram().GetSampleCount())
+	assert.Equal(t, 30.+3600+7200, metric.GetHistogram().GetSampleSum())
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetBucket()[0].GetCumulativeCount()) // до минуты
+
+	// гистограмма - снимок текущих сеансов: следующий сбор не добавляется к предыдущему
+	exp.resetMetrics()
+	exp.observeSessions(cl, []map[string]string{
+		{"host": "srv", "infobase": "ib1", "session-id": "2", "started-at": "2025-03-03T10:00:00"},
+	}, now)
+	exp.age.Collect(ch)
+	metric = &dto.Metric{}
+	assert.NoError(t, (<-ch).Write(metric))
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+	assert.Equal(t, 7200., metric.GetHistogram().GetSampleSum())
+	assert.Equal(t, uint64(0), metric.GetHistogram().GetBucket()[0].GetCumulativeCount())
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetBucket()[4].GetCumulativeCount()) // до 4 часов
+}
+
+func Test_ExporterClientLic(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	run := mock_models.NewMockIRunner(c)
+	exp := new(ExporterClientLic).Construct(s)
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	run.EXPECT().Run(gomock.Any()).Return(stringData()+`
+	session            : d300825a-de1d-11e9-5881-001a4b0103f1
+	user-name          : Смирнов
+	host               : pc1
+	app-id             : 1CV8C
+	series             : "ORGL8"
+	issued-by-server   : no
+	license-type       : HASP
+	net                : yes
+	max-users-all      : 50
+	max-users-cur      : 50
+	rmngr-address      :
+	rmngr-port         : 0
+	rmngr-pid          : 0
+	short-presentation : "Клиент, ORGL8 50"
+`, nil)
+
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, 500., values["client_lic_capacity{123,test,software,host1,20452,Сервер, 8100886831 500 113000,:1545,8100886831}"])
+	assert.Equal(t, 50., values["client_lic_capacity{123,test,network_key,HASP,0,Клиент, ORGL8 50,:1545,ORGL8}"])
+	assert.Equal(t, 113000., values["client_lic_capacity_all{123,test,software,host1,20452,Сервер, 8100886831 500 113000,:1545,8100886831}"])
+	assert.Equal(t, 50., values["client_lic_capacity_all{123,test,network_key,HASP,0,Клиент, ORGL8 50,:1545,ORGL8}"])
+	assert.Equal(t, 3., values["client_lic_used{123,test,software,host1,:1545,8100886831}"])
+	assert.Equal(t, 3./500, values["client_lic_utilization_ratio{123,test,:1545,8100886831}"])
+	assert.Equal(t, 1./50, values["client_lic_utilization_ratio{123,test,:1545,ORGL8}"])
+	assert.Equal(t, 3./500, values["client_lic_server_utilization_ratio{123,test,host1,:1545}"])
+
+	// серия, лицензии которой выдали два менеджера кластера, учитывается у каждого только своими сеансами
+	exp.resetMetrics()
+	cl := exp.clusters[s.GetClusters()[0].String()].items[0]
+	exp.observeCapacity(cl, []map[string]string{
+		{"series": "8100886831", "license-type": "soft", "rmngr-address": "host1", "max-users-cur": "100"},
+		{"series": "8100886831", "license-type": "soft", "rmngr-address": "host1", "max-users-cur": "100"},
+		{"series": "8100886831", "license-type": "soft", "rmngr-address": "host1", "max-users-cur": "100"},
+		{"series": "8100886831", "license-type": "soft", "rmngr-address": "host2", "max-users-cur": "100"},
+	})
+	values = metricValues(t, exp.collectMetrics)
+	assert.Equal(t, 3., values["client_lic_used{123,test,software,host1,:1545,8100886831}"])
+	assert.Equal(t, 1., values["client_lic_used{123,test,software,host2,:1545,8100886831}"])
+	assert.Equal(t, 4./100, values["client_lic_utilization_ratio{123,test,:1545,8100886831}"])
+	assert.Equal(t, 3./100, values["client_lic_server_utilization_ratio{123,test,host1,:1545}"])
+	assert.Equal(t, 1./100, values["client_lic_server_utilization_ratio{123,test,host2,:1545}"])
+}
+
+// testLicInspector лицензии по имени файла, в списке лицензий каталога имя лицензии - "pin-<имя файла>"
+type testLicInspector struct {
+	licenses map[string]LicInfo
+	inspects int
+}
+
+func (i *testLicInspector) List(string) (map[string]string, error) {
+	result := map[string]string{"broken.lic": "pin-broken.lic"}
+	for file := range i.licenses {
+		result[file] = "pin-" + file
+	}
+	return result, nil
+}
+
+func (i *testLicInspector) Inspect(_, name string) (LicInfo, error) {
+	i.inspects++
+	if info, ok := i.licenses[strings.TrimPrefix(name, "pin-")]; ok {
+		return info, nil
+	}
+	return LicInfo{}, errors.New("license not found")
+}
+
+func Test_ExporterLicFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "1.lic"), []byte("1"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "2.lic"), []byte("2"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("-"), os.ModePerm))
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: lic_files
+    Property:
+      Dirs: [`+dir+`]
+`), s))
+
+	exp := new(ExporterLicFiles).Construct(s)
+	inspector := &testLicInspector{licenses: map[string]LicInfo{
+		"1.lic": {Series: "8100886831", Product: "Клиентская лицензия на 50 рабочих мест", Users: 50},
+		"2.lic": {Series: "8100886831", Product: "Клиентская лицензия на 50 рабочих мест", Users: 50},
+		"3.lic": {Series: "8100123456", Product: "Клиентская лицензия на 10 рабочих мест", Users: 10},
+	}}
+	exp.inspector = inspector
+
+	// первое сканирование - исходное состояние, изменений нет
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, 50., values["lic_files_info{"+dir+",1.lic,Клиентская лицензия на 50 рабочих мест,,8100886831}"])
+	assert.Equal(t, 100., values["lic_files_capacity{Клиентская лицензия на 50 рабочих мест,8100886831}"])
+	assert.Len(t, values, 3)
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "2.lic")))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "1.lic"), []byte("11"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "3.lic"), []byte("3"), os.ModePerm))
+
+	values = metricValues(t, exp.Collect)
+	assert.Equal(t, 1., values["lic_files_changed{added,"+dir+",3.lic}"])
+	assert.Equal(t, 1., values["lic_files_changed{removed,"+dir+",2.lic}"])
+	assert.Equal(t, 1., values["lic_files_changed{modified,"+dir+",1.lic}"])
+	assert.Equal(t, 1., values["lic_files_changes_total{added,"+dir+"}"])
+	assert.Equal(t, 50., values["lic_files_capacity{Клиентская лицензия на 50 рабочих мест,8100886831}"])
+	assert.Equal(t, 10., values["lic_files_capacity{Клиентская лицензия на 10 рабочих мест,8100123456}"])
+
+	// изменения отмечаются до следующего изменения (сканирование по другому адресу их не забирает), счетчики накапливаются
+	values = metricValues(t, exp.Collect)
+	assert.Equal(t, 1., values["lic_files_changed{added,"+dir+",3.lic}"])
+	assert.Equal(t, 1., values["lic_files_changes_total{removed,"+dir+"}"])
+	assert.Equal(t, 4, inspector.inspects) // сведения запрашивались только для новых и измененных файлов
+
+	// ошибка получения сведений запоминается, ring не запускается на каждое сканирование
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.lic"), []byte("4"), os.ModePerm))
+	values = metricValues(t, exp.Collect)
+	assert.Equal(t, 0., values["lic_files_info{"+dir+",broken.lic,,,}"])
+	assert.Equal(t, 1., values["lic_files_changed{added,"+dir+",broken.lic}"])
+	assert.NotContains(t, values, "lic_files_changed{added,"+dir+",3.lic}")
+	metricValues(t, exp.Collect)
+	assert.Equal(t, 5, inspector.inspects)
+}
+
+func Test_findRing(t *testing.T) {
+	dir := t.TempDir()
+	for _, version := range []string{"0.9.5+12-x86_64", "0.19.5+12-x86_64", "0.11.5+12-x86_64"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, "1c-enterprise-ring-"+version), os.ModePerm))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "1c-enterprise-ring-"+version, "ring"), nil, os.ModePerm))
+	}
+
+	defer func(globs []string) { ringGlobs = globs }(ringGlobs)
+	ringGlobs = []string{filepath.Join(t.TempDir(), "1c-enterprise-ring-*", "ring"), filepath.Join(dir, "1c-enterprise-ring-*", "ring")}
+
+	// берется последняя установленная версия
+	assert.Equal(t, filepath.Join(dir, "1c-enterprise-ring-0.19.5+12-x86_64", "ring"), findRing())
+}
+
+func Test_parseLicList(t *testing.T) {
+	names := parseLicList(`018106628354391-8100886831 (file name: "018106628354391-8100886831.lic")
+018106628354392-8100123456 (file name: "client10.lic")
+`)
+	assert.Equal(t, map[string]string{
+		"018106628354391-8100886831.lic": "018106628354391-8100886831",
+		"client10.lic":                   "018106628354392-8100123456",
+	}, names)
+}
+
+func Test_parseLicInfo(t *testing.T) {
+	info := parseLicInfo(`Регистрационный номер: 8100886831
+Продукт: "1С:Предприятие 8 ПРОФ. Клиентская лицензия на 50 рабочих мест"
+Ограничения: Linux`)
+	assert.Equal(t, LicInfo{Series: "8100886831", Product: "1С:Предприятие 8 ПРОФ. Клиентская лицензия на 50 рабочих мест", Users: 50, Restrictions: "Linux"}, info)
+}
+
+func Test_ExporterSrvInfo(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "reg_1541"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "reg_1541", "1CV8Clst.lst"), []byte(`{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,"Локальный кластер",1541,"srv1",0,
+{1,{7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d,"hrm","Зарплата","PostgreSQL","db1","hrm_db","postgres","",0}},
+{1,{c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f,"Центральный сервер","srv1",1540,"1560:1591",0,{1,{3b4c5d6e-7f80-4a91-b2c3-d4e5f6a7b8c9,"BackgroundJob","hrm",0,"",10}}}},
+{1,{9a8b7c6d-5e4f-4321-8fed-cba987654321,"Главный менеджер кластера","srv1",1541,c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f}},0}`), os.ModePerm))
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: srvinfo
+    Property:
+      SrvInfo: [`+dir+`]
+`), s))
+
+	exp := new(ExporterSrvInfo).Construct(s)
+	defer exp.Stop()
+
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, map[string]float64{
+		"srvinfo_cluster_info{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,srv1,Локальный кластер,1541,reg_1541}":                                              1,
+		"srvinfo_infobase_info{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,hrm_db,db1,PostgreSQL,Зарплата,7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d,hrm,reg_1541}": 1,
+		"srvinfo_server_info{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,srv1,Центральный сервер,1540,1560:1591,reg_1541}":                                    1,
+		"srvinfo_infobases{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,reg_1541}":                                                                             1,
+		"srvinfo_manager_info{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,Главный менеджер кластера,srv1,1541,reg_1541,c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f}": 1,
+		"srvinfo_assignment_rule{,5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,hrm,BackgroundJob,reg_1541,0,c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f}":             10,
+	}, values)
+
+	// база, которой нет в списке RAS, определяется по файлу реестра
+	r := newInfobaseRegistry(s)
+	defer r.Stop()
+	job := new(ExporterCheckSheduleJob).Construct(s)
+	defer job.Stop()
+	job.SetInfobases(r)
+
+	assert.Equal(t, "", job.findBaseName("7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"))
+	r.SetFallback(exp)
+	assert.Equal(t, "hrm", job.findBaseName("7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"))
+
+	r.set("5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60", []*Infobase{{GUID: "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d", Name: "hrm_ras"}})
+	assert.Equal(t, "hrm_ras", job.findBaseName("7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"))
+}
+
+func Test_ExporterCounters(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	run := mock_models.NewMockIRunner(c)
+	exp := new(ExporterCounters).Construct(s)
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	run.EXPECT().Run(gomock.Any()).DoAndReturn(func(cmd *exec.Cmd) (string, error) {
+		switch parseRACArgs(cmd.Args[1:]).command {
+		case "counter list":
+			return `name                      : users_cpu
+collection-time           : current
+group                     : users
+filter-type               : all
+filter                    :
+duration                  : not-analyze
+cpu-time                  : analyze
+duration-dbms             : not-analyze
+service                   : not-analyze
+memory                    : analyze
+read                      : not-analyze
+write                     : not-analyze
+dbms-bytes                : not-analyze
+call                      : not-analyze
+number-of-active-sessions : not-analyze
+number-of-sessions        : not-analyze
+descr                     :
+`, nil
+		case "counter values":
+			assert.Contains(t, cmd.Args, "--counter=users_cpu")
+			return `object                    : Иванов
+collection-time           : 123
+duration                  : 0
+cpu-time                  : 5000
+memory                    : 1024
+call                      : 7
+
+object                    : Петров
+collection-time           : 123
+duration                  : 0
+cpu-time                  : 1000
+memory                    : 2048
+call                      : 1
+`, nil
+		case "limit list":
+			return `name                      : cpu_limit
+counter                   : users_cpu
+action                    : interrupt-current-call
+duration                  : 0
+cpu-time                  : 10000
+memory                    : 0
+error-message             : "Превышен лимит"
+descr                     :
+`, nil
+		}
+		return "", errors.New("unexpected command")
+	}).Times(3)
+
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, 5000., values["counters{123,test,users_cpu,cpu_time,users,Иванов,:1545}"])
+	assert.Equal(t, 2048., values["counters{123,test,users_cpu,memory,users,Петров,:1545}"])
+	assert.NotContains(t, values, "counters{123,test,users_cpu,call,users,Иванов,:1545}")
+	assert.Equal(t, 10000., values["counters_limit{interrupt-current-call,123,test,users_cpu,cpu_time,cpu_limit,:1545}"])
+	assert.Equal(t, 0.5, values["counters_limit_usage_ratio{123,test,users_cpu,cpu_time,cpu_limit,Иванов,:1545}"])
+	assert.Equal(t, 0.1, values["counters_limit_usage_ratio{123,test,users_cpu,cpu_time,cpu_limit,Петров,:1545}"])
+	assert.Len(t, values, 7)
+}
+
+func Test_ExporterInfobaseState(t *testing.T) {
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: infobase_state
+    Property:
+      Properties: [sessions-deny, denied-from, denied-to, permission-code, license-distribution, security-level, dbms, db-server]
+`), s))
+
+	exp := new(ExporterInfobaseState).Construct(s)
+	exp.clusters = testClusters(s, "123")
+	exp.SetInfobases(testInfobases(s, Infobase{GUID: "ib1", Name: "hrm", Cluster: "123"}))
+
+	p := gomonkey.ApplyPrivateMethod(&exp.ExporterCheckSheduleJob, "getInfoBase", func(_ *ExporterCheckSheduleJob, _ *clusterInfo, _, _ string) (map[string]string, error) {
+		return map[string]string{
+			"sessions-deny":        "on",
+			"denied-from":          "2020-01-01T00:00:00",
+			"denied-to":            "0001-01-01T00:00:00",
+			"permission-code":      "\"123\"",
+			"license-distribution": "allow",
+			"security-level":       "0",
+			"dbms":                 "PostgreSQL",
+			"db-server":            "pg01",
+			"db-pwd":               "secret",
+		}, nil
+	})
+	defer p.Reset()
+
+	values := metricValues(t, exp.Collect)
+	assert.Equal(t, 1., values["infobase_state{hrm,123,test,sessions-deny,:1545}"])
+	assert.Equal(t, 1., values["infobase_state{hrm,123,test,sessions-deny-active,:1545}"])
+	assert.Equal(t, float64(time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local).Unix()), values["infobase_state{hrm,123,test,denied-from,:1545}"])
+	assert.Equal(t, 0., values["infobase_state{hrm,123,test,denied-to,:1545}"])
+	assert.Equal(t, 1., values["infobase_state{hrm,123,test,permission-code,:1545}"])
+	assert.Equal(t, 1., values["infobase_state{hrm,123,test,license-distribution,:1545}"])
+	assert.Equal(t, 0., values["infobase_state{hrm,123,test,security-level,:1545}"])
+	assert.Equal(t, 1., values["infobase_state_info{hrm,123,test,pg01,PostgreSQL,:1545}"])
+	assert.Len(t, values, 8)
+}
+
+func Test_ExporterCustom(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: session_apps
+    Property:
+      Command: session list
+      Labels: [infobase, app-id]
+  - Name: session_memory
+    Property:
+      Command: [session, list]
+      Labels: [infobase]
+      Values: [memory-current, hibernate]
+      Aggregate: max
+  - Name: session_calls
+    Property:
+      Command: session list
+      Labels: [session-id]
+      Values: [calls-all]
+  - Name: wrong
+    Property:
+      Command: session list
+      Aggregate: avg
+  - Name: session-apps
+    Property:
+      Command: session list
+  - Name: wrong_label
+    Property:
+      Command: session list
+      Labels: [app.id]
+  - Name: reserved_label
+    Property:
+      Command: session list
+      Labels: [cluster-id]
+`), s))
+
+	assert.Equal(t, []string{"session_apps", "session_memory", "session_calls", "wrong", "session-apps", "wrong_label", "reserved_label"}, s.GetCustomExporters())
+	_, err := new(ExporterCustom).Construct(s, "wrong")
+	assert.EqualError(t, err, `для экспортера "wrong" задан неизвестный Aggregate "avg", допустимо count, sum, max`)
+	_, err = new(ExporterCustom).Construct(s, "session-apps")
+	assert.EqualError(t, err, `имя экспортера "session-apps" не может быть именем метрики prometheus (допустимы латинские буквы, цифры, _ и :)`)
+	_, err = new(ExporterCustom).Construct(s, "wrong_label")
+	assert.EqualError(t, err, `для экспортера "wrong_label" поле "app.id" в Labels не может быть именем метки prometheus`)
+	_, err = new(ExporterCustom).Construct(s, "reserved_label")
+	assert.EqualError(t, err, `для экспортера "reserved_label" поле "cluster-id" в Labels совпадает с зарезервированной меткой "cluster_id"`)
+
+	data := `session        : 1
+session-id     : 10
+infobase       : ib1
+app-id         : 1CV8C
+memory-current : 100
+calls-all      : 5
+hibernate      : no
+
+session        : 2
+session-id     : 11
+infobase       : ib1
+app-id         : 1CV8C
+memory-current : 300
+calls-all      : 7
+hibernate      : yes
+
+session        : 3
+session-id     : 12
+infobase       : ib2
+app-id         : BackgroundJob
+memory-current : 50
+calls-all      : 1
+hibernate      : no
+`
+
+	infobases := testInfobases(s, Infobase{GUID: "ib1", Name: "hrm", Cluster: "123"})
+	newExporter := func(name string) *ExporterCustom {
+		exp, err := new(ExporterCustom).Construct(s, name)
+		assert.NoError(t, err)
+
+		run := mock_models.NewMockIRunner(c)
+		run.EXPECT().Run(gomock.Any()).Return(data, nil)
+		exp.clusters = testClusters(s, "123")
+		exp.runner = run
+		exp.SetInfobases(infobases)
+		return exp
+	}
+
+	// без Values считается количество записей, база неизвестная реестру остается идентификатором
+	values := metricValues(t, newExporter("session_apps").Collect)
+	assert.Equal(t, 2., values["session_apps_gauge{1CV8C,123,test,hrm,:1545}"])
+	assert.Equal(t, 1., values["session_apps_gauge{BackgroundJob,123,test,ib2,:1545}"])
+	assert.Len(t, values, 2)
+
+	values = metricValues(t, newExporter("session_memory").Collect)
+	assert.Equal(t, 300., values["session_memory_gauge{123,test,memory-current,hrm,:1545}"])
+	assert.Equal(t, 1., values["session_memory_gauge{123,test,hibernate,hrm,:1545}"])
+	assert.Equal(t, 50., values["session_memory_gauge{123,test,memory-current,ib2,:1545}"])
+	assert.Len(t, values, 4)
+
+	values = metricValues(t, newExporter("session_calls").Collect)
+	assert.Equal(t, 5., values["session_calls_gauge{123,test,calls-all,:1545,10}"])
+	assert.Equal(t, 7., values["session_calls_gauge{123,test,calls-all,:1545,11}"])
+	assert.Len(t, values, 3)
+}
+
 func Test_Unmarshal(t *testing.T) {
 	s := &settings.Settings{}
 	err := yaml.Unmarshal([]byte(settingstext()), s)
@@ -318,6 +1035,35 @@ DBCredentials:
   Password: ""`
 }
 
+// metricValues значения метрик экспортера, ключ - имя{значения меток через запятую в порядке имен меток}
+var reFqName = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+func metricValues(t *testing.T, collect func(ch chan<- prometheus.Metric)) map[string]float64 {
+	ch := make(chan prometheus.Metric, 100)
+	collect(ch)
+	close(ch)
+
+	result := map[string]float64{}
+	for m := range ch {
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+
+		var lvs []string
+		for _, l := range metric.GetLabel() {
+			lvs = append(lvs, l.GetValue())
+		}
+		name := reFqName.FindStringSubmatch(m.Desc().String())[1]
+		result[name+"{"+strings.Join(lvs, ",")+"}"] = metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+	}
+
+	return result
+}
+
+func testClusters(s *settings.Settings, id string) map[string]*clusterList {
+	c := s.GetClusters()[0]
+	return map[string]*clusterList{c.String(): {items: []*clusterInfo{{Cluster: c, id: id, name: "test"}}, updated: time.Now()}}
+}
+
 func testDataAvailablePerformance() string {
 	return `process              : 6a147c59-9825-4ae7-b47e-7e63fce20c78
 host                 : xxxx-win
@@ -360,44 +1106,76 @@ func Test_collectingMetrics(t *testing.T) {
 		close(ch)
 	}).MaxTimes(2)
 	summaryMock.EXPECT().Reset().MaxTimes(2)
-
-	go func() {
-		fillBaseListRun.Lock() // что бы не запустился fillBaseList и все не испортил
-	}()
-
-	var cancel context.CancelFunc
+	observer := mock_models.NewMockObserver(c)
+	observer.EXPECT().Observe(gomock.Any()).Times(14)
+	summaryMock.EXPECT().WithLabelValues(gomock.Any()).Return(observer).Times(14)
 
 	exp := new(ExporterSessionsData).Construct(settings)
-	cancel = exp.cancel
+	defer exp.Stop()
 
-	exp.mx.Lock()
-	exp.cache = expirable.NewLRU[string, []map[string]string](5, nil, time.Millisecond)
 	exp.summary = summaryMock
-	exp.clusterID = "123"
+	exp.clusters = testClusters(settings, "123")
 	exp.runner = run
 
-	freeze := make(chan struct{})
+	run.EXPECT().Run(gomock.Any()).Return(testDatasession1(), nil)
+	assert.NoError(t, exp.getValue())
 
-	run.EXPECT().Run(gomock.Any()).DoAndReturn(func(_ *exec.Cmd) (string, error) {
-		cancel()
+	exp.mx.RLock()
 
-		go func() {
-			time.Sleep(time.Millisecond * 100)
-			close(freeze)
-		}()
+	assert.Equal(t, int64(10), exp.buff["f028ea3e-5402-4f15-8194-34cb70bca0c4"].memorycurrent)
+	assert.Equal(t, int64(130), exp.buff["f028ea3e-5402-4f15-8194-34cb70bca0c4"].durationcurrentdbms)
+	assert.Equal(t, int64(112815764), exp.buff["f028ea3e-5402-4f15-8194-34cb70bca0c4"].readtotal)
 
-		return testDatasession1(), nil
-	})
-	exp.mx.Unlock()
+	exp.mx.RUnlock()
+}
 
-	<-freeze
-	exp.mx.RLock()
+func Test_sessionsDataWindow(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
 
-	assert.Equal(t, int64(10), exp.buff["590"].memorycurrent)
-	assert.Equal(t, int64(130), exp.buff["590"].durationcurrentdbms)
-	assert.Equal(t, int64(112815764), exp.buff["590"].readtotal)
+	s := new(settings.Settings)
+	run := mock_models.NewMockIRunner(c)
+	exp := new(ExporterSessionsData).Construct(s)
+	defer exp.Stop()
 
-	exp.mx.RUnlock()
+	exp.schedule(exp.GetName(), time.Second*5)
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	// максимум memory-current из снимка, который получит prometheus
+	snapshot := func() float64 {
+		ch := make(chan prometheus.Metric, 100)
+		exp.Collect(ch)
+		close(ch)
+		for m := range ch {
+			metric := &dto.Metric{}
+			assert.NoError(t, m.Write(metric))
+			for _, l := range metric.GetLabel() {
+				if l.GetName() == "datatype" && l.GetValue() == "memorycurrent" {
+					return metric.GetSummary().GetSampleSum()
+				}
+			}
+		}
+		return -1
+	}
+	memory := func(current string) float64 {
+		run.EXPECT().Run(gomock.Any()).Return(strings.Replace(testDatasession1(), "memory-current                   : 10", "memory-current                   : "+current, 1), nil)
+		assert.NoError(t, exp.getValue())
+		exp.saveSnapshot()
+
+		return snapshot()
+	}
+
+	assert.Equal(t, 10., memory("10"))
+	assert.Equal(t, 10., snapshot())  // запросы с разных адресов (/metrics, /metrics_rac) получают одни и те же данные
+	assert.Equal(t, 10., memory("5")) // пик держится в течение периода
+
+	// новый период: пик предыдущего периода еще отдается
+	exp.windowStart = exp.windowStart.Add(-time.Minute)
+	assert.Equal(t, 10., memory("3"))
+
+	exp.windowStart = exp.windowStart.Add(-time.Minute)
+	assert.Equal(t, 3., memory("3"))
 }
 
 func testDatasession1() string {
@@ -0,0 +1,256 @@
+package exporter
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Infobase информационная база кластера (rac infobase summary list)
+type Infobase struct {
+	GUID    string
+	Name    string
+	Descr   string
+	Cluster string // идентификатор кластера
+}
+
+// InfobaseEvent база добавлена в кластер или удалена из него
+type InfobaseEvent struct {
+	Infobase
+	Removed bool
+}
+
+// InfobaseRegistry список информационных баз в разрезе кластеров, нужен экспортерам для определения имени базы по идентификатору.
+// Список обновляется периодически независимо от экспортеров, об изменениях можно узнать подпиской (Subscribe)
+type InfobaseRegistry struct {
+	BaseRACExporter
+
+	basesMx     sync.RWMutex
+	bases       map[string]map[string]*Infobase // ключ - идентификатор кластера, затем идентификатор базы
+	subscribers map[int]func(InfobaseEvent)
+	nextID      int
+	fallback    InfobaseSource
+
+	info  *prometheus.Desc
+	count prometheus.Gauge // количество баз в exporter_infobases, только у реестра приложения
+}
+
+// InfobaseConsumer экспортер, которому для определения имен баз нужен реестр баз
+type InfobaseConsumer interface {
+	SetInfobases(r *InfobaseRegistry)
+}
+
+// InfobaseSource запасной источник баз, используется когда базы нет в списке, полученном через RAS (например RAS недоступен)
+type InfobaseSource interface {
+	FindInfobase(guid string) (Infobase, bool)
+}
+
+// NewInfobaseRegistry реестр баз кластеров из настроек, обновление запускается методом Start
+func NewInfobaseRegistry(s *settings.Settings) *InfobaseRegistry {
+	r := newInfobaseRegistry(s)
+	r.count = selfMetrics.infobases
+	return r
+}
+
+func newInfobaseRegistry(s *settings.Settings) *InfobaseRegistry {
+	r := &InfobaseRegistry{
+		bases:       map[string]map[string]*Infobase{},
+		subscribers: map[int]func(InfobaseEvent){},
+	}
+	r.BaseExporter = newBase("infobases")
+	r.settings = s
+	r.runner = newRunner(s)
+	r.info = prometheus.NewDesc(
+		s.GetMetricNamePrefix()+"infobase_info",
+		"Информационные базы кластера, значение всегда 1",
+		[]string{"name", "guid", "descr", "cluster"}, nil,
+	)
+
+	return r
+}
+
+// Start периодически обновляет список баз до завершения ctx
+func (r *InfobaseRegistry) Start(ctx context.Context) {
+	// редко, но все же список баз может быть изменен, поэтому обновление периодическое, чтобы не приходилось перезапускать экспортер
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+
+	for {
+		r.logger.Info("получаем список баз")
+		if err := r.Refresh(); err != nil {
+			r.logger.Error(errors.Wrap(err, "ошибка получения списка баз"))
+			t.Reset(time.Minute) // если была ошибка пробуем через минуту, если ошибка пропала, то вернем часовой интервал
+		} else {
+			t.Reset(time.Hour)
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			r.logger.Debug("context is done")
+			return
+		}
+	}
+}
+
+// Refresh получает список баз всех кластеров. Если кластер недоступен, его базы остаются в списке
+func (r *InfobaseRegistry) Refresh() (err error) {
+	clusters := r.GetClusters()
+	if len(clusters) == 0 {
+		return errors.New("не удалось получить список кластеров")
+	}
+
+	for _, cl := range clusters {
+		list, e := r.query(cl, "infobase", "summary", "list")
+		if e != nil {
+			err = e
+			continue
+		}
+
+		bases := make([]*Infobase, 0, len(list))
+		for _, item := range list {
+			if item["infobase"] == "" {
+				continue
+			}
+
+			bases = append(bases, &Infobase{
+				GUID:    item["infobase"],
+				Name:    strings.Trim(item["name"], "\""),
+				Descr:   strings.Trim(item["descr"], "\""),
+				Cluster: cl.id,
+			})
+		}
+
+		r.set(cl.id, bases)
+	}
+
+	return err
+}
+
+// set заменяет список баз кластера и оповещает подписчиков об изменениях
+func (r *InfobaseRegistry) set(cluster string, list []*Infobase) {
+	r.basesMx.Lock()
+
+	previous := r.bases[cluster]
+	current := make(map[string]*Infobase, len(list))
+	var events []InfobaseEvent
+	for _, b := range list {
+		current[b.GUID] = b
+		if _, ok := previous[b.GUID]; !ok {
+			events = append(events, InfobaseEvent{Infobase: *b})
+		}
+	}
+	for guid, b := range previous {
+		if _, ok := current[guid]; !ok {
+			events = append(events, InfobaseEvent{Infobase: *b, Removed: true})
+		}
+	}
+
+	r.bases[cluster] = current
+	if r.count != nil {
+		var count int
+		for _, bases := range r.bases {
+			count += len(bases)
+		}
+		r.count.Set(float64(count))
+	}
+
+	subscribers := slices.Collect(maps.Values(r.subscribers))
+	r.basesMx.Unlock()
+
+	// подписчики вызываются вне блокировки, что бы из них можно было обращаться к реестру
+	for _, ev := range events {
+		for _, fn := range subscribers {
+			fn(ev)
+		}
+	}
+}
+
+// Subscribe подписка на добавление и удаление баз, возвращает функцию отмены подписки
+func (r *InfobaseRegistry) Subscribe(fn func(InfobaseEvent)) func() {
+	r.basesMx.Lock()
+	defer r.basesMx.Unlock()
+
+	id := r.nextID
+	r.nextID++
+	r.subscribers[id] = fn
+
+	return func() {
+		r.basesMx.Lock()
+		defer r.basesMx.Unlock()
+
+		delete(r.subscribers, id)
+	}
+}
+
+// SetFallback задает запасной источник баз для Find
+func (r *InfobaseRegistry) SetFallback(src InfobaseSource) {
+	r.basesMx.Lock()
+	defer r.basesMx.Unlock()
+
+	r.fallback = src
+}
+
+// Find база по идентификатору, идентификаторы баз уникальны, поэтому поиск по всем кластерам.
+// Если база не найдена, она ищется в запасном источнике
+func (r *InfobaseRegistry) Find(guid string) (Infobase, bool) {
+	r.basesMx.RLock()
+	for _, bases := range r.bases {
+		if b, ok := bases[guid]; ok {
+			r.basesMx.RUnlock()
+			return *b, true
+		}
+	}
+	fallback := r.fallback
+	r.basesMx.RUnlock()
+
+	if fallback != nil {
+		return fallback.FindInfobase(guid)
+	}
+
+	return Infobase{}, false
+}
+
+// List базы всех кластеров
+func (r *InfobaseRegistry) List() []Infobase {
+	r.basesMx.RLock()
+	defer r.basesMx.RUnlock()
+
+	var result []Infobase
+	for _, bases := range r.bases {
+		for _, b := range bases {
+			result = append(result, *b)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b Infobase) int {
+		return strings.Compare(a.Cluster+a.Name, b.Cluster+b.Name)
+	})
+	return result
+}
+
+func (r *InfobaseRegistry) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.info
+}
+
+func (r *InfobaseRegistry) Collect(ch chan<- prometheus.Metric) {
+	for _, b := range r.List() {
+		ch <- prometheus.MustNewConstMetric(r.info, prometheus.GaugeValue, 1, b.Name, b.GUID, b.Descr, b.Cluster)
+	}
+}
+
+// SetInfobases задает реестр баз всем экспортерам, которым он нужен (в том числе отключенным, что бы при включении они сразу определяли имена баз)
+func (exp *Metrics) SetInfobases(r *InfobaseRegistry) {
+	for _, ex := range exp.Exporters {
+		if v, ok := ex.(InfobaseConsumer); ok {
+			v.SetInfobases(r)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"testing"
+
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InfobaseRegistry(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := new(settings.Settings)
+	run := mock_models.NewMockIRunner(c)
+
+	r := newInfobaseRegistry(s)
+	defer r.Stop()
+	r.clusters = testClusters(s, "123")
+	r.runner = run
+
+	var events []InfobaseEvent
+	unsubscribe := r.Subscribe(func(ev InfobaseEvent) { events = append(events, ev) })
+
+	job := new(ExporterCheckSheduleJob).Construct(s)
+	defer job.Stop()
+	job.SetInfobases(r)
+
+	t.Run("refresh", func(t *testing.T) {
+		run.EXPECT().Run(gomock.Any()).Return(`infobase : ib1
+name     : hrm
+descr    : "Зарплата"
+
+infobase : ib2
+name     : acc
+descr    :
+`, nil)
+
+		assert.NoError(t, r.Refresh())
+		assert.Equal(t, []Infobase{
+			{GUID: "ib2", Name: "acc", Cluster: "123"},
+			{GUID: "ib1", Name: "hrm", Descr: "Зарплата", Cluster: "123"},
+		}, r.List())
+		assert.Len(t, events, 2)
+		assert.Equal(t, "hrm", job.findBaseName("ib1"))
+
+		job.gauge.WithLabelValues(r.GetClusters()[0].with("acc")...).Set(1)
+		job.gauge.WithLabelValues(r.GetClusters()[0].with("hrm")...).Set(0)
+	})
+	t.Run("error", func(t *testing.T) {
+		run.EXPECT().Run(gomock.Any()).Return("", errors.New("error"))
+
+		// список кластера сохраняется
+		assert.Error(t, r.Refresh())
+		assert.Len(t, r.List(), 2)
+	})
+	t.Run("removed", func(t *testing.T) {
+		run.EXPECT().Run(gomock.Any()).Return("infobase : ib1\nname     : hrm\n", nil)
+		events = nil
+
+		assert.NoError(t, r.Refresh())
+		assert.Equal(t, []InfobaseEvent{{Infobase: Infobase{GUID: "ib2", Name: "acc", Cluster: "123"}, Removed: true}}, events)
+		assert.Equal(t, "", job.findBaseName("ib2"))
+
+		// значение удаленной базы больше не отдается
+		assert.Equal(t, 1, testutil.CollectAndCount(job.gauge))
+	})
+	t.Run("metric", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		assert.NoError(t, reg.Register(r))
+
+		values := metricValues(t, r.Collect)
+		assert.Equal(t, map[string]float64{"infobase_info{123,,ib1,hrm}": 1}, values)
+	})
+	t.Run("unsubscribe", func(t *testing.T) {
+		unsubscribe()
+		events = nil
+
+		r.set("123", nil)
+		assert.Empty(t, events)
+		assert.Empty(t, r.List())
+	})
+}
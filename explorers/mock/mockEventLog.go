@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: exporterEventLog.go
+
+// Package mock_models is a generated GoMock package.
+package mock_models
+
+import (
+	reflect "reflect"
+
+	eventlog "github.com/LazarenkoA/prometheus_1C_exporter/explorers/eventlog"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIEventLogReader is a mock of IEventLogReader interface.
+type MockIEventLogReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockIEventLogReaderMockRecorder
+}
+
+// MockIEventLogReaderMockRecorder is the mock recorder for MockIEventLogReader.
+type MockIEventLogReaderMockRecorder struct {
+	mock *MockIEventLogReader
+}
+
+// NewMockIEventLogReader creates a new mock instance.
+func NewMockIEventLogReader(ctrl *gomock.Controller) *MockIEventLogReader {
+	mock := &MockIEventLogReader{ctrl: ctrl}
+	mock.recorder = &MockIEventLogReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIEventLogReader) EXPECT() *MockIEventLogReaderMockRecorder {
+	return m.recorder
+}
+
+// Poll mocks base method.
+func (m *MockIEventLogReader) Poll() ([]*eventlog.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Poll")
+	ret0, _ := ret[0].([]*eventlog.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Poll indicates an expected call of Poll.
+func (mr *MockIEventLogReaderMockRecorder) Poll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Poll", reflect.TypeOf((*MockIEventLogReader)(nil).Poll))
+}
@@ -0,0 +1,126 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: rasRunner.go
+
+// Package mock_models is a generated GoMock package.
+package mock_models
+
+import (
+	context "context"
+	reflect "reflect"
+
+	ras "github.com/LazarenkoA/prometheus_1C_exporter/explorers/ras"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIRASClient is a mock of IRASClient interface.
+type MockIRASClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockIRASClientMockRecorder
+}
+
+// MockIRASClientMockRecorder is the mock recorder for MockIRASClient.
+type MockIRASClientMockRecorder struct {
+	mock *MockIRASClient
+}
+
+// NewMockIRASClient creates a new mock instance.
+func NewMockIRASClient(ctrl *gomock.Controller) *MockIRASClient {
+	mock := &MockIRASClient{ctrl: ctrl}
+	mock.recorder = &MockIRASClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIRASClient) EXPECT() *MockIRASClientMockRecorder {
+	return m.recorder
+}
+
+// Clusters mocks base method.
+func (m *MockIRASClient) Clusters(ctx context.Context) ([]map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Clusters", ctx)
+	ret0, _ := ret[0].([]map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Clusters indicates an expected call of Clusters.
+func (mr *MockIRASClientMockRecorder) Clusters(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Clusters", reflect.TypeOf((*MockIRASClient)(nil).Clusters), ctx)
+}
+
+// Connections mocks base method.
+func (m *MockIRASClient) Connections(ctx context.Context, auth ras.ClusterAuth) ([]map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Connections", ctx, auth)
+	ret0, _ := ret[0].([]map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Connections indicates an expected call of Connections.
+func (mr *MockIRASClientMockRecorder) Connections(ctx, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Connections", reflect.TypeOf((*MockIRASClient)(nil).Connections), ctx, auth)
+}
+
+// InfobaseInfo mocks base method.
+func (m *MockIRASClient) InfobaseInfo(ctx context.Context, auth ras.ClusterAuth, infobase, user, pwd string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InfobaseInfo", ctx, auth, infobase, user, pwd)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InfobaseInfo indicates an expected call of InfobaseInfo.
+func (mr *MockIRASClientMockRecorder) InfobaseInfo(ctx, auth, infobase, user, pwd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InfobaseInfo", reflect.TypeOf((*MockIRASClient)(nil).InfobaseInfo), ctx, auth, infobase, user, pwd)
+}
+
+// InfobasesSummary mocks base method.
+func (m *MockIRASClient) InfobasesSummary(ctx context.Context, auth ras.ClusterAuth) ([]map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InfobasesSummary", ctx, auth)
+	ret0, _ := ret[0].([]map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InfobasesSummary indicates an expected call of InfobasesSummary.
+func (mr *MockIRASClientMockRecorder) InfobasesSummary(ctx, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InfobasesSummary", reflect.TypeOf((*MockIRASClient)(nil).InfobasesSummary), ctx, auth)
+}
+
+// Processes mocks base method.
+func (m *MockIRASClient) Processes(ctx context.Context, auth ras.ClusterAuth) ([]map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Processes", ctx, auth)
+	ret0, _ := ret[0].([]map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Processes indicates an expected call of Processes.
+func (mr *MockIRASClientMockRecorder) Processes(ctx, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Processes", reflect.TypeOf((*MockIRASClient)(nil).Processes), ctx, auth)
+}
+
+// Sessions mocks base method.
+func (m *MockIRASClient) Sessions(ctx context.Context, auth ras.ClusterAuth, licenses bool) ([]map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sessions", ctx, auth, licenses)
+	ret0, _ := ret[0].([]map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sessions indicates an expected call of Sessions.
+func (mr *MockIRASClientMockRecorder) Sessions(ctx, auth, licenses interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sessions", reflect.TypeOf((*MockIRASClient)(nil).Sessions), ctx, auth, licenses)
+}
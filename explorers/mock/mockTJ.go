@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: exporterTJ.go
+
+// Package mock_models is a generated GoMock package.
+package mock_models
+
+import (
+	reflect "reflect"
+
+	tj "github.com/LazarenkoA/prometheus_1C_exporter/explorers/tj"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockITJReader is a mock of ITJReader interface.
+type MockITJReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockITJReaderMockRecorder
+}
+
+// MockITJReaderMockRecorder is the mock recorder for MockITJReader.
+type MockITJReaderMockRecorder struct {
+	mock *MockITJReader
+}
+
+// NewMockITJReader creates a new mock instance.
+func NewMockITJReader(ctrl *gomock.Controller) *MockITJReader {
+	mock := &MockITJReader{ctrl: ctrl}
+	mock.recorder = &MockITJReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockITJReader) EXPECT() *MockITJReaderMockRecorder {
+	return m.recorder
+}
+
+// Poll mocks base method.
+func (m *MockITJReader) Poll() ([]*tj.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Poll")
+	ret0, _ := ret[0].([]*tj.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Poll indicates an expected call of Poll.
+func (mr *MockITJReaderMockRecorder) Poll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Poll", reflect.TypeOf((*MockITJReader)(nil).Poll))
+}
@@ -0,0 +1,128 @@
+package exporter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// конструкторы экспортеров, собираемых через RAC, которые можно использовать в модулях /probe
+var racExporters = map[string]func(s *settings.Settings) model.IExporter{
+	"client_lic":            func(s *settings.Settings) model.IExporter { return new(ExporterClientLic).Construct(s) },
+	"available_performance": func(s *settings.Settings) model.IExporter { return new(ExporterAvailablePerformance).Construct(s) },
+	"shedule_job":           func(s *settings.Settings) model.IExporter { return new(ExporterCheckSheduleJob).Construct(s) },
+	"session":               func(s *settings.Settings) model.IExporter { return new(ExporterSessions).Construct(s) },
+	"connect":               func(s *settings.Settings) model.IExporter { return new(ExporterConnects).Construct(s) },
+	"sessions_data":         func(s *settings.Settings) model.IExporter { return new(ExporterSessionsData).Construct(s) },
+	"locks":                 func(s *settings.Settings) model.IExporter { return new(ExporterLocks).Construct(s) },
+	"rphost":                func(s *settings.Settings) model.IExporter { return new(ExporterRphost).Construct(s) },
+	"server":                func(s *settings.Settings) model.IExporter { return new(ExporterServer).Construct(s) },
+	"infobase_state":        func(s *settings.Settings) model.IExporter { return new(ExporterInfobaseState).Construct(s) },
+	"counters":              func(s *settings.Settings) model.IExporter { return new(ExporterCounters).Construct(s) },
+	"session_analytics":     func(s *settings.Settings) model.IExporter { return new(ExporterSessionAnalytics).Construct(s) },
+}
+
+// Probe опрос произвольного RAS в стиле blackbox_exporter: /probe?target=host:port&module=name.
+// На каждый запрос создаются свои экспортеры и свой реестр метрик, после ответа экспортеры останавливаются
+func Probe(s *settings.Settings) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, fmt.Sprintf("Метод %q не поддерживается", r.Method), http.StatusInternalServerError)
+			return
+		}
+		logger.DefaultLogger.With("URL", r.URL.RequestURI()).Debug("Probe")
+
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "не заполнен параметр \"target\"", http.StatusBadRequest)
+			return
+		}
+
+		moduleName := r.URL.Query().Get("module")
+		module, ok := s.ProbeModules[moduleName]
+		if !ok {
+			http.Error(w, fmt.Sprintf("модуль %q не найден в настройках", moduleName), http.StatusBadRequest)
+			return
+		}
+
+		ps := probeSettings(s, target, module)
+		registry := prometheus.NewRegistry()
+
+		var exporters []model.IExporter
+		defer func() {
+			for _, ex := range exporters {
+				ex.Stop()
+			}
+		}()
+
+		for _, name := range module.Exporters {
+			ex, err := probeExporter(ps, strings.TrimSpace(name))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			exporters = append(exporters, ex)
+			if err := registry.Register(ex); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// у целевого RAS свой список баз, он получается сразу и используется только экспортерами этого запроса
+		infobases := newInfobaseRegistry(ps)
+		defer infobases.Stop()
+		if err := infobases.Refresh(); err != nil {
+			infobases.logger.With("target", target).Error(err)
+		}
+		for _, ex := range exporters {
+			if v, ok := ex.(InfobaseConsumer); ok {
+				v.SetInfobases(infobases)
+			}
+		}
+		if err := registry.Register(infobases); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// probeExporter встроенный RAC экспортер или пользовательский экспортер из настроек
+func probeExporter(s *settings.Settings, name string) (model.IExporter, error) {
+	if construct, ok := racExporters[name]; ok {
+		return construct(s), nil
+	}
+
+	if slices.Contains(s.GetCustomExporters(), name) {
+		return new(ExporterCustom).Construct(s, name)
+	}
+
+	return nil, fmt.Errorf("экспортер %q не поддерживается в /probe", name)
+}
+
+// probeSettings копия настроек в которой единственный RAS - target
+func probeSettings(s *settings.Settings, target string, module *settings.ProbeModule) *settings.Settings {
+	cluster := &settings.Cluster{
+		Host:  target,
+		Login: module.Login,
+		Pass:  module.Pass,
+		Name:  module.Cluster,
+	}
+	if host, port, err := net.SplitHostPort(target); err == nil {
+		cluster.Host, cluster.Port = host, port
+	}
+
+	ps := *s
+	ps.Clusters = []*settings.Cluster{cluster}
+	return &ps
+}
@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Probe(t *testing.T) {
+	logger.InitLogger("", 4)
+
+	s := &settings.Settings{
+		ProbeModules: map[string]*settings.ProbeModule{
+			"lic": {Exporters: []string{"client_lic"}, Login: "admin"},
+			"bad": {Exporters: []string{"cpu"}},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		url  string
+		code int
+	}{
+		"no target":      {url: "/probe?module=lic", code: http.StatusBadRequest},
+		"unknown module": {url: "/probe?target=srv:1545&module=test", code: http.StatusBadRequest},
+		"not rac":        {url: "/probe?target=srv:1545&module=bad", code: http.StatusBadRequest},
+		"pass":           {url: "/probe?target=srv:1545&module=lic", code: http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			responseRecorder := httptest.NewRecorder()
+			Probe(s).ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, test.url, nil))
+			assert.Equal(t, test.code, responseRecorder.Code)
+		})
+	}
+}
+
+func Test_probeSettings(t *testing.T) {
+	s := &settings.Settings{Clusters: []*settings.Cluster{{Host: "main"}}}
+
+	ps := probeSettings(s, "srv:1645", &settings.ProbeModule{Login: "admin", Pass: "123", Cluster: "main"})
+	if assert.Len(t, ps.GetClusters(), 1) {
+		cl := ps.GetClusters()[0]
+		assert.Equal(t, "srv", cl.Host)
+		assert.Equal(t, "1645", cl.Port)
+		assert.Equal(t, "admin", cl.Login)
+		assert.Equal(t, "main", cl.Name)
+	}
+
+	// исходные настройки не меняются
+	assert.Equal(t, "main", s.GetClusters()[0].Host)
+
+	ps = probeSettings(s, "srv", &settings.ProbeModule{})
+	assert.Equal(t, "srv:1545", ps.GetClusters()[0].RASHostPort())
+}
@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// таймаут выполнения команды rac, если у экспортера не задано свойство Timeout
+const defaultRACTimeout = time.Second * 15
+
+// racPool ограничивает количество одновременных обращений к RAS всеми экспортерами,
+// команды сверх лимита ждут в очереди
+type racPool struct {
+	mx  sync.RWMutex
+	sem chan struct{} // nil - без ограничений
+}
+
+var racLimiter = new(racPool)
+
+// SetRACConcurrency задает максимальное количество одновременных обращений к RAS, 0 - без ограничений.
+// Уже выполняющиеся команды освобождают место в прежнем пуле
+func SetRACConcurrency(n int) {
+	racLimiter.resize(n)
+}
+
+func (p *racPool) resize(n int) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if n > 0 {
+		p.sem = make(chan struct{}, n)
+	} else {
+		p.sem = nil
+	}
+	selfMetrics.racConcurrency.Set(float64(n))
+}
+
+// limit максимальное количество одновременных обращений, 0 - без ограничений
+func (p *racPool) limit() int {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+
+	return cap(p.sem)
+}
+
+// acquire занимает место в пуле, возвращает функцию освобождения места
+func (p *racPool) acquire(ctx context.Context, exporter string) (func(), error) {
+	p.mx.RLock()
+	sem := p.sem
+	p.mx.RUnlock()
+
+	start := time.Now()
+	if sem != nil {
+		selfMetrics.racQueue.Inc()
+		select {
+		case sem <- struct{}{}:
+			selfMetrics.racQueue.Dec()
+		case <-ctx.Done():
+			selfMetrics.racQueue.Dec()
+			return nil, ctx.Err()
+		}
+	}
+
+	selfMetrics.racQueueWait.WithLabelValues(exporter).Observe(time.Since(start).Seconds())
+	selfMetrics.racInFlight.Inc()
+
+	return func() {
+		selfMetrics.racInFlight.Dec()
+		if sem != nil {
+			<-sem
+		}
+	}, nil
+}
+
+// timeoutRunner IRunner, которому можно передать таймаут выполнения команды
+type timeoutRunner interface {
+	RunTimeout(cmd *exec.Cmd, timeout time.Duration) (string, error)
+}
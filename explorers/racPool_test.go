@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type testTimeoutRunner struct {
+	timeout           atomic.Int64
+	running, inFlight atomic.Int32
+}
+
+func (r *testTimeoutRunner) Run(cmd *exec.Cmd) (string, error) {
+	return r.RunTimeout(cmd, defaultRACTimeout)
+}
+
+func (r *testTimeoutRunner) RunTimeout(_ *exec.Cmd, timeout time.Duration) (string, error) {
+	r.timeout.Store(int64(timeout))
+
+	n := r.running.Add(1)
+	defer r.running.Add(-1)
+	for {
+		v := r.inFlight.Load()
+		if n <= v || r.inFlight.CompareAndSwap(v, n) {
+			break
+		}
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	return "", nil
+}
+
+func Test_racPool(t *testing.T) {
+	defer SetRACConcurrency(0)
+	assert.Zero(t, racLimiter.limit())
+
+	s := new(settings.Settings)
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: test
+    Property:
+      Timeout: 40s
+`), s))
+
+	runner := new(testTimeoutRunner)
+	exp := &BaseExporter{name: "test", logger: logger.NopLogger.Named("test"), runner: runner, settings: s, ctx: context.Background()}
+
+	t.Run("timeout", func(t *testing.T) {
+		exp.run(exec.Command("rac", "srv:1545", "session", "list"))
+		assert.Equal(t, time.Second*40, time.Duration(runner.timeout.Load()))
+	})
+	t.Run("concurrency", func(t *testing.T) {
+		SetRACConcurrency(2)
+		assert.Equal(t, 2., testutil.ToFloat64(selfMetrics.racConcurrency))
+		assert.Equal(t, 2, racLimiter.limit())
+
+		wg := new(sync.WaitGroup)
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				exp.run(exec.Command("rac", "srv:1545", "session", "list"))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(2), runner.inFlight.Load())
+		assert.Zero(t, testutil.ToFloat64(selfMetrics.racQueue))
+		assert.Zero(t, testutil.ToFloat64(selfMetrics.racInFlight))
+	})
+	t.Run("cancel", func(t *testing.T) {
+		SetRACConcurrency(1)
+
+		release, err := racLimiter.acquire(context.Background(), "test")
+		assert.NoError(t, err)
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+
+		_, err = racLimiter.acquire(ctx, "test")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,339 @@
+package ras
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// тип значения параметра соединения
+const paramTypeInt byte = 0x03
+
+// ClusterAuth идентификатор кластера и учетные данные администратора кластера
+type ClusterAuth struct {
+	Cluster  string
+	User     string
+	Password string
+}
+
+// ServerError ошибка, которую вернул сам RAS (например неверный пароль), соединение при этом остается рабочим
+type ServerError struct {
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return "RAS вернул ошибку: " + e.Message
+}
+
+// Client клиент бинарного протокола RAS, позволяет получать данные кластера без запуска rac.
+// Соединение устанавливается при первом запросе и переиспользуется, запросы выполняются последовательно
+type Client struct {
+	addr    string
+	timeout time.Duration
+
+	mx       sync.Mutex
+	conn     net.Conn
+	reader   *bufio.Reader
+	endpoint int
+}
+
+func NewClient(addr string, timeout time.Duration) *Client {
+	return &Client{
+		addr:    addr,
+		timeout: timeout,
+	}
+}
+
+// Clusters аналог rac cluster list
+func (c *Client) Clusters(ctx context.Context) ([]map[string]string, error) {
+	return c.list(ctx, nil, msgGetClusters, msgGetClustersResponse, clusterSchema)
+}
+
+// Sessions аналог rac session list, если licenses = true то аналог rac session list --licenses
+func (c *Client) Sessions(ctx context.Context, auth ClusterAuth, licenses bool) (result []map[string]string, err error) {
+	err = c.do(ctx, func() error {
+		d, err := c.call(&auth, msgGetSessions, msgGetSessionsResponse, clusterPayload(auth))
+		if err != nil {
+			return err
+		}
+
+		for i, count := 0, d.size(); i < count && d.err == nil; i++ {
+			session := decodeObject(d, sessionSchema)
+			for j, lcount := 0, d.size(); j < lcount && d.err == nil; j++ {
+				license := decodeObject(d, licenseSchema)
+				if licenses {
+					for _, key := range sessionLicenseKeys {
+						license[key] = session[key]
+					}
+					result = append(result, license)
+				}
+			}
+
+			if !licenses {
+				result = append(result, session)
+			}
+		}
+
+		return d.err
+	})
+
+	return result, err
+}
+
+// Connections аналог rac connection list
+func (c *Client) Connections(ctx context.Context, auth ClusterAuth) ([]map[string]string, error) {
+	return c.list(ctx, &auth, msgGetConnectionsShort, msgGetConnectionsRsp, connectionSchema)
+}
+
+// Processes аналог rac process list
+func (c *Client) Processes(ctx context.Context, auth ClusterAuth) ([]map[string]string, error) {
+	return c.list(ctx, &auth, msgGetProcesses, msgGetProcessesResponse, processSchema)
+}
+
+// InfobasesSummary аналог rac infobase summary list
+func (c *Client) InfobasesSummary(ctx context.Context, auth ClusterAuth) ([]map[string]string, error) {
+	return c.list(ctx, &auth, msgGetInfobasesShort, msgGetInfobasesShortRsp, infobaseSummarySchema)
+}
+
+// InfobaseInfo аналог rac infobase info, для получения полной информации нужны учетные данные пользователя ИБ
+func (c *Client) InfobaseInfo(ctx context.Context, auth ClusterAuth, infobase, user, pwd string) (result map[string]string, err error) {
+	err = c.do(ctx, func() error {
+		ibAuth := new(encoder)
+		if err := ibAuth.uuid(auth.Cluster); err != nil {
+			return err
+		}
+		ibAuth.string(user)
+		ibAuth.string(pwd)
+
+		if _, err := c.call(&auth, msgAddAuthentication, 0, ibAuth.bytes()); err != nil {
+			return err
+		}
+
+		payload := new(encoder)
+		payload.buf.Write(clusterPayload(auth))
+		if err := payload.uuid(infobase); err != nil {
+			return err
+		}
+
+		d, err := c.call(nil, msgGetInfobaseInfo, msgGetInfobaseInfoRsp, payload.bytes())
+		if err != nil {
+			return err
+		}
+
+		result = decodeObject(d, infobaseSchema)
+		return d.err
+	})
+
+	return result, err
+}
+
+func (c *Client) Close() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.reset()
+}
+
+func (c *Client) list(ctx context.Context, auth *ClusterAuth, reqType, respType byte, schema []field) (result []map[string]string, err error) {
+	err = c.do(ctx, func() error {
+		var payload []byte
+		if auth != nil {
+			payload = clusterPayload(*auth)
+		}
+
+		d, err := c.call(auth, reqType, respType, payload)
+		if err != nil {
+			return err
+		}
+
+		count := d.size()
+		result = make([]map[string]string, 0, max(count, 0))
+		for i := 0; i < count && d.err == nil; i++ {
+			result = append(result, decodeObject(d, schema))
+		}
+
+		return d.err
+	})
+
+	return result, err
+}
+
+// do выполняет f в рамках одного соединения, при транспортной ошибке соединение закрывается и будет переоткрыто при следующем запросе
+func (c *Client) do(ctx context.Context, f func() error) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+
+	if err := c.connect(deadline); err != nil {
+		c.reset()
+		return errors.Wrapf(err, "ошибка подключения к RAS %s", c.addr)
+	}
+
+	c.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	err := f()
+
+	var srvErr *ServerError
+	if err != nil && !errors.As(err, &srvErr) {
+		c.reset()
+	}
+
+	return err
+}
+
+func (c *Client) connect(deadline time.Time) error {
+	if c.conn != nil {
+		return nil
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.Dial("tcp", c.addr)
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.conn.SetDeadline(deadline)
+
+	if _, err := c.conn.Write(negotiate()); err != nil {
+		return err
+	}
+
+	params := new(encoder)
+	params.size(1)
+	params.string("connect.timeout")
+	params.byte(paramTypeInt)
+	params.int(2000)
+	if err := writePacket(c.conn, packetConnect, params.bytes()); err != nil {
+		return err
+	}
+	if _, err := c.expect(packetConnectAck); err != nil {
+		return err
+	}
+
+	open := new(encoder)
+	open.string(serviceName)
+	open.string(serviceVersion)
+	open.size(0)
+	if err := writePacket(c.conn, packetEndpointOpen, open.bytes()); err != nil {
+		return err
+	}
+
+	p, err := c.expect(packetEndpointOpenAck)
+	if err != nil {
+		return err
+	}
+
+	d := newDecoder(p.body)
+	d.string() // имя сервиса
+	d.string() // версия
+	c.endpoint = d.size()
+
+	return d.err
+}
+
+func (c *Client) reset() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn, c.reader = nil, nil
+	return err
+}
+
+func (c *Client) expect(typ byte) (*packet, error) {
+	p, err := readPacket(c.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.typ != typ {
+		return nil, fmt.Errorf("неожиданный тип пакета %d, ожидался %d", p.typ, typ)
+	}
+
+	return p, nil
+}
+
+// call отправляет сообщение и читает ответ, если respType = 0 ожидается пустой ответ. Перед запросом при необходимости выполняется аутентификация в кластере
+func (c *Client) call(auth *ClusterAuth, reqType, respType byte, payload []byte) (*decoder, error) {
+	if auth != nil {
+		e := new(encoder)
+		e.buf.Write(clusterPayload(*auth))
+		e.string(auth.User)
+		e.string(auth.Password)
+
+		if _, err := c.call(nil, msgAuthenticateCluster, 0, e.bytes()); err != nil {
+			return nil, errors.Wrap(err, "ошибка аутентификации в кластере")
+		}
+	}
+
+	msg := new(encoder)
+	msg.size(c.endpoint)
+	msg.short(0) // формат
+	msg.byte(kindMessage)
+	msg.byte(reqType)
+	msg.buf.Write(payload)
+
+	if err := writePacket(c.conn, packetEndpointMessage, msg.bytes()); err != nil {
+		return nil, err
+	}
+
+	p, err := readPacket(c.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDecoder(p.body)
+	switch p.typ {
+	case packetEndpointMessage:
+	case packetEndpointFailure:
+		d.string() // версия сервиса
+		d.size()   // endpoint
+		return nil, fmt.Errorf("RAS закрыл endpoint: %s", d.string())
+	default:
+		return nil, fmt.Errorf("неожиданный тип пакета %d", p.typ)
+	}
+
+	d.size()  // endpoint
+	d.short() // формат
+	switch kind := d.byte(); kind {
+	case kindVoid:
+		if respType != 0 {
+			return nil, errors.New("RAS вернул пустой ответ")
+		}
+	case kindException:
+		d.string() // идентификатор сервиса
+		return nil, &ServerError{Message: d.string()}
+	case kindMessage:
+		if t := d.byte(); t != respType {
+			return nil, fmt.Errorf("неожиданный тип сообщения %#x, ожидался %#x", t, respType)
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный вид сообщения %d", kind)
+	}
+
+	return d, d.err
+}
+
+func clusterPayload(auth ClusterAuth) []byte {
+	e := new(encoder)
+	if err := e.uuid(auth.Cluster); err != nil {
+		// некорректный идентификатор кластера RAS все равно не примет, отправляем пустой
+		e.uuid("00000000-0000-0000-0000-000000000000")
+	}
+
+	return e.bytes()
+}
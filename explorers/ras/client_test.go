@@ -0,0 +1,240 @@
+package ras
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCluster = "ee5adb9a-14fa-11e9-7589-005056032522"
+
+// fakeServer минимальная реализация RAS для тестов
+type fakeServer struct {
+	listener net.Listener
+	sessions []map[string]string
+	licenses map[string][]map[string]string
+	password string
+}
+
+func (s *fakeServer) start(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s.listener = l
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	d := &decoder{r: r}
+	if d.int() != protocolMagic {
+		return
+	}
+	d.short()
+	d.short()
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch p.typ {
+		case packetConnect:
+			writePacket(conn, packetConnectAck, nil)
+		case packetEndpointOpen:
+			e := new(encoder)
+			e.string(serviceName)
+			e.string(serviceVersion)
+			e.size(1)
+			writePacket(conn, packetEndpointOpenAck, e.bytes())
+		case packetEndpointMessage:
+			writePacket(conn, packetEndpointMessage, s.handle(newDecoder(p.body)))
+		}
+	}
+}
+
+func (s *fakeServer) handle(d *decoder) []byte {
+	endpoint := d.size()
+	d.short()
+	d.byte()
+
+	resp := new(encoder)
+	resp.size(endpoint)
+	resp.short(0)
+
+	switch d.byte() {
+	case msgAuthenticateCluster:
+		d.uuid()
+		d.string()
+		if d.string() != s.password {
+			resp.byte(kindException)
+			resp.string(serviceName)
+			resp.string("Администратор кластера не аутентифицирован")
+			return resp.bytes()
+		}
+		resp.byte(kindVoid)
+	case msgAddAuthentication:
+		resp.byte(kindVoid)
+	case msgGetClusters:
+		resp.byte(kindMessage)
+		resp.byte(msgGetClustersResponse)
+		resp.size(1)
+		encodeObject(resp, clusterSchema, map[string]string{"cluster": testCluster, "name": "Локальный кластер", "host": "srv", "port": "1541"})
+	case msgGetSessions:
+		resp.byte(kindMessage)
+		resp.byte(msgGetSessionsResponse)
+		resp.size(len(s.sessions))
+		for _, ses := range s.sessions {
+			encodeObject(resp, sessionSchema, ses)
+			resp.size(len(s.licenses[ses["session"]]))
+			for _, lic := range s.licenses[ses["session"]] {
+				encodeObject(resp, licenseSchema, lic)
+			}
+		}
+	case msgGetInfobaseInfo:
+		resp.byte(kindMessage)
+		resp.byte(msgGetInfobaseInfoRsp)
+		encodeObject(resp, infobaseSchema, map[string]string{"infobase": d.uuid(), "name": "hrm", "scheduled-jobs-deny": "on"})
+	default:
+		resp.byte(kindException)
+		resp.string(serviceName)
+		resp.string("не поддерживается")
+	}
+
+	return resp.bytes()
+}
+
+func Test_Client(t *testing.T) {
+	srv := &fakeServer{licenses: map[string][]map[string]string{}}
+	srv.sessions = []map[string]string{
+		{
+			"session":        "f028ea3e-5402-4f15-8194-34cb70bca0c4",
+			"session-id":     "590",
+			"infobase":       "899bbbb8-7ffb-4e91-9b3b-8638793108ec",
+			"user-name":      "Иванов",
+			"app-id":         "1CV8C",
+			"memory-current": "10",
+			"started-at":     "2025-03-03T14:17:30",
+			"hibernate":      "no",
+		},
+	}
+	srv.licenses["f028ea3e-5402-4f15-8194-34cb70bca0c4"] = []map[string]string{
+		{"series": "8100886831", "license-type": "soft", "max-users-cur": "500", "rmngr-address": "host1"},
+	}
+	srv.start(t)
+
+	cl := NewClient(srv.listener.Addr().String(), time.Second)
+	defer cl.Close()
+
+	ctx := context.Background()
+	auth := ClusterAuth{Cluster: testCluster}
+
+	t.Run("clusters", func(t *testing.T) {
+		clusters, err := cl.Clusters(ctx)
+		require.NoError(t, err)
+		require.Len(t, clusters, 1)
+		assert.Equal(t, testCluster, clusters[0]["cluster"])
+		assert.Equal(t, "\"Локальный кластер\"", clusters[0]["name"])
+		assert.Equal(t, "1541", clusters[0]["port"])
+	})
+	t.Run("sessions", func(t *testing.T) {
+		sessions, err := cl.Sessions(ctx, auth, false)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "590", sessions[0]["session-id"])
+		assert.Equal(t, "899bbbb8-7ffb-4e91-9b3b-8638793108ec", sessions[0]["infobase"])
+		assert.Equal(t, "10", sessions[0]["memory-current"])
+		assert.Equal(t, "2025-03-03T14:17:30", sessions[0]["started-at"])
+		assert.Equal(t, "no", sessions[0]["hibernate"])
+	})
+	t.Run("licenses", func(t *testing.T) {
+		licenses, err := cl.Sessions(ctx, auth, true)
+		require.NoError(t, err)
+		require.Len(t, licenses, 1)
+		assert.Equal(t, "Иванов", licenses[0]["user-name"])
+		assert.Equal(t, "soft", licenses[0]["license-type"])
+		assert.Equal(t, "host1", licenses[0]["rmngr-address"])
+		assert.Equal(t, "500", licenses[0]["max-users-cur"])
+	})
+	t.Run("infobase info", func(t *testing.T) {
+		info, err := cl.InfobaseInfo(ctx, auth, "899bbbb8-7ffb-4e91-9b3b-8638793108ec", "user", "pwd")
+		require.NoError(t, err)
+		assert.Equal(t, "on", info["scheduled-jobs-deny"])
+		assert.Equal(t, "off", info["sessions-deny"])
+	})
+	t.Run("server error", func(t *testing.T) {
+		_, err := cl.Processes(ctx, auth)
+		var srvErr *ServerError
+		assert.ErrorAs(t, err, &srvErr)
+
+		// после ошибки сервера соединение остается рабочим
+		_, err = cl.Clusters(ctx)
+		assert.NoError(t, err)
+	})
+	t.Run("auth error", func(t *testing.T) {
+		secured := (&fakeServer{password: "123"}).start(t)
+		cl := NewClient(secured.listener.Addr().String(), time.Second)
+		defer cl.Close()
+
+		_, err := cl.Sessions(ctx, auth, false)
+		assert.ErrorContains(t, err, "не аутентифицирован")
+	})
+	t.Run("connection error", func(t *testing.T) {
+		bad := NewClient("127.0.0.1:1", time.Second)
+		_, err := bad.Clusters(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func Test_size(t *testing.T) {
+	for _, n := range []int{0, 1, 63, 64, 127, 8191, 8192, 1 << 20} {
+		e := new(encoder)
+		e.size(n)
+		assert.Equal(t, n, newDecoder(e.bytes()).size())
+	}
+
+	assert.Equal(t, -1, newDecoder([]byte{0x80}).size())
+
+	// бесконечная последовательность продолжения размера
+	d := newDecoder(append([]byte{0x7f}, bytes.Repeat([]byte{0xff}, 16)...))
+	d.size()
+	assert.Error(t, d.err)
+
+	// строка длиннее оставшихся данных
+	e := new(encoder)
+	e.size(1 << 20)
+	d = newDecoder(e.bytes())
+	assert.Equal(t, "", d.string())
+	assert.Error(t, d.err)
+}
+
+func Test_readPacketLimit(t *testing.T) {
+	e := new(encoder)
+	e.byte(packetEndpointMessage)
+	e.size(maxPacketSize + 1)
+
+	_, err := readPacket(bufio.NewReader(bytes.NewReader(e.bytes())))
+	assert.EqualError(t, err, fmt.Sprintf("размер пакета RAS %d больше допустимого %d", maxPacketSize+1, maxPacketSize))
+}
@@ -0,0 +1,205 @@
+package ras
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// смещение (в миллисекундах) между 0001-01-01 и началом эпохи unix, даты в протоколе передаются от 0001-01-01 с точностью до 0.1 мс
+const dateOffset = 62135596800000
+
+// encoder сериализует значения в формате протокола RAS (big-endian)
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) byte(v byte) {
+	e.buf.WriteByte(v)
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.byte(1)
+	} else {
+		e.byte(0)
+	}
+}
+
+func (e *encoder) short(v uint16) {
+	e.buf.Write(binary.BigEndian.AppendUint16(nil, v))
+}
+
+func (e *encoder) int(v uint32) {
+	e.buf.Write(binary.BigEndian.AppendUint32(nil, v))
+}
+
+func (e *encoder) long(v uint64) {
+	e.buf.Write(binary.BigEndian.AppendUint64(nil, v))
+}
+
+func (e *encoder) double(v float64) {
+	e.long(math.Float64bits(v))
+}
+
+// size размер в формате "nullable size": в первом байте 6 бит данных и флаг продолжения 0x40, в последующих по 7 бит и флаг 0x80
+func (e *encoder) size(v int) {
+	b := byte(v & 0x3f)
+	v >>= 6
+	if v > 0 {
+		b |= 0x40
+	}
+	e.byte(b)
+
+	for v > 0 {
+		b = byte(v & 0x7f)
+		v >>= 7
+		if v > 0 {
+			b |= 0x80
+		}
+		e.byte(b)
+	}
+}
+
+func (e *encoder) string(v string) {
+	e.size(len(v))
+	e.buf.WriteString(v)
+}
+
+func (e *encoder) uuid(v string) error {
+	raw, err := hex.DecodeString(strings.ReplaceAll(v, "-", ""))
+	if err != nil || len(raw) != 16 {
+		return fmt.Errorf("некорректный идентификатор %q", v)
+	}
+
+	e.buf.Write(raw)
+	return nil
+}
+
+func (e *encoder) time(v time.Time) {
+	if v.IsZero() {
+		e.long(0)
+		return
+	}
+
+	e.long(uint64((v.UnixMilli() + dateOffset) * 10))
+}
+
+func (e *encoder) bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// decoder читает значения протокола RAS, первая ошибка запоминается и все последующие чтения игнорируются
+type decoder struct {
+	r   io.Reader
+	err error
+}
+
+func newDecoder(data []byte) *decoder {
+	return &decoder{r: bytes.NewReader(data)}
+}
+
+func (d *decoder) read(n int) []byte {
+	// размер приходит по сети, буфер под заведомо некорректный размер не выделяется
+	if n < 0 || n > maxPacketSize {
+		if d.err == nil {
+			d.err = errors.Errorf("некорректный размер данных в ответе RAS: %d", n)
+		}
+		return nil
+	}
+	if r, ok := d.r.(*bytes.Reader); ok && n > r.Len() && d.err == nil {
+		d.err = errors.Wrap(io.ErrUnexpectedEOF, "ошибка чтения ответа RAS")
+	}
+	if d.err != nil {
+		// после ошибки значения не используются, пустой буфер нужен только для чтения чисел фиксированного размера
+		return make([]byte, min(n, 16))
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		d.err = errors.Wrap(err, "ошибка чтения ответа RAS")
+	}
+
+	return buf
+}
+
+func (d *decoder) byte() byte {
+	return d.read(1)[0]
+}
+
+func (d *decoder) bool() bool {
+	return d.byte() != 0
+}
+
+func (d *decoder) short() uint16 {
+	return binary.BigEndian.Uint16(d.read(2))
+}
+
+func (d *decoder) int() uint32 {
+	return binary.BigEndian.Uint32(d.read(4))
+}
+
+func (d *decoder) long() uint64 {
+	return binary.BigEndian.Uint64(d.read(8))
+}
+
+func (d *decoder) double() float64 {
+	return math.Float64frombits(d.long())
+}
+
+// size возвращает -1 если передан null
+func (d *decoder) size() int {
+	b := d.byte()
+	if b&0x80 != 0 {
+		return -1
+	}
+
+	v := int(b & 0x3f)
+	shift := 6
+	for more := b&0x40 != 0; more && d.err == nil; shift += 7 {
+		// размер больше maxPacketSize не бывает, длинная последовательность - признак поврежденных данных
+		if shift > 34 {
+			d.err = errors.New("некорректный размер данных в ответе RAS")
+			return 0
+		}
+
+		b = d.byte()
+		v |= int(b&0x7f) << shift
+		more = b&0x80 != 0
+	}
+
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.size()
+	if n <= 0 {
+		return ""
+	}
+
+	if v := d.read(n); d.err == nil {
+		return string(v)
+	}
+	return ""
+}
+
+func (d *decoder) uuid() string {
+	raw := hex.EncodeToString(d.read(16))
+	return raw[:8] + "-" + raw[8:12] + "-" + raw[12:16] + "-" + raw[16:20] + "-" + raw[20:]
+}
+
+func (d *decoder) time() time.Time {
+	v := int64(d.long())
+	if v == 0 {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(v/10 - dateOffset).UTC()
+}
@@ -0,0 +1,110 @@
+package ras
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// типы пакетов транспортного уровня
+const (
+	packetNegotiate byte = iota
+	packetConnect
+	packetConnectAck
+	packetStartTLS
+	packetDisconnect
+	packetSaslNegotiate
+	packetSaslAuth
+	packetSaslChallenge
+	packetSaslSuccess
+	packetSaslFailure
+	packetSaslAbort
+	packetEndpointOpen
+	packetEndpointOpenAck
+	packetEndpointClose
+	packetEndpointMessage
+	packetEndpointFailure
+	packetKeepAlive
+)
+
+// вид сообщения внутри packetEndpointMessage
+const (
+	kindVoid byte = iota
+	kindMessage
+	kindException
+)
+
+// типы сообщений сервиса администрирования кластера (протокол версии 10.0)
+const (
+	msgAuthenticateCluster  byte = 0x09
+	msgAddAuthentication    byte = 0x0a
+	msgGetClusters          byte = 0x0b
+	msgGetClustersResponse  byte = 0x0c
+	msgGetProcesses         byte = 0x1d
+	msgGetProcessesResponse byte = 0x1e
+	msgGetInfobasesShort    byte = 0x2a
+	msgGetInfobasesShortRsp byte = 0x2b
+	msgGetInfobaseInfo      byte = 0x30
+	msgGetInfobaseInfoRsp   byte = 0x31
+	msgGetConnectionsShort  byte = 0x32
+	msgGetConnectionsRsp    byte = 0x33
+	msgGetSessions          byte = 0x41
+	msgGetSessionsResponse  byte = 0x42
+)
+
+const (
+	protocolMagic   uint32 = 0x1c535750
+	protocolVersion uint16 = 0x0100
+	codecVersion    uint16 = 0x0100
+
+	serviceName    = "v8.service.Admin.Cluster"
+	serviceVersion = "10.0"
+
+	// максимальный размер пакета, больше не бывает даже у списка сеансов большого кластера.
+	// Размер приходит по сети, ограничение защищает от выделения памяти по ответу поврежденного или чужого сервиса
+	maxPacketSize = 64 << 20
+)
+
+type packet struct {
+	typ  byte
+	body []byte
+}
+
+func writePacket(w io.Writer, typ byte, body []byte) error {
+	e := new(encoder)
+	e.byte(typ)
+	e.size(len(body))
+	e.buf.Write(body)
+
+	_, err := w.Write(e.bytes())
+	return err
+}
+
+func readPacket(r *bufio.Reader) (*packet, error) {
+	d := &decoder{r: r}
+
+	p := &packet{typ: d.byte()}
+	n := d.size()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if n > maxPacketSize {
+		return nil, errors.Errorf("размер пакета RAS %d больше допустимого %d", n, maxPacketSize)
+	}
+	if n > 0 {
+		p.body = d.read(n)
+	}
+
+	return p, errors.Wrap(d.err, "ошибка чтения пакета")
+}
+
+// negotiate первое сообщение после установки соединения, передается без заголовка пакета
+func negotiate() []byte {
+	e := new(encoder)
+	e.int(protocolMagic)
+	e.short(protocolVersion)
+	e.short(codecVersion)
+
+	return e.bytes()
+}
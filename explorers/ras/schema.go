@@ -0,0 +1,295 @@
+package ras
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+type fieldType byte
+
+const (
+	tString fieldType = iota
+	tUUID
+	tBool
+	tShort
+	tInt
+	tLong
+	tDouble
+	tTime
+)
+
+const timeLayout = "2006-01-02T15:04:05"
+
+// field описание поля объекта, name совпадает с именем поля в выводе rac
+type field struct {
+	name string
+	typ  fieldType
+	// представление значений перечислений (для tInt) или булевых полей (для tBool: [ложь, истина]), если не задано выводится число или yes/no
+	enum []string
+}
+
+var (
+	onOff  = []string{"off", "on"}
+	yesNo  = []string{"no", "yes"}
+	access = []string{"deny", "allow"}
+)
+
+var clusterSchema = []field{
+	{name: "cluster", typ: tUUID},
+	{name: "expiration-timeout", typ: tInt},
+	{name: "host", typ: tString},
+	{name: "lifetime-limit", typ: tInt},
+	{name: "port", typ: tShort},
+	{name: "max-memory-size", typ: tInt},
+	{name: "max-memory-time-limit", typ: tInt},
+	{name: "name", typ: tString},
+	{name: "security-level", typ: tInt},
+	{name: "session-fault-tolerance-level", typ: tInt},
+	{name: "load-balancing-mode", typ: tInt, enum: []string{"performance", "memory"}},
+	{name: "errors-count-threshold", typ: tInt},
+	{name: "kill-problem-processes", typ: tBool},
+	{name: "kill-by-memory-with-dump", typ: tBool},
+}
+
+var sessionSchema = []field{
+	{name: "session", typ: tUUID},
+	{name: "app-id", typ: tString},
+	{name: "blocked-by-dbms", typ: tInt},
+	{name: "blocked-by-ls", typ: tInt},
+	{name: "bytes-all", typ: tLong},
+	{name: "bytes-last-5min", typ: tLong},
+	{name: "calls-all", typ: tInt},
+	{name: "calls-last-5min", typ: tLong},
+	{name: "connection", typ: tUUID},
+	{name: "dbms-bytes-all", typ: tLong},
+	{name: "dbms-bytes-last-5min", typ: tLong},
+	{name: "db-proc-info", typ: tString},
+	{name: "db-proc-took", typ: tInt},
+	{name: "db-proc-took-at", typ: tTime},
+	{name: "duration-all", typ: tInt},
+	{name: "duration-all-dbms", typ: tInt},
+	{name: "duration-current", typ: tInt},
+	{name: "duration-current-dbms", typ: tInt},
+	{name: "duration-last-5min", typ: tLong},
+	{name: "duration-last-5min-dbms", typ: tLong},
+	{name: "host", typ: tString},
+	{name: "infobase", typ: tUUID},
+	{name: "last-active-at", typ: tTime},
+	{name: "hibernate", typ: tBool},
+	{name: "passive-session-hibernate-time", typ: tInt},
+	{name: "hibernate-session-terminate-time", typ: tInt},
+	{name: "locale", typ: tString},
+	{name: "process", typ: tUUID},
+	{name: "session-id", typ: tInt},
+	{name: "started-at", typ: tTime},
+	{name: "user-name", typ: tString},
+	{name: "memory-current", typ: tLong},
+	{name: "memory-last-5min", typ: tLong},
+	{name: "memory-total", typ: tLong},
+	{name: "read-current", typ: tLong},
+	{name: "read-last-5min", typ: tLong},
+	{name: "read-total", typ: tLong},
+	{name: "write-current", typ: tLong},
+	{name: "write-last-5min", typ: tLong},
+	{name: "write-total", typ: tLong},
+	{name: "duration-current-service", typ: tInt},
+	{name: "duration-last-5min-service", typ: tLong},
+	{name: "duration-all-service", typ: tInt},
+	{name: "current-service-name", typ: tString},
+	{name: "cpu-time-current", typ: tLong},
+	{name: "cpu-time-last-5min", typ: tLong},
+	{name: "cpu-time-total", typ: tLong},
+	{name: "data-separation", typ: tString},
+	{name: "client-ip", typ: tString},
+}
+
+// поля сеанса, которые rac выводит вместе с лицензией (session list --licenses)
+var sessionLicenseKeys = []string{"session", "user-name", "host", "app-id", "started-at", "last-active-at"}
+
+var licenseSchema = []field{
+	{name: "full-name", typ: tString},
+	{name: "series", typ: tString},
+	{name: "issued-by-server", typ: tBool},
+	{name: "license-type", typ: tInt, enum: []string{"soft", "HASP"}},
+	{name: "net", typ: tBool},
+	{name: "max-users-all", typ: tInt},
+	{name: "max-users-cur", typ: tInt},
+	{name: "rmngr-address", typ: tString},
+	{name: "rmngr-port", typ: tInt},
+	{name: "rmngr-pid", typ: tString},
+	{name: "short-presentation", typ: tString},
+	{name: "full-presentation", typ: tString},
+}
+
+var connectionSchema = []field{
+	{name: "connection", typ: tUUID},
+	{name: "application", typ: tString},
+	{name: "blocked-by-ls", typ: tInt},
+	{name: "connected-at", typ: tTime},
+	{name: "conn-id", typ: tInt},
+	{name: "host", typ: tString},
+	{name: "infobase", typ: tUUID},
+	{name: "process", typ: tUUID},
+	{name: "session-number", typ: tInt},
+}
+
+var processSchema = []field{
+	{name: "process", typ: tUUID},
+	{name: "avg-back-call-time", typ: tDouble},
+	{name: "avg-call-time", typ: tDouble},
+	{name: "avg-db-call-time", typ: tDouble},
+	{name: "avg-lock-call-time", typ: tDouble},
+	{name: "avg-server-call-time", typ: tDouble},
+	{name: "avg-threads", typ: tDouble},
+	{name: "capacity", typ: tInt},
+	{name: "connections", typ: tInt},
+	{name: "host", typ: tString},
+	{name: "is-enable", typ: tBool},
+	{name: "memory-excess-time", typ: tInt},
+	{name: "memory-size", typ: tInt},
+	{name: "pid", typ: tString},
+	{name: "running", typ: tBool},
+	{name: "selection-size", typ: tInt},
+	{name: "started-at", typ: tTime},
+	{name: "use", typ: tInt, enum: []string{"not-used", "used", "used-as-reserve"}},
+	{name: "available-perfomance", typ: tInt},
+	{name: "reserve", typ: tBool},
+	{name: "port", typ: tShort},
+}
+
+var infobaseSummarySchema = []field{
+	{name: "infobase", typ: tUUID},
+	{name: "descr", typ: tString},
+	{name: "name", typ: tString},
+}
+
+var infobaseSchema = []field{
+	{name: "infobase", typ: tUUID},
+	{name: "date-offset", typ: tInt},
+	{name: "dbms", typ: tString},
+	{name: "db-name", typ: tString},
+	{name: "db-pwd", typ: tString},
+	{name: "db-server", typ: tString},
+	{name: "db-user", typ: tString},
+	{name: "denied-from", typ: tTime},
+	{name: "denied-message", typ: tString},
+	{name: "denied-parameter", typ: tString},
+	{name: "denied-to", typ: tTime},
+	{name: "descr", typ: tString},
+	{name: "locale", typ: tString},
+	{name: "name", typ: tString},
+	{name: "permission-code", typ: tString},
+	{name: "scheduled-jobs-deny", typ: tBool, enum: onOff},
+	{name: "security-level", typ: tInt},
+	{name: "sessions-deny", typ: tBool, enum: onOff},
+	{name: "license-distribution", typ: tInt, enum: access},
+	{name: "external-session-manager-connection-string", typ: tString},
+	{name: "external-session-manager-required", typ: tBool, enum: yesNo},
+	{name: "security-profile-name", typ: tString},
+	{name: "safe-mode-security-profile-name", typ: tString},
+	{name: "reserve-working-processes", typ: tBool, enum: yesNo},
+}
+
+// decodeObject читает объект по схеме и возвращает его в том же виде, в каком его выводит rac
+func decodeObject(d *decoder, schema []field) map[string]string {
+	result := make(map[string]string, len(schema))
+	for _, f := range schema {
+		result[f.name] = decodeValue(d, f)
+	}
+
+	return result
+}
+
+func decodeValue(d *decoder, f field) string {
+	switch f.typ {
+	case tUUID:
+		return d.uuid()
+	case tBool:
+		return enumValue(f.enum, yesNo, lo.Ternary(d.bool(), 1, 0))
+	case tShort:
+		return strconv.FormatUint(uint64(d.short()), 10)
+	case tInt:
+		v := d.int()
+		if len(f.enum) > 0 {
+			return enumValue(f.enum, nil, int(v))
+		}
+		return strconv.FormatInt(int64(int32(v)), 10)
+	case tLong:
+		return strconv.FormatInt(int64(d.long()), 10)
+	case tDouble:
+		return strconv.FormatFloat(d.double(), 'f', -1, 64)
+	case tTime:
+		if t := d.time(); !t.IsZero() {
+			return t.Format(timeLayout)
+		}
+		return ""
+	default:
+		return quote(d.string())
+	}
+}
+
+// encodeObject обратная операция к decodeObject, отсутствующие в data поля пишутся нулевыми значениями
+func encodeObject(e *encoder, schema []field, data map[string]string) error {
+	for _, f := range schema {
+		v := data[f.name]
+
+		switch f.typ {
+		case tUUID:
+			if v == "" {
+				v = "00000000-0000-0000-0000-000000000000"
+			}
+			if err := e.uuid(v); err != nil {
+				return err
+			}
+		case tBool:
+			e.bool(slices.Index(lo.Ternary(len(f.enum) > 0, f.enum, yesNo), v) == 1)
+		case tShort:
+			n, _ := strconv.ParseUint(v, 10, 16)
+			e.short(uint16(n))
+		case tInt:
+			if len(f.enum) > 0 {
+				e.int(uint32(max(slices.Index(f.enum, v), 0)))
+				continue
+			}
+			n, _ := strconv.ParseInt(v, 10, 32)
+			e.int(uint32(int32(n)))
+		case tLong:
+			n, _ := strconv.ParseInt(v, 10, 64)
+			e.long(uint64(n))
+		case tDouble:
+			n, _ := strconv.ParseFloat(v, 64)
+			e.double(n)
+		case tTime:
+			t, _ := time.Parse(timeLayout, v)
+			e.time(t)
+		default:
+			e.string(strings.Trim(v, "\""))
+		}
+	}
+
+	return nil
+}
+
+func enumValue(enum, def []string, i int) string {
+	if len(enum) == 0 {
+		enum = def
+	}
+	if i >= 0 && i < len(enum) {
+		return enum[i]
+	}
+
+	return strconv.Itoa(i)
+}
+
+// quote rac берет в кавычки строки содержащие пробелы или разделители
+func quote(v string) string {
+	if strings.ContainsAny(v, " ,;\t") {
+		return "\"" + v + "\""
+	}
+
+	return v
+}
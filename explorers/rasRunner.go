@@ -0,0 +1,188 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/ras"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"golang.org/x/exp/maps"
+)
+
+const defaultRASAddr = "localhost:1545"
+
+//go:generate mockgen -source=$GOFILE -package=mock_models -destination=./mock/mockRAS.go
+type IRASClient interface {
+	Clusters(ctx context.Context) ([]map[string]string, error)
+	Sessions(ctx context.Context, auth ras.ClusterAuth, licenses bool) ([]map[string]string, error)
+	Connections(ctx context.Context, auth ras.ClusterAuth) ([]map[string]string, error)
+	Processes(ctx context.Context, auth ras.ClusterAuth) ([]map[string]string, error)
+	InfobasesSummary(ctx context.Context, auth ras.ClusterAuth) ([]map[string]string, error)
+	InfobaseInfo(ctx context.Context, auth ras.ClusterAuth, infobase, user, pwd string) (map[string]string, error)
+}
+
+// rasRunner выполняет команды rac через нативный клиент RAS без запуска процесса.
+// Аргументы команды транслируются в запросы протокола, а ответ форматируется так же как его выводит rac,
+// поэтому экспортерам не важно какой IRunner используется. Команды, которые нативный клиент не поддерживает,
+// выполняются утилитой rac (RAC.Path)
+type rasRunner struct {
+	mx        sync.Mutex
+	clients   map[string]IRASClient
+	newClient func(addr string) IRASClient
+	fallback  timeoutRunner
+	timeout   time.Duration
+}
+
+// nativeCommands команды rac, которые выполняет нативный клиент RAS
+var nativeCommands = []string{"cluster list", "session list", "connection list", "process list", "infobase summary list", "infobase info"}
+
+// NativeCommands команды rac, которые выполняет нативный клиент RAS, остальные выполняются утилитой rac
+func NativeCommands() []string {
+	return slices.Clone(nativeCommands)
+}
+
+type racArgs struct {
+	addr    string
+	command string
+	flags   map[string]string
+}
+
+// общий на все экспортеры, что бы соединения с RAS переиспользовались
+var nativeRunner = sync.OnceValue(func() *rasRunner {
+	return newRASRunner(defaultRACTimeout)
+})
+
+func newRASRunner(timeout time.Duration) *rasRunner {
+	return &rasRunner{
+		clients:  map[string]IRASClient{},
+		fallback: new(cmdRunner),
+		timeout:  timeout,
+		newClient: func(addr string) IRASClient {
+			return ras.NewClient(addr, timeout)
+		},
+	}
+}
+
+func newRunner(s *settings.Settings) IRunner {
+	if s.RAC_Transport() == settings.TransportNative {
+		return nativeRunner()
+	}
+
+	return new(cmdRunner)
+}
+
+func (r *rasRunner) Run(cmd *exec.Cmd) (string, error) {
+	return r.RunTimeout(cmd, r.timeout)
+}
+
+func (r *rasRunner) RunTimeout(cmd *exec.Cmd, timeout time.Duration) (string, error) {
+	args := parseRACArgs(cmd.Args[1:])
+	if !slices.Contains(nativeCommands, args.command) {
+		if cmd.Args[0] == "" {
+			return "", fmt.Errorf("команда %q не поддерживается нативным клиентом RAS, а путь к утилите rac (RAC.Path) не задан", args.command)
+		}
+
+		return r.fallback.RunTimeout(cmd, timeout)
+	}
+
+	client := r.client(args.addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	auth := ras.ClusterAuth{
+		Cluster:  args.flags["cluster"],
+		User:     args.flags["cluster-user"],
+		Password: args.flags["cluster-pwd"],
+	}
+
+	var result []map[string]string
+	var err error
+
+	switch args.command {
+	case "cluster list":
+		result, err = client.Clusters(ctx)
+	case "session list":
+		_, licenses := args.flags["licenses"]
+		result, err = client.Sessions(ctx, auth, licenses)
+	case "connection list":
+		result, err = client.Connections(ctx, auth)
+	case "process list":
+		result, err = client.Processes(ctx, auth)
+	case "infobase summary list":
+		result, err = client.InfobasesSummary(ctx, auth)
+	case "infobase info":
+		var info map[string]string
+		if info, err = client.InfobaseInfo(ctx, auth, args.flags["infobase"], args.flags["infobase-user"], args.flags["infobase-pwd"]); err == nil {
+			result = append(result, info)
+		}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return formatRACOutput(result), nil
+}
+
+func (r *rasRunner) client(addr string) IRASClient {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if c, ok := r.clients[addr]; ok {
+		return c
+	}
+
+	c := r.newClient(addr)
+	r.clients[addr] = c
+	return c
+}
+
+// parseRACArgs разбирает аргументы в формате rac: [host[:port]] <режим> <команда> [--параметр[=значение]...]
+func parseRACArgs(args []string) racArgs {
+	result := racArgs{addr: defaultRASAddr, flags: map[string]string{}}
+
+	var command []string
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			k, v, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+			result.flags[k] = v
+		case i == 0 && (strings.Contains(arg, ":") || !isRACMode(arg)):
+			result.addr = arg
+			if !strings.Contains(arg, ":") {
+				result.addr += ":1545"
+			}
+		default:
+			command = append(command, arg)
+		}
+	}
+
+	result.command = strings.Join(command, " ")
+	return result
+}
+
+func isRACMode(arg string) bool {
+	return slices.Contains([]string{"agent", "cluster", "manager", "server", "process", "service", "infobase", "connection", "session", "lock", "rule", "profile", "counter", "limit", "service-setting", "binary-data-storage"}, arg)
+}
+
+// formatRACOutput форматирует данные так же как их выводит rac: блоки "ключ : значение" разделенные пустой строкой
+func formatRACOutput(data []map[string]string) string {
+	var b strings.Builder
+	for _, item := range data {
+		keys := maps.Keys(item)
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s : %s\n", k, item[k])
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
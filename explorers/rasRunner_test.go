@@ -0,0 +1,87 @@
+package exporter
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/ras"
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRACArgs(t *testing.T) {
+	args := parseRACArgs([]string{"srv1:1645", "session", "list", "--cluster-user=admin", "--licenses", "--cluster=123"})
+	assert.Equal(t, "srv1:1645", args.addr)
+	assert.Equal(t, "session list", args.command)
+	assert.Equal(t, "admin", args.flags["cluster-user"])
+	assert.Equal(t, "123", args.flags["cluster"])
+	assert.Contains(t, args.flags, "licenses")
+
+	args = parseRACArgs([]string{"infobase", "summary", "list"})
+	assert.Equal(t, defaultRASAddr, args.addr)
+	assert.Equal(t, "infobase summary list", args.command)
+
+	args = parseRACArgs([]string{"srv2", "cluster", "list"})
+	assert.Equal(t, "srv2:1545", args.addr)
+}
+
+func Test_rasRunner(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	client := mock_models.NewMockIRASClient(c)
+	runner := newRASRunner(time.Second)
+	runner.newClient = func(addr string) IRASClient {
+		assert.Equal(t, "srv1:1545", addr)
+		return client
+	}
+
+	object := new(BaseRACExporter)
+	object.logger = logger.NopLogger.Named("test")
+
+	t.Run("licenses", func(t *testing.T) {
+		client.EXPECT().Sessions(gomock.Any(), ras.ClusterAuth{Cluster: "123", User: "admin", Password: "pwd"}, true).Return([]map[string]string{
+			{"session": "1", "license-type": "soft", "rmngr-address": "\"host1\""},
+			{"session": "2", "license-type": "HASP"},
+		}, nil)
+
+		out, err := runner.Run(exec.Command("rac", "srv1:1545", "session", "list", "--cluster-user=admin", "--cluster-pwd=pwd", "--licenses", "--cluster=123"))
+		assert.NoError(t, err)
+
+		var data []map[string]string
+		object.formatMultiResult(out, &data)
+		if assert.Len(t, data, 2) {
+			assert.Equal(t, "\"host1\"", data[0]["rmngr-address"])
+			assert.Equal(t, "HASP", data[1]["license-type"])
+		}
+	})
+	t.Run("infobase info", func(t *testing.T) {
+		client.EXPECT().InfobaseInfo(gomock.Any(), ras.ClusterAuth{Cluster: "123"}, "ib", "user", "pass").Return(map[string]string{"scheduled-jobs-deny": "on"}, nil)
+
+		out, err := runner.Run(exec.Command("rac", "srv1", "infobase", "info", "--cluster=123", "--infobase=ib", "--infobase-user=user", "--infobase-pwd=pass"))
+		assert.NoError(t, err)
+		assert.Equal(t, "scheduled-jobs-deny : on\n\n", out)
+	})
+	t.Run("error", func(t *testing.T) {
+		client.EXPECT().Processes(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+
+		_, err := runner.Run(exec.Command("rac", "srv1", "process", "list", "--cluster=123"))
+		assert.Error(t, err)
+	})
+	t.Run("fallback", func(t *testing.T) {
+		fallback := new(testTimeoutRunner)
+		runner.fallback = fallback
+
+		// команды, которых нет в нативном клиенте, выполняет утилита rac
+		_, err := runner.RunTimeout(exec.Command("rac", "srv1", "lock", "list", "--cluster=123"), time.Second*3)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Second*3, time.Duration(fallback.timeout.Load()))
+
+		_, err = runner.Run(exec.Command("", "srv1", "session", "terminate", "--cluster=123", "--session=1"))
+		assert.ErrorContains(t, err, "RAC.Path")
+	})
+}
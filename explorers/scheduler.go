@@ -0,0 +1,175 @@
+package exporter
+
+import (
+	"context"
+	"runtime/trace"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/model"
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// экспортер, данные которого может обновлять планировщик
+type scheduled interface {
+	model.IExporter
+
+	base() *BaseExporter
+	getValue() error
+}
+
+// собственные интервалы экспортеров, которым нужен сбор чаще чем приходит prometheus
+var defaultIntervals = map[string]time.Duration{
+	"sessions_data": time.Second * 5,
+}
+
+// Scheduler фоново обновляет данные экспортеров с заданным интервалом сбора.
+// Collect таких экспортеров отдает последний собранный снимок, а не ходит за данными сам
+type Scheduler struct {
+	settings  *settings.Settings
+	exporters []scheduled
+	wg        sync.WaitGroup
+}
+
+func NewScheduler(s *settings.Settings) *Scheduler {
+	return &Scheduler{settings: s}
+}
+
+// Add ставит экспортеры в расписание. Вызывать нужно до регистрации экспортеров в prometheus,
+// т.к. от расписания зависит набор метрик в Describe
+func (sch *Scheduler) Add(exporters ...model.IExporter) {
+	for _, ex := range exporters {
+		se, ok := ex.(scheduled)
+		if !ok {
+			continue
+		}
+
+		interval := sch.settings.GetCollectInterval(ex.GetName(), defaultIntervals[ex.GetName()])
+		if interval <= 0 {
+			continue
+		}
+
+		se.base().schedule(ex.GetName(), interval)
+		sch.exporters = append(sch.exporters, se)
+	}
+}
+
+// Start запускает фоновый сбор, сбор экспортера завершается при его остановке или при отмене ctx
+func (sch *Scheduler) Start(ctx context.Context) {
+	for _, ex := range sch.exporters {
+		sch.wg.Add(1)
+		go func() {
+			defer sch.wg.Done()
+			sch.run(ctx, ex)
+		}()
+	}
+}
+
+// Wait ожидает завершения фонового сбора
+func (sch *Scheduler) Wait() {
+	sch.wg.Wait()
+}
+
+func (sch *Scheduler) run(ctx context.Context, ex scheduled) {
+	b := ex.base()
+	logger.DefaultLogger.Named(ex.GetName()).Debugf("Запущен фоновый сбор с интервалом %v", b.interval)
+
+	for {
+		// отключенный экспортер остановлен до запуска расписания, данные для него не собираются даже один раз
+		if b.ctx.Err() != nil || ctx.Err() != nil {
+			return
+		}
+		if !b.isLocked.Load() {
+			sch.refresh(ex)
+		}
+
+		select {
+		case <-time.After(b.interval):
+		case <-b.ctx.Done():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (sch *Scheduler) refresh(ex scheduled) {
+	b := ex.base()
+	defer trace.StartRegion(b.ctx, "Scheduler.refresh").End()
+
+	// ошибку логирует сам экспортер, при ошибке (например, недоступен один из RAS) снимок содержит то, что удалось собрать,
+	// но время успешного сбора не обновляется
+	b.track(ex.getValue)
+	b.saveSnapshot()
+}
+
+// schedule переводит экспортер в режим фонового сбора
+func (exp *BaseExporter) schedule(name string, interval time.Duration) {
+	exp.interval = interval
+	exp.staleDesc = prometheus.NewDesc(
+		"exporter_stale",
+		"Данные экспортера устарели: последний успешный сбор был больше двух интервалов назад (1) или нет (0)",
+		nil, prometheus.Labels{"exporter": name},
+	)
+}
+
+func (exp *BaseExporter) isScheduled() bool {
+	return exp.interval > 0
+}
+
+// saveSnapshot запоминает текущие значения метрик. Т.к. каждый сбор начинается со сброса векторов,
+// метрики из снимка при следующем сборе не меняются
+func (exp *BaseExporter) saveSnapshot() {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		exp.collectMetrics(ch)
+		close(ch)
+	}()
+
+	var snapshot []prometheus.Metric
+	for m := range ch {
+		snapshot = append(snapshot, m)
+	}
+
+	exp.snapshotMx.Lock()
+	exp.snapshot = snapshot
+	exp.snapshotMx.Unlock()
+}
+
+func (exp *BaseExporter) clearSnapshot() {
+	exp.snapshotMx.Lock()
+	exp.snapshot = nil
+	exp.snapshotMx.Unlock()
+}
+
+// collect отдает метрики экспортера: без расписания данные собираются сразу, иначе отдается последний снимок
+func (exp *BaseExporter) collect(ch chan<- prometheus.Metric, getValue func() error) {
+	if !exp.isScheduled() {
+		exp.collectMx.Lock()
+		defer exp.collectMx.Unlock()
+
+		exp.track(getValue)
+		exp.collectMetrics(ch)
+		return
+	}
+
+	exp.snapshotMx.RLock()
+	for _, m := range exp.snapshot {
+		ch <- m
+	}
+	exp.snapshotMx.RUnlock()
+
+	exp.collectStale(ch)
+}
+
+// collectStale признак устаревания снимка, время последнего успешного сбора отдается в exporter_last_success_timestamp_seconds
+func (exp *BaseExporter) collectStale(ch chan<- prometheus.Metric) {
+	stale := 1.
+	if ts := exp.lastSuccess.Load(); ts > 0 && time.Since(time.Unix(0, ts)) <= exp.interval*2 {
+		stale = 0
+	}
+
+	ch <- prometheus.MustNewConstMetric(exp.staleDesc, prometheus.GaugeValue, stale)
+}
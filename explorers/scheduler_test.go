@@ -0,0 +1,142 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_Scheduler(t *testing.T) {
+	logger.InitLogger("", 4)
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: cpu
+    Property:
+      Interval: 50ms
+  - Name: disk
+`), s))
+
+	hInfo := mock_models.NewMockICPUInfo(c)
+	collected := make(chan struct{}, 10)
+	hInfo.EXPECT().TotalCPUPercent(time.Duration(0), false).DoAndReturn(func(time.Duration, bool) ([]float64, error) {
+		collected <- struct{}{}
+		return []float64{5.}, nil
+	}).MinTimes(1)
+
+	cpu := new(CPU).Construct(s)
+	cpu.hInfo = hInfo
+	disk := new(ExporterDisk).Construct(s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sch := NewScheduler(s)
+	sch.Add(cpu, disk)
+
+	assert.True(t, cpu.isScheduled())
+	assert.False(t, disk.isScheduled()) // без интервала данные собираются при запросе
+
+	sch.Start(ctx)
+	<-collected
+	time.Sleep(time.Millisecond * 10) // снимок сохраняется после сбора
+
+	// Collect отдает снимок не обращаясь за данными
+	ch := make(chan prometheus.Metric, 10)
+	cpu.Collect(ch)
+	close(ch)
+
+	values := map[string]float64{}
+	for m := range ch {
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+
+		switch {
+		case metric.Summary != nil:
+			values["cpu"] = metric.GetSummary().GetSampleSum()
+		case m.Desc() == cpu.staleDesc:
+			values["stale"] = metric.GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, 5., values["cpu"])
+	assert.Equal(t, 0., values["stale"])
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(selfMetrics.lastSuccess.WithLabelValues("cpu")), 5)
+	assert.Equal(t, 1., testutil.ToFloat64(selfMetrics.up.WithLabelValues("cpu")))
+
+	cancel()
+	sch.Wait()
+}
+
+func Test_collectSerialized(t *testing.T) {
+	exp := newBase("test")
+	defer exp.Stop()
+
+	// экспортер без расписания собирает данные на каждый Collect, параллельные запросы разных реестров
+	// (/metrics и /metrics_rac) не должны выполнять сбор одновременно
+	var active, overlaps atomic.Int32
+	getValue := func() error {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond * 5)
+		active.Add(-1)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exp.collect(make(chan prometheus.Metric), getValue)
+		}()
+	}
+	wg.Wait()
+
+	assert.Zero(t, overlaps.Load())
+}
+
+func Test_SchedulerStopped(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: cpu
+    Property:
+      Interval: 10ms
+`), s))
+
+	// отключенный экспортер останавливается при регистрации, до запуска расписания
+	hInfo := mock_models.NewMockICPUInfo(c)
+	hInfo.EXPECT().TotalCPUPercent(gomock.Any(), gomock.Any()).Times(0)
+
+	cpu := new(CPU).Construct(s)
+	cpu.hInfo = hInfo
+
+	sch := NewScheduler(s)
+	sch.Add(cpu)
+	cpu.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sch.Start(ctx)
+	sch.Wait()
+}
@@ -0,0 +1,214 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const selfNamespace = "exporter"
+
+// метрики о работе самого экспортера, отдаются только на /metrics
+var selfMetrics = struct {
+	collectDuration *prometheus.HistogramVec
+	lastSuccess     *prometheus.GaugeVec
+	up              *prometheus.GaugeVec
+
+	racRequests *prometheus.CounterVec
+	racDuration *prometheus.HistogramVec
+	racErrors   *prometheus.CounterVec
+	racTimeouts *prometheus.CounterVec
+
+	racConcurrency prometheus.Gauge
+	racQueue       prometheus.Gauge
+	racInFlight    prometheus.Gauge
+	racQueueWait   *prometheus.HistogramVec
+	racCache       *prometheus.CounterVec
+
+	breakerState    *prometheus.GaugeVec
+	breakerRejected *prometheus.CounterVec
+
+	infobases prometheus.Gauge
+
+	credentialsRefresh     *prometheus.CounterVec
+	credentialsLastSuccess prometheus.Gauge
+	credentialsBases       prometheus.Gauge
+}{
+	collectDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: selfNamespace,
+		Name:      "collect_duration_seconds",
+		Help:      "Длительность сбора данных экспортера",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 15, 30, 60},
+	}, []string{"exporter"}),
+	lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Время последнего успешного сбора данных экспортера",
+	}, []string{"exporter"}),
+	up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "up",
+		Help:      "Результат последнего сбора данных экспортера: 1 - успешно, 0 - ошибка",
+	}, []string{"exporter"}),
+
+	racRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_requests_total",
+		Help:      "Количество вызовов rac в разрезе команд",
+	}, []string{"command"}),
+	racDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_request_duration_seconds",
+		Help:      "Длительность вызовов rac в разрезе команд",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15},
+	}, []string{"command"}),
+	racErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_errors_total",
+		Help:      "Количество ошибок вызова rac в разрезе команд",
+	}, []string{"command"}),
+	racTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_timeouts_total",
+		Help:      "Количество вызовов rac прерванных по таймауту",
+	}, []string{"command"}),
+
+	racConcurrency: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_concurrency_limit",
+		Help:      "Максимальное количество одновременных обращений к RAS, 0 - без ограничений",
+	}),
+	racQueue: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_queue_length",
+		Help:      "Количество команд rac, ожидающих очереди",
+	}),
+	racInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_in_flight",
+		Help:      "Количество выполняющихся команд rac",
+	}),
+	racQueueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_queue_wait_seconds",
+		Help:      "Время ожидания очереди командами rac в разрезе экспортеров",
+		Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"exporter"}),
+	racCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: selfNamespace,
+		Name:      "rac_cache_requests_total",
+		Help:      "Обращения экспортеров к общему кешу результатов rac: hit - результат взят из кеша, miss - команда выполнена",
+	}, []string{"result"}),
+
+	breakerState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "ras_breaker_state",
+		Help:      "Состояние обращений к RAS: closed - RAS доступен, open - обращения приостановлены, half-open - проверка доступности",
+	}, []string{"ras", "state"}),
+	breakerRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: selfNamespace,
+		Name:      "ras_breaker_rejected_total",
+		Help:      "Количество команд rac, не выполненных из-за недоступности RAS",
+	}, []string{"ras"}),
+
+	infobases: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "infobases",
+		Help:      "Количество известных информационных баз",
+	}),
+
+	credentialsRefresh: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: selfNamespace,
+		Name:      "db_credentials_refresh_total",
+		Help:      "Количество обращений к REST за учетными данными БД в разрезе результата",
+	}, []string{"result"}),
+	credentialsLastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "db_credentials_last_success_timestamp_seconds",
+		Help:      "Время последнего успешного получения учетных данных БД",
+	}),
+	credentialsBases: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: selfNamespace,
+		Name:      "db_credentials_infobases",
+		Help:      "Количество баз, полученных из REST с учетными данными",
+	}),
+}
+
+// RegisterSelfMetrics регистрирует метрики о работе экспортера
+func RegisterSelfMetrics(r prometheus.Registerer) error {
+	settings.DBCredentialsHook = func(bases int, err error) {
+		if err != nil {
+			selfMetrics.credentialsRefresh.WithLabelValues("error").Inc()
+			return
+		}
+
+		selfMetrics.credentialsRefresh.WithLabelValues("success").Inc()
+		selfMetrics.credentialsLastSuccess.SetToCurrentTime()
+		selfMetrics.credentialsBases.Set(float64(bases))
+	}
+
+	for _, c := range []prometheus.Collector{
+		selfMetrics.collectDuration,
+		selfMetrics.lastSuccess,
+		selfMetrics.up,
+		selfMetrics.racRequests,
+		selfMetrics.racDuration,
+		selfMetrics.racErrors,
+		selfMetrics.racTimeouts,
+		selfMetrics.racConcurrency,
+		selfMetrics.racQueue,
+		selfMetrics.racInFlight,
+		selfMetrics.racQueueWait,
+		selfMetrics.racCache,
+		selfMetrics.breakerState,
+		selfMetrics.breakerRejected,
+		selfMetrics.infobases,
+		selfMetrics.credentialsRefresh,
+		selfMetrics.credentialsLastSuccess,
+		selfMetrics.credentialsBases,
+	} {
+		if err := r.Register(c); err != nil {
+			return errors.Wrap(err, "register self metrics error")
+		}
+	}
+
+	return nil
+}
+
+// track выполняет сбор данных экспортера и фиксирует его длительность и результат
+func (exp *BaseExporter) track(getValue func() error) error {
+	start := time.Now()
+	err := getValue()
+
+	selfMetrics.collectDuration.WithLabelValues(exp.name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		selfMetrics.up.WithLabelValues(exp.name).Set(0)
+		return err
+	}
+
+	exp.lastSuccess.Store(time.Now().UnixNano())
+	selfMetrics.up.WithLabelValues(exp.name).Set(1)
+	selfMetrics.lastSuccess.WithLabelValues(exp.name).SetToCurrentTime()
+	return nil
+}
+
+// observeRAC фиксирует вызов rac
+func observeRAC(command string, duration time.Duration, err error) {
+	selfMetrics.racRequests.WithLabelValues(command).Inc()
+	selfMetrics.racDuration.WithLabelValues(command).Observe(duration.Seconds())
+
+	if err != nil {
+		selfMetrics.racErrors.WithLabelValues(command).Inc()
+		if isTimeout(err) {
+			selfMetrics.racTimeouts.WithLabelValues(command).Inc()
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	return errors.Is(err, errTimeout) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,67 @@
+package exporter
+
+import (
+	"os/exec"
+	"testing"
+
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_selfMetrics(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	assert.NoError(t, RegisterSelfMetrics(prometheus.NewRegistry()))
+
+	run := mock_models.NewMockIRunner(c)
+	exp := &BaseExporter{name: "test", logger: logger.NopLogger.Named("test"), runner: run}
+
+	// счетчики общие на пакет, поэтому проверяется прирост, а не значение
+	delta := func(c prometheus.Collector) func() float64 {
+		before := testutil.ToFloat64(c)
+		return func() float64 { return testutil.ToFloat64(c) - before }
+	}
+
+	t.Run("rac", func(t *testing.T) {
+		run.EXPECT().Run(gomock.Any()).Return("", nil)
+		run.EXPECT().Run(gomock.Any()).Return("", errTimeout)
+
+		requests := delta(selfMetrics.racRequests.WithLabelValues("rule list"))
+		failed := delta(selfMetrics.racErrors.WithLabelValues("rule list"))
+		timeouts := delta(selfMetrics.racTimeouts.WithLabelValues("rule list"))
+
+		exp.run(exec.Command("rac", "srv:1545", "rule", "list", "--cluster=123"))
+		exp.run(exec.Command("rac", "srv:1545", "rule", "list", "--cluster=123"))
+
+		assert.Equal(t, 2., requests())
+		assert.Equal(t, 1., failed())
+		assert.Equal(t, 1., timeouts())
+	})
+	t.Run("track", func(t *testing.T) {
+		assert.Error(t, exp.track(func() error { return errors.New("error") }))
+		assert.Equal(t, 0., testutil.ToFloat64(selfMetrics.up.WithLabelValues("test")))
+		assert.Zero(t, exp.lastSuccess.Load())
+
+		assert.NoError(t, exp.track(func() error { return nil }))
+		assert.Equal(t, 1., testutil.ToFloat64(selfMetrics.up.WithLabelValues("test")))
+		assert.NotZero(t, exp.lastSuccess.Load())
+	})
+	t.Run("db credentials", func(t *testing.T) {
+		success := delta(selfMetrics.credentialsRefresh.WithLabelValues("success"))
+		failed := delta(selfMetrics.credentialsRefresh.WithLabelValues("error"))
+
+		settings.DBCredentialsHook(3, nil)
+		settings.DBCredentialsHook(0, errors.New("error"))
+
+		assert.Equal(t, 1., success())
+		assert.Equal(t, 1., failed())
+		assert.Equal(t, 3., testutil.ToFloat64(selfMetrics.credentialsBases))
+	})
+}
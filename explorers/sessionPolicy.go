@@ -0,0 +1,233 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// действия правил завершения сеансов, значения метки action
+const (
+	policyActionDryRun     = "dry_run"
+	policyActionTerminated = "terminated"
+	policyActionFailed     = "failed"
+)
+
+// SessionPolicy завершает сеансы по правилам секции SessionPolicies. Правила проверяются по тому же
+// списку сеансов (rac session list), что используют экспортеры сеансов
+type SessionPolicy struct {
+	ExporterSessions
+
+	auditMx sync.Mutex
+	// количество опросов подряд, в которых сеанс подходит под правило: по идентификатору кластера,
+	// внутри - по правилу и идентификатору сеанса
+	streak  map[string]map[string]int
+	actions *prometheus.CounterVec
+}
+
+// PolicyAudit запись журнала аудита
+type PolicyAudit struct {
+	Time      time.Time `json:"time"`
+	Rule      string    `json:"rule"`
+	Action    string    `json:"action"`
+	Cluster   string    `json:"cluster"`
+	Base      string    `json:"base"`
+	User      string    `json:"user"`
+	AppID     string    `json:"app_id"`
+	Host      string    `json:"host"`
+	Session   string    `json:"session"`
+	SessionID string    `json:"session_id"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func NewSessionPolicy(s *settings.Settings) *SessionPolicy {
+	exp := &SessionPolicy{streak: map[string]map[string]int{}}
+	exp.BaseExporter = newBase("session_policy")
+	exp.actions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: s.GetMetricNamePrefix() + "session_policy_actions_total",
+			Help: "Действия правил завершения сеансов: terminated - сеанс завершен, dry_run - сеанс был бы завершен (режим dry-run), failed - ошибка завершения",
+		},
+		[]string{"rule", "base", "action"},
+	)
+
+	exp.settings = s
+	exp.runner = newRunner(s)
+	return exp
+}
+
+// Start проверяет правила с интервалом Interval до завершения ctx
+func (exp *SessionPolicy) Start(ctx context.Context) {
+	for {
+		interval := time.Minute
+		if p := exp.settings.SessionPolicies; p != nil && p.Interval > 0 {
+			interval = p.Interval
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			exp.logger.Debug("context is done")
+			return
+		}
+
+		if err := exp.Evaluate(time.Now()); err != nil {
+			exp.logger.Error(errors.Wrap(err, "ошибка проверки правил завершения сеансов"))
+		}
+	}
+}
+
+// Evaluate один опрос: сеансы, которые подходят под правило заданное количество опросов подряд, завершаются
+func (exp *SessionPolicy) Evaluate(now time.Time) (err error) {
+	// настройки читаются на каждом опросе, что бы правила обновлялись при перечитывании настроек
+	policies := exp.settings.SessionPolicies
+	if policies == nil || len(policies.Rules) == 0 {
+		return nil
+	}
+
+	streak := map[string]map[string]int{}
+	for _, cl := range exp.GetClusters() {
+		ses, e := exp.getSessions(cl)
+		if e != nil {
+			// сеансы кластера не получены, отсчет продолжится со следующего успешного опроса
+			streak[cl.id] = exp.streak[cl.id]
+			err = e
+			continue
+		}
+
+		prev, current := exp.streak[cl.id], map[string]int{}
+		streak[cl.id] = current
+
+		for _, item := range ses {
+			base, user := exp.findBaseName(item["infobase"]), item["user-name"]
+			if !policies.Allowed(base, item["infobase"], user) {
+				continue
+			}
+
+			for _, rule := range policies.Rules {
+				if rule == nil || !matchSessionRule(rule, item, now) {
+					continue
+				}
+
+				key := rule.Name + "/" + item["session"]
+				current[key] = prev[key] + 1
+				if current[key] < max(rule.Polls, 1) {
+					continue
+				}
+
+				// после действия условие должно снова выполниться Polls опросов подряд
+				delete(current, key)
+				exp.apply(policies, rule, cl, item, base, now)
+				break
+			}
+		}
+	}
+
+	// сеансы, которые перестали подходить под правило (или завершились), начинают отсчет заново
+	exp.streak = streak
+	return err
+}
+
+func (exp *SessionPolicy) apply(policies *settings.SessionPolicies, rule *settings.SessionRule, cl *clusterInfo, item map[string]string, base string, now time.Time) {
+	audit := PolicyAudit{
+		Time:      now,
+		Rule:      rule.Name,
+		Action:    policyActionDryRun,
+		Cluster:   cl.name,
+		Base:      base,
+		User:      item["user-name"],
+		AppID:     item["app-id"],
+		Host:      item["host"],
+		Session:   item["session"],
+		SessionID: item["session-id"],
+	}
+
+	if policies.Mode == settings.PolicyTerminate {
+		audit.Action = policyActionTerminated
+		if err := exp.terminate(cl, item["session"], rule.GetMessage(policies)); err != nil {
+			audit.Action, audit.Error = policyActionFailed, err.Error()
+		}
+	}
+
+	exp.actions.WithLabelValues(rule.Name, base, audit.Action).Inc()
+	exp.logger.With("rule", audit.Rule, "action", audit.Action, "base", audit.Base, "user", audit.User,
+		"app-id", audit.AppID, "session-id", audit.SessionID, "error", audit.Error).Warn("сработало правило завершения сеансов")
+
+	if err := exp.writeAudit(policies.AuditLog, audit); err != nil {
+		exp.logger.Error(errors.Wrap(err, "ошибка записи журнала аудита"))
+	}
+}
+
+func (exp *SessionPolicy) terminate(cl *clusterInfo, session, message string) error {
+	cmd := exp.racCommand(cl, "session", "terminate",
+		fmt.Sprintf("--session=%v", session),
+		fmt.Sprintf("--error-message=%v", message))
+
+	_, err := exp.run(cmd)
+	return err
+}
+
+func (exp *SessionPolicy) writeAudit(path string, audit PolicyAudit) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(audit)
+	if err != nil {
+		return err
+	}
+
+	exp.auditMx.Lock()
+	defer exp.auditMx.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// matchSessionRule выполняются ли для сеанса все заданные условия правила
+func matchSessionRule(rule *settings.SessionRule, item map[string]string, now time.Time) bool {
+	if rule.InMaintenance(now) {
+		return false
+	}
+	if len(rule.AppID) > 0 && !slices.ContainsFunc(rule.AppID, func(v string) bool { return strings.EqualFold(v, item["app-id"]) }) {
+		return false
+	}
+	if rule.Hibernated > 0 {
+		lastActive, err := time.ParseInLocation("2006-01-02T15:04:05", item["last-active-at"], time.Local)
+		if !strings.EqualFold(item["hibernate"], "yes") || err != nil || now.Sub(lastActive) < rule.Hibernated {
+			return false
+		}
+	}
+	if rule.MemoryCurrent > 0 && atoi(item["memory-current"]) <= int64(rule.MemoryCurrent) {
+		return false
+	}
+	// duration-current в миллисекундах
+	if rule.DurationCurrent > 0 && time.Duration(atoi(item["duration-current"]))*time.Millisecond <= rule.DurationCurrent {
+		return false
+	}
+
+	return true
+}
+
+func (exp *SessionPolicy) Describe(ch chan<- *prometheus.Desc) {
+	exp.actions.Describe(ch)
+}
+
+func (exp *SessionPolicy) Collect(ch chan<- prometheus.Metric) {
+	exp.actions.Collect(ch)
+}
@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_SessionPolicy(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	logger.InitLogger("", 4)
+
+	audit := filepath.Join(t.TempDir(), "audit.log")
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+SessionPolicies:
+  Mode: terminate
+  AuditLog: `+audit+`
+  DenyUsers: [Администратор]
+  Rules:
+    - Name: memory
+      MemoryCurrent: 1KB
+      Polls: 2
+      Message: "Превышен объем памяти"
+    - Name: hibernated
+      Hibernated: 8h
+`), s))
+
+	run := mock_models.NewMockIRunner(c)
+	exp := NewSessionPolicy(s)
+	exp.SetInfobases(testInfobases(s, Infobase{GUID: "ib1", Name: "hrm", Cluster: "123"}))
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	var terminated []string
+	run.EXPECT().Run(gomock.Any()).DoAndReturn(func(cmd *exec.Cmd) (string, error) {
+		switch parseRACArgs(cmd.Args[1:]).command {
+		case "session list":
+			return `session        : s1
+session-id     : 1
+infobase       : ib1
+user-name      : Иванов
+app-id         : 1CV8C
+hibernate      : no
+last-active-at : 2025-03-03T11:59:00
+memory-current : 2048
+
+session        : s2
+session-id     : 2
+infobase       : ib1
+user-name      : Петров
+app-id         : 1CV8C
+hibernate      : yes
+last-active-at : 2025-03-03T01:00:00
+memory-current : 0
+
+session        : s3
+session-id     : 3
+infobase       : ib1
+user-name      : Администратор
+app-id         : Designer
+hibernate      : yes
+last-active-at : 2025-03-03T01:00:00
+memory-current : 4096
+`, nil
+		case "session terminate":
+			terminated = append(terminated, strings.Join(cmd.Args, " "))
+			if strings.Contains(strings.Join(cmd.Args, " "), "--session=s1") {
+				return "", errors.New("Сеанс не найден")
+			}
+			return "", nil
+		}
+		return "", errors.New("unexpected command")
+	}).AnyTimes()
+
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.Local)
+
+	// s2 спит дольше 8 часов и завершается сразу, s1 должен превышать память 2 опроса подряд, s3 в списке исключений
+	assert.NoError(t, exp.Evaluate(now))
+	assert.Len(t, terminated, 1)
+	assert.Contains(t, terminated[0], "--session=s2")
+	assert.Equal(t, 1., testutil.ToFloat64(exp.actions.WithLabelValues("hibernated", "hrm", policyActionTerminated)))
+
+	assert.NoError(t, exp.Evaluate(now.Add(time.Minute)))
+	assert.Len(t, terminated, 3)
+	assert.Contains(t, terminated[1], "--session=s1")
+	assert.Contains(t, terminated[1], "--error-message=Превышен объем памяти")
+	assert.Equal(t, 1., testutil.ToFloat64(exp.actions.WithLabelValues("memory", "hrm", policyActionFailed)))
+
+	data, err := os.ReadFile(audit)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 3)
+
+	var record PolicyAudit
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "memory", record.Rule)
+	assert.Equal(t, policyActionFailed, record.Action)
+	assert.Equal(t, "Иванов", record.User)
+	assert.Equal(t, "Сеанс не найден", record.Error)
+
+	// в режиме dry-run сеансы не завершаются
+	s.SessionPolicies.Mode = settings.PolicyDryRun
+	assert.NoError(t, exp.Evaluate(now.Add(time.Minute*2)))
+	assert.Len(t, terminated, 3)
+	assert.Equal(t, 1., testutil.ToFloat64(exp.actions.WithLabelValues("hibernated", "hrm", policyActionDryRun)))
+}
+
+func Test_SessionPolicyFailedPoll(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	logger.InitLogger("", 4)
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+SessionPolicies:
+  Mode: terminate
+  Rules:
+    - Name: memory
+      MemoryCurrent: 1KB
+      Polls: 3
+`), s))
+
+	run := mock_models.NewMockIRunner(c)
+	exp := NewSessionPolicy(s)
+	exp.SetInfobases(testInfobases(s, Infobase{GUID: "ib1", Name: "hrm", Cluster: "123"}))
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	var polls int
+	var terminated []string
+	run.EXPECT().Run(gomock.Any()).DoAndReturn(func(cmd *exec.Cmd) (string, error) {
+		switch parseRACArgs(cmd.Args[1:]).command {
+		case "session list":
+			// каждый второй опрос RAS недоступен
+			if polls++; polls%2 == 0 {
+				return "", errors.New("connection refused")
+			}
+			return `session        : s1
+infobase       : ib1
+user-name      : Иванов
+memory-current : 2048
+`, nil
+		case "session terminate":
+			terminated = append(terminated, strings.Join(cmd.Args, " "))
+			return "", nil
+		}
+		return "", errors.New("unexpected command")
+	}).AnyTimes()
+
+	// неудачный опрос не сбрасывает количество опросов подряд, в которых сеанс подходил под правило
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 4; i++ {
+		exp.Evaluate(now.Add(time.Minute * time.Duration(i)))
+	}
+	assert.Empty(t, terminated)
+
+	assert.NoError(t, exp.Evaluate(now.Add(time.Minute*4)))
+	if assert.Len(t, terminated, 1) {
+		assert.Contains(t, terminated[0], "--session=s1")
+	}
+}
+
+func Test_SessionPolicyUnresolvedBase(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	logger.InitLogger("", 4)
+
+	s := &settings.Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+SessionPolicies:
+  Mode: terminate
+  DenyBases: [hrm]
+  Rules:
+    - Name: designer
+      AppID: [Designer]
+`), s))
+
+	run := mock_models.NewMockIRunner(c)
+	exp := NewSessionPolicy(s)
+	exp.SetInfobases(testInfobases(s, Infobase{GUID: "ib2", Name: "acc", Cluster: "123"}))
+	exp.clusters = testClusters(s, "123")
+	exp.runner = run
+
+	var terminated []string
+	run.EXPECT().Run(gomock.Any()).DoAndReturn(func(cmd *exec.Cmd) (string, error) {
+		switch parseRACArgs(cmd.Args[1:]).command {
+		case "session list":
+			return `session        : s1
+infobase       : ib1
+user-name      : Иванов
+app-id         : Designer
+
+session        : s2
+infobase       : ib2
+user-name      : Петров
+app-id         : Designer
+`, nil
+		case "session terminate":
+			terminated = append(terminated, strings.Join(cmd.Args, " "))
+			return "", nil
+		}
+		return "", errors.New("unexpected command")
+	}).AnyTimes()
+
+	// база ib1 (hrm) еще не попала в реестр: по списку DenyBases нельзя проверить, что ее сеансы можно завершать
+	assert.NoError(t, exp.Evaluate(time.Now()))
+	assert.Len(t, terminated, 1)
+	assert.Contains(t, terminated[0], "--session=s2")
+}
@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// racSnapshots общий для всех экспортеров кеш результатов rac в разрезе кластера и команды.
+// Одинаковые команды, запрошенные разными экспортерами в пределах ttl, выполняются один раз,
+// одновременные запросы ждут результата первого.
+// session list --licenses не заменяет session list (отступление от задачи, описано в README): rac выводит по этому ключу
+// записи лицензий, в них нет базы, памяти и прочих показателей сеанса. infobase info кешируется по каждой базе и
+// используется shedule_job и infobase_state.
+type racSnapshots struct {
+	mx    sync.Mutex
+	ttl   time.Duration
+	items map[string]*racSnapshot
+}
+
+type racSnapshot struct {
+	ready   chan struct{}
+	data    []map[string]string
+	err     error
+	expires time.Time
+}
+
+var snapshots = &racSnapshots{items: map[string]*racSnapshot{}}
+
+// SetRACCacheTTL задает время, в течение которого результат команды rac используется всеми экспортерами, 0 - не кешировать
+func SetRACCacheTTL(ttl time.Duration) {
+	snapshots.mx.Lock()
+	defer snapshots.mx.Unlock()
+
+	snapshots.ttl = ttl
+	clear(snapshots.items)
+}
+
+func (s *racSnapshots) get(key string, load func() ([]map[string]string, error)) ([]map[string]string, error) {
+	s.mx.Lock()
+	if s.ttl <= 0 {
+		s.mx.Unlock()
+		return load()
+	}
+
+	item, ok := s.items[key]
+	if ok && (!item.done() || time.Now().Before(item.expires)) {
+		s.mx.Unlock()
+		selfMetrics.racCache.WithLabelValues("hit").Inc()
+
+		<-item.ready
+		return cloneRows(item.data), item.err
+	}
+
+	item = &racSnapshot{ready: make(chan struct{})}
+	s.items[key] = item
+	ttl := s.ttl
+	s.mx.Unlock()
+	selfMetrics.racCache.WithLabelValues("miss").Inc()
+
+	item.data, item.err = load()
+	item.expires = time.Now().Add(ttl)
+	close(item.ready)
+
+	// ошибки не кешируются, следующий запрос выполнит команду повторно
+	if item.err != nil {
+		s.mx.Lock()
+		if s.items[key] == item {
+			delete(s.items, key)
+		}
+		s.mx.Unlock()
+	}
+
+	return cloneRows(item.data), item.err
+}
+
+func (item *racSnapshot) done() bool {
+	select {
+	case <-item.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// cloneRows копия результата, что бы изменения одного экспортера не затрагивали остальных
+func cloneRows(rows []map[string]string) []map[string]string {
+	if rows == nil {
+		return nil
+	}
+
+	result := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		result[i] = maps.Clone(row)
+	}
+
+	return result
+}
+
+// query выполняет команду rac по кластеру и разбирает ее вывод, результат берется из общего кеша (см. racSnapshots)
+func (exp *BaseRACExporter) query(cl *clusterInfo, args ...string) ([]map[string]string, error) {
+	key := strings.Join(append([]string{cl.RASHostPort(), cl.id, cl.Login}, args...), " ")
+
+	return snapshots.get(key, func() ([]map[string]string, error) {
+		result, err := exp.run(exp.racCommand(cl, args...))
+		if err != nil {
+			return nil, err
+		}
+
+		var data []map[string]string
+		exp.formatMultiResult(result, &data)
+		return data, nil
+	})
+}
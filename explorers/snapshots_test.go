@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	mock_models "github.com/LazarenkoA/prometheus_1C_exporter/explorers/mock"
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_racSnapshots(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	SetRACCacheTTL(time.Millisecond * 100)
+	defer SetRACCacheTTL(0)
+
+	s := new(settings.Settings)
+	run := mock_models.NewMockIRunner(c)
+
+	newExporter := func(name string) *BaseRACExporter {
+		exp := &BaseRACExporter{BaseExporter: newBase(name)}
+		exp.settings = s
+		exp.clusters = testClusters(s, "123")
+		exp.runner = run
+		return exp
+	}
+
+	ses, locks := newExporter("session"), newExporter("locks")
+	defer ses.Stop()
+	defer locks.Stop()
+
+	cl := ses.GetClusters()[0]
+
+	t.Run("dedup", func(t *testing.T) {
+		run.EXPECT().Run(gomock.Any()).DoAndReturn(func(any) (string, error) {
+			time.Sleep(time.Millisecond * 20)
+			return testDatasession1(), nil
+		}).Times(1)
+
+		wg := new(sync.WaitGroup)
+		for _, exp := range []*BaseRACExporter{ses, locks, ses, locks} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				data, err := exp.query(cl, "session", "list")
+				assert.NoError(t, err)
+				if assert.Len(t, data, 1) {
+					data[0]["session-id"] = "changed" // копия, на других экспортерах не сказывается
+				}
+			}()
+		}
+		wg.Wait()
+
+		data, _ := locks.query(cl, "session", "list")
+		assert.Equal(t, "590", data[0]["session-id"])
+	})
+	t.Run("other command", func(t *testing.T) {
+		run.EXPECT().Run(gomock.Any()).Return(testDatasession1(), nil)
+		_, err := ses.query(cl, "session", "list", "--licenses")
+		assert.NoError(t, err)
+	})
+	t.Run("expired", func(t *testing.T) {
+		time.Sleep(time.Millisecond * 110)
+
+		run.EXPECT().Run(gomock.Any()).Return("", errors.New("error"))
+		_, err := ses.query(cl, "session", "list")
+		assert.Error(t, err)
+
+		// ошибка не кешируется
+		run.EXPECT().Run(gomock.Any()).Return(testDatasession1(), nil)
+		data, err := locks.query(cl, "session", "list")
+		assert.NoError(t, err)
+		assert.Len(t, data, 1)
+	})
+}
+
+func Test_infobaseInfoShared(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	SetRACCacheTTL(time.Second)
+	defer SetRACCacheTTL(0)
+
+	s := new(settings.Settings)
+	p := gomonkey.ApplyMethod(reflect.TypeOf(s), "GetLogPass", func(_ *settings.Settings, _ string) (string, string) {
+		return "admin", ""
+	})
+	defer p.Reset()
+
+	run := mock_models.NewMockIRunner(c)
+	job := new(ExporterCheckSheduleJob).Construct(s)
+	defer job.Stop()
+	state := new(ExporterInfobaseState).Construct(s)
+	defer state.Stop()
+
+	for _, exp := range []*BaseRACExporter{&job.BaseRACExporter, &state.BaseRACExporter} {
+		exp.clusters = testClusters(s, "123")
+		exp.runner = run
+	}
+
+	// infobase info по базе выполняется один раз для shedule_job и infobase_state
+	run.EXPECT().Run(gomock.Any()).Return("scheduled-jobs-deny : on\nsessions-deny : off\n", nil).Times(1)
+
+	info, err := job.getInfoBase(job.GetClusters()[0], "ib1", "hrm")
+	assert.NoError(t, err)
+	assert.Equal(t, "on", info["scheduled-jobs-deny"])
+
+	info, err = state.getInfoBase(state.GetClusters()[0], "ib1", "hrm")
+	assert.NoError(t, err)
+	assert.Equal(t, "off", info["sessions-deny"])
+}
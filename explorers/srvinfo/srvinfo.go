@@ -0,0 +1,329 @@
+// Package srvinfo чтение файлов реестра кластера 1С (srvinfo/reg_<порт>/1CV8Clst.lst, в старых версиях 1CV8Reg.lst)
+// без обращения к RAS
+package srvinfo
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/explorers/brace"
+	"github.com/pkg/errors"
+)
+
+// имена файла реестра кластера в порядке приоритета
+var registryFiles = []string{"1CV8Clst.lst", "1CV8Reg.lst"}
+
+// СУБД информационных баз, по ним записи баз отличаются от остальных записей реестра
+var dbmsTypes = []string{"PostgreSQL", "MSSQLServer", "IBMDB2", "OracleDatabase"}
+
+var (
+	reGUID      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	rePortRange = regexp.MustCompile(`^\d+:\d+$`)
+	reNumber    = regexp.MustCompile(`^\d+$`)
+)
+
+// Cluster кластер из файла реестра
+type Cluster struct {
+	Reg       string // каталог reg_<порт>
+	GUID      string
+	Name      string
+	Host      string
+	Port      int64
+	Infobases []Infobase
+	Servers   []Server
+	Managers  []Manager
+}
+
+// Infobase информационная база кластера
+type Infobase struct {
+	GUID     string
+	Name     string
+	Descr    string
+	DBMS     string
+	DBServer string
+	DBName   string
+}
+
+// Server рабочий сервер кластера
+type Server struct {
+	GUID      string
+	Name      string
+	Host      string
+	Port      int64
+	PortRange string
+	Rules     []Rule
+}
+
+// Manager менеджер кластера
+type Manager struct {
+	GUID   string
+	Descr  string
+	Host   string
+	Port   int64
+	Server string // идентификатор рабочего сервера, на котором запущен менеджер
+}
+
+// Rule требование назначения функциональности рабочего сервера
+type Rule struct {
+	GUID        string
+	ObjectType  string // тип объекта требования в том виде, как он записан в файле
+	Infobase    string // имя базы, пустое - для всех баз
+	RuleType    int64  // тип требования в том виде, как он записан в файле
+	Application string // значение дополнительного параметра
+	Priority    int64
+}
+
+// как часто Find проверяет изменение файлов реестра, Clusters проверяет их при каждом вызове
+const findRescan = time.Minute
+
+type registry struct {
+	modTime time.Time
+	cluster *Cluster
+}
+
+// Reader читает файлы реестра кластеров из каталогов srvinfo, файл перечитывается если изменилось время его модификации
+type Reader struct {
+	mx        sync.Mutex
+	dirs      []string
+	files     map[string]*registry
+	scannedAt time.Time
+	// базы всех кластеров по идентификатору, перестраивается только при изменении файлов
+	index map[string]indexed
+}
+
+type indexed struct {
+	cluster  string
+	infobase Infobase
+}
+
+func NewReader(dirs []string) *Reader {
+	return &Reader{dirs: dirs, files: map[string]*registry{}}
+}
+
+// Clusters кластеры всех каталогов srvinfo. Файлы, которые не удалось прочитать, пропускаются, ошибка возвращается последняя
+func (r *Reader) Clusters() ([]*Cluster, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	return r.scan()
+}
+
+// Find база по идентификатору и идентификатор ее кластера. Файлы реестра проверяются не чаще findRescan,
+// поэтому поиск можно вызывать на каждую запись (например для каждого сеанса)
+func (r *Reader) Find(guid string) (Infobase, string, bool) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if time.Since(r.scannedAt) > findRescan {
+		r.scan()
+	}
+
+	v, ok := r.index[guid]
+	return v.infobase, v.cluster, ok
+}
+
+func (r *Reader) scan() (result []*Cluster, err error) {
+	r.scannedAt = time.Now()
+
+	changed := r.index == nil
+	paths, err := r.registryFiles()
+	for _, path := range paths {
+		info, e := os.Stat(path)
+		if e != nil {
+			err = e
+			continue
+		}
+
+		reg, ok := r.files[path]
+		if !ok || !reg.modTime.Equal(info.ModTime()) {
+			cluster, e := ReadFile(path)
+			if e != nil {
+				err = e
+				continue
+			}
+
+			reg = &registry{modTime: info.ModTime(), cluster: cluster}
+			r.files[path] = reg
+			changed = true
+		}
+
+		result = append(result, reg.cluster)
+	}
+
+	// удаленные файлы больше не нужны
+	for path := range r.files {
+		if !slices.Contains(paths, path) {
+			delete(r.files, path)
+			changed = true
+		}
+	}
+
+	if changed {
+		r.index = map[string]indexed{}
+		for _, cl := range result {
+			for _, ib := range cl.Infobases {
+				r.index[ib.GUID] = indexed{cluster: cl.GUID, infobase: ib}
+			}
+		}
+	}
+
+	return result, err
+}
+
+// registryFiles файлы реестра в каталогах reg_<порт>, из двух имен берется первое найденное
+func (r *Reader) registryFiles() (result []string, err error) {
+	for _, dir := range r.dirs {
+		regs, e := filepath.Glob(filepath.Join(dir, "reg_*"))
+		if e != nil {
+			err = e
+			continue
+		}
+
+		for _, reg := range regs {
+			for _, name := range registryFiles {
+				if path := filepath.Join(reg, name); fileExists(path) {
+					result = append(result, path)
+					break
+				}
+			}
+		}
+	}
+
+	return result, err
+}
+
+// ReadFile разбор файла реестра кластера
+func ReadFile(path string) (*Cluster, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "ошибка чтения файла реестра кластера")
+	}
+
+	// файл в utf-8 с BOM
+	data = []byte(strings.TrimPrefix(string(data), "\xEF\xBB\xBF"))
+
+	objects, _ := brace.ReadObjects(data)
+	if len(objects) == 0 {
+		return nil, errors.Errorf("файл реестра кластера %q не содержит данных", path)
+	}
+
+	return parseCluster(filepath.Base(filepath.Dir(path)), objects[0]), nil
+}
+
+// parseCluster кластер описан корневым списком {GUID,"имя",порт,"хост",...}, базы, серверы и менеджеры кластера ищутся
+// по всему дереву по виду записи, так как их расположение отличается в разных версиях платформы
+func parseCluster(reg string, root brace.Node) *Cluster {
+	cluster := &Cluster{Reg: reg}
+	if isGUID(root.Item(0)) {
+		cluster.GUID, cluster.Name, cluster.Port, cluster.Host = root.Item(0).Value, root.Item(1).Value, root.Item(2).Int(), root.Item(3).Value
+	}
+
+	var walk func(n brace.Node)
+	walk = func(n brace.Node) {
+		switch {
+		case isInfobase(n):
+			cluster.Infobases = append(cluster.Infobases, Infobase{
+				GUID:     n.Item(0).Value,
+				Name:     n.Item(1).Value,
+				Descr:    n.Item(2).Value,
+				DBMS:     n.Item(3).Value,
+				DBServer: n.Item(4).Value,
+				DBName:   n.Item(5).Value,
+			})
+			return
+		case isServer(n):
+			cluster.Servers = append(cluster.Servers, Server{
+				GUID:      n.Item(0).Value,
+				Name:      n.Item(1).Value,
+				Host:      n.Item(2).Value,
+				Port:      n.Item(3).Int(),
+				PortRange: n.Item(4).Value,
+				Rules:     parseRules(n),
+			})
+			return
+		case isManager(n):
+			cluster.Managers = append(cluster.Managers, Manager{
+				GUID:   n.Item(0).Value,
+				Descr:  n.Item(1).Value,
+				Host:   n.Item(2).Value,
+				Port:   n.Item(3).Int(),
+				Server: n.Item(4).Value,
+			})
+			return
+		}
+
+		for _, item := range n.List {
+			walk(item)
+		}
+	}
+	walk(root)
+
+	return cluster
+}
+
+// parseRules требования назначения функциональности, записанные внутри записи рабочего сервера
+func parseRules(server brace.Node) (result []Rule) {
+	var walk func(n brace.Node)
+	walk = func(n brace.Node) {
+		if isRule(n) {
+			result = append(result, Rule{
+				GUID:        n.Item(0).Value,
+				ObjectType:  n.Item(1).Value,
+				Infobase:    n.Item(2).Value,
+				RuleType:    n.Item(3).Int(),
+				Application: n.Item(4).Value,
+				Priority:    n.Item(5).Int(),
+			})
+			return
+		}
+
+		for _, item := range n.List {
+			walk(item)
+		}
+	}
+
+	for _, item := range server.List {
+		walk(item)
+	}
+
+	return result
+}
+
+// isInfobase запись базы: {GUID,"имя","описание","СУБД","сервер СУБД","имя БД",...}
+func isInfobase(n brace.Node) bool {
+	return len(n.List) >= 6 && isGUID(n.Item(0)) && slices.Contains(dbmsTypes, n.Item(3).Value)
+}
+
+// isServer запись рабочего сервера: {GUID,"имя","хост",порт агента,"диапазон портов",...}
+func isServer(n brace.Node) bool {
+	return len(n.List) >= 5 && isGUID(n.Item(0)) && n.Item(3).Int() > 0 && rePortRange.MatchString(n.Item(4).Value)
+}
+
+// isManager запись менеджера кластера: {GUID,"описание","хост",порт,GUID рабочего сервера,...}
+func isManager(n brace.Node) bool {
+	return len(n.List) >= 5 && isGUID(n.Item(0)) && isNumber(n.Item(3)) && n.Item(3).Int() > 0 && isGUID(n.Item(4))
+}
+
+// isRule запись требования назначения функциональности: {GUID,тип объекта,"имя базы",тип требования,"доп. параметр",приоритет,...}
+func isRule(n brace.Node) bool {
+	return len(n.List) >= 6 && isGUID(n.Item(0)) && len(n.Item(1).List) == 0 && !isGUID(n.Item(1)) && len(n.Item(2).List) == 0 &&
+		isNumber(n.Item(3)) && n.Item(3).Int() <= 2 && isNumber(n.Item(5))
+}
+
+func isNumber(n brace.Node) bool {
+	return len(n.List) == 0 && reNumber.MatchString(n.Value)
+}
+
+func isGUID(n brace.Node) bool {
+	return len(n.List) == 0 && reGUID.MatchString(n.Value)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
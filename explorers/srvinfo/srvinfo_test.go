@@ -0,0 +1,108 @@
+package srvinfo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClst = "\xEF\xBB\xBF" + `{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,"Локальный кластер",1541,"srv1",0,0,0,0,0,0,
+{2,
+{7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d,"hrm","Зарплата ""и"" кадры","PostgreSQL","db1","hrm","postgres","",0,"",0,0,"",""},
+{0f1e2d3c-4b5a-4968-8776-655443322110,"acc","","MSSQLServer","db2\sql","acc","sa","",1,"",0,0,"",""}
+},
+{1,
+{c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f,"Центральный сервер","srv1",1540,"1560:1591",0,0,1,0,0,
+{2,
+{3b4c5d6e-7f80-4a91-b2c3-d4e5f6a7b8c9,"BackgroundJob","hrm",0,"",10},
+{4c5d6e7f-8091-4ab2-83d4-e5f6a7b8c9d0,"Any","",2,"",0}
+}}
+},
+{1,
+{9a8b7c6d-5e4f-4321-8fed-cba987654321,"Главный менеджер кластера","srv1",1541,c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f}
+},
+{0},0}`
+
+func Test_ReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reg_1541", "1CV8Clst.lst")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(testClst), 0o644))
+
+	cluster, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Cluster{
+		Reg:  "reg_1541",
+		GUID: "5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60",
+		Name: "Локальный кластер",
+		Host: "srv1",
+		Port: 1541,
+		Infobases: []Infobase{
+			{GUID: "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d", Name: "hrm", Descr: `Зарплата "и" кадры`, DBMS: "PostgreSQL", DBServer: "db1", DBName: "hrm"},
+			{GUID: "0f1e2d3c-4b5a-4968-8776-655443322110", Name: "acc", DBMS: "MSSQLServer", DBServer: `db2\sql`, DBName: "acc"},
+		},
+		Servers: []Server{
+			{GUID: "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f", Name: "Центральный сервер", Host: "srv1", Port: 1540, PortRange: "1560:1591", Rules: []Rule{
+				{GUID: "3b4c5d6e-7f80-4a91-b2c3-d4e5f6a7b8c9", ObjectType: "BackgroundJob", Infobase: "hrm", Priority: 10},
+				{GUID: "4c5d6e7f-8091-4ab2-83d4-e5f6a7b8c9d0", ObjectType: "Any", RuleType: 2},
+			}},
+		},
+		Managers: []Manager{
+			{GUID: "9a8b7c6d-5e4f-4321-8fed-cba987654321", Descr: "Главный менеджер кластера", Host: "srv1", Port: 1541, Server: "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f"},
+		},
+	}, cluster)
+
+	_, err = ReadFile(filepath.Join(filepath.Dir(path), "1CV8Reg.lst"))
+	assert.Error(t, err)
+}
+
+func Test_Reader(t *testing.T) {
+	dir := t.TempDir()
+	reg := filepath.Join(dir, "reg_1541")
+	require.NoError(t, os.MkdirAll(reg, 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "reg_2541"), 0o755)) // каталог без файла реестра пропускается
+
+	// файл старых версий платформы
+	path := filepath.Join(reg, "1CV8Reg.lst")
+	require.NoError(t, os.WriteFile(path, []byte(testClst), 0o644))
+
+	r := NewReader([]string{dir})
+	clusters, err := r.Clusters()
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	assert.Len(t, clusters[0].Infobases, 2)
+
+	// без изменения времени модификации файл не перечитывается
+	cached := clusters[0]
+	clusters, err = r.Clusters()
+	require.NoError(t, err)
+	assert.Same(t, cached, clusters[0])
+
+	ib, cluster, ok := r.Find("7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d")
+	assert.True(t, ok)
+	assert.Equal(t, "hrm", ib.Name)
+	assert.Equal(t, "5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60", cluster)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{5e2b1f3c-6a7d-4c8e-9f0a-1b2c3d4e5f60,"Локальный кластер",1541,"srv1"}`), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	// поиск не проверяет файлы чаще findRescan
+	_, _, ok = r.Find("7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d")
+	assert.True(t, ok)
+
+	clusters, err = r.Clusters()
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	assert.Empty(t, clusters[0].Infobases)
+	_, _, ok = r.Find("7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d")
+	assert.False(t, ok)
+
+	// удаленный файл
+	require.NoError(t, os.Remove(path))
+	clusters, err = r.Clusters()
+	assert.NoError(t, err)
+	assert.Empty(t, clusters)
+}
@@ -0,0 +1,175 @@
+package tj
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/pkg/errors"
+)
+
+const (
+	logCfgName = "logcfg.xml"
+	// по этой отметке понимаем, что logcfg.xml сформирован нами, а не отредактирован вручную
+	logCfgMark = "<!-- generated by prometheus_1C_exporter -->"
+	backupExt  = ".exporter.bak"
+)
+
+type xmlConfig struct {
+	XMLName xml.Name `xml:"config"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Log     xmlLog   `xml:"log"`
+}
+
+type xmlLog struct {
+	Location   string        `xml:"location,attr"`
+	History    int           `xml:"history,attr"`
+	Events     []xmlEvent    `xml:"event"`
+	Properties []xmlProperty `xml:"property"`
+}
+
+type xmlEvent struct {
+	Conditions []xmlCondition
+}
+
+type xmlCondition struct {
+	XMLName  xml.Name
+	Property string `xml:"property,attr"`
+	Value    string `xml:"value,attr"`
+}
+
+type xmlProperty struct {
+	Name string `xml:"name,attr"`
+}
+
+// LogCfg управляет logcfg.xml: записывает сформированный по настройкам файл и восстанавливает исходный при откате.
+// Исходный файл сохраняется рядом (logcfg.xml.exporter.bak), что бы его можно было восстановить и после аварийного завершения
+type LogCfg struct {
+	mx   sync.Mutex
+	path string
+}
+
+func NewLogCfg() *LogCfg {
+	return new(LogCfg)
+}
+
+// RenderLogCfg формирует содержимое logcfg.xml
+func RenderLogCfg(cfg *settings.TechLog) ([]byte, error) {
+	conf := xmlConfig{
+		Xmlns: "http://v8.1c.ru/v8/tech-log",
+		Log: xmlLog{
+			Location: cfg.Location,
+			History:  cfg.History,
+		},
+	}
+
+	for _, e := range cfg.Events {
+		event := xmlEvent{}
+		event.Conditions = append(event.Conditions, xmlCondition{XMLName: xml.Name{Local: "eq"}, Property: "name", Value: strings.ToLower(e.Name)})
+		if e.Duration > 0 {
+			event.Conditions = append(event.Conditions, xmlCondition{XMLName: xml.Name{Local: "ge"}, Property: "durationus", Value: strconv.FormatInt(e.Duration.Microseconds(), 10)})
+		}
+		if e.Infobase != "" {
+			event.Conditions = append(event.Conditions, xmlCondition{XMLName: xml.Name{Local: "eq"}, Property: "p:processname", Value: e.Infobase})
+		}
+
+		conf.Log.Events = append(conf.Log.Events, event)
+	}
+
+	for _, p := range cfg.Properties {
+		conf.Log.Properties = append(conf.Log.Properties, xmlProperty{Name: strings.ToLower(p)})
+	}
+
+	data, err := xml.MarshalIndent(conf, "", "\t")
+	if err != nil {
+		return nil, errors.Wrap(err, "ошибка формирования logcfg.xml")
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(logCfgMark + "\n")
+	b.Write(data)
+	b.WriteString("\n")
+
+	return b.Bytes(), nil
+}
+
+// Apply записывает logcfg.xml по настройкам, если настройки не заданы - откатывает изменения
+func (l *LogCfg) Apply(cfg *settings.TechLog) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if cfg == nil || cfg.ConfDir == "" || len(cfg.Events) == 0 {
+		return l.rollback()
+	}
+
+	path := filepath.Join(cfg.ConfDir, logCfgName)
+	if l.path != "" && l.path != path {
+		// сменился каталог conf, старый файл нужно вернуть в исходное состояние
+		if err := l.rollback(); err != nil {
+			return err
+		}
+	}
+
+	data, err := RenderLogCfg(cfg)
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return errors.Wrapf(err, "ошибка чтения %q", path)
+	case bytes.Equal(current, data):
+		l.path = path
+		return nil
+	case !bytes.Contains(current, []byte(logCfgMark)):
+		// файл не наш, сохраняем его для отката
+		if err := os.WriteFile(path+backupExt, current, 0o644); err != nil {
+			return errors.Wrap(err, "ошибка сохранения исходного logcfg.xml")
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return errors.Wrapf(err, "ошибка записи %q", path)
+	}
+
+	l.path = path
+	return nil
+}
+
+// Rollback восстанавливает исходный logcfg.xml, если его не было - удаляет сформированный
+func (l *LogCfg) Rollback() error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	return l.rollback()
+}
+
+func (l *LogCfg) rollback() error {
+	if l.path == "" {
+		return nil
+	}
+
+	path := l.path
+	l.path = ""
+
+	if original, err := os.ReadFile(path + backupExt); err == nil {
+		if err := os.WriteFile(path, original, 0o644); err != nil {
+			return errors.Wrapf(err, "ошибка восстановления %q", path)
+		}
+		return os.Remove(path + backupExt)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "ошибка удаления %q", path)
+	}
+
+	return nil
+}
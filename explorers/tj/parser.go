@@ -0,0 +1,156 @@
+// Package tj разбор технологического журнала 1С
+package tj
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event событие технологического журнала
+type Event struct {
+	Time     time.Time
+	Duration time.Duration
+	Name     string
+	Level    int
+	Props    map[string]string
+}
+
+// начало события: mm:ss.ffffff-длительность,СОБЫТИЕ,уровень,
+// в старых версиях платформы дробная часть секунд и длительность в десятитысячных долях секунды (4 знака)
+var reHeader = regexp.MustCompile(`(?m)^(\d{2}):(\d{2})\.(\d{4}|\d{6})-(\d+),(\w+),(\d+),?`)
+
+// Infobase имя информационной базы события
+func (e *Event) Infobase() string {
+	return e.Props["p:processName"]
+}
+
+// Context последняя строка контекста события (сам контекст может содержать весь стек вызовов)
+func (e *Event) Context() string {
+	lines := strings.Split(strings.TrimSpace(e.Props["Context"]), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// ParseEvents разбирает события из data. hour - час, за который записан файл (определяется по имени файла yyMMddHH.log)
+func ParseEvents(data []byte, hour time.Time) []*Event {
+	var result []*Event
+
+	bounds := reHeader.FindAllSubmatchIndex(data, -1)
+	for i, b := range bounds {
+		end := len(data)
+		if i+1 < len(bounds) {
+			end = bounds[i+1][0]
+		}
+
+		if e := parseEvent(data, b, end, hour); e != nil {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// eventStarts позиции начала событий в data
+func eventStarts(data []byte) []int {
+	var result []int
+	for _, b := range reHeader.FindAllIndex(data, -1) {
+		result = append(result, b[0])
+	}
+
+	return result
+}
+
+func parseEvent(data []byte, header []int, end int, hour time.Time) *Event {
+	field := func(n int) string {
+		return string(data[header[n*2]:header[n*2+1]])
+	}
+
+	min, _ := strconv.Atoi(field(1))
+	sec, _ := strconv.Atoi(field(2))
+	frac := field(3)
+	fracValue, _ := strconv.Atoi(frac)
+	duration, _ := strconv.ParseInt(field(4), 10, 64)
+	level, _ := strconv.Atoi(field(6))
+
+	unit := time.Microsecond
+	if len(frac) == 4 {
+		unit = time.Microsecond * 100
+	}
+
+	return &Event{
+		Time:     hour.Add(time.Duration(min)*time.Minute + time.Duration(sec)*time.Second + time.Duration(fracValue)*unit),
+		Duration: time.Duration(duration) * unit,
+		Name:     field(5),
+		Level:    level,
+		Props:    parseProps(bytes.TrimRight(data[header[1]:end], "\r\n")),
+	}
+}
+
+// parseProps разбирает свойства события key=value,key='value',key="value".
+// Значения в кавычках могут содержать запятые и переводы строк, кавычка внутри значения удваивается
+func parseProps(data []byte) map[string]string {
+	result := map[string]string{}
+
+	for i := 0; i < len(data); {
+		eq := bytes.IndexByte(data[i:], '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.TrimSpace(string(data[i : i+eq]))
+		i += eq + 1
+
+		var value string
+		if i < len(data) && (data[i] == '\'' || data[i] == '"') {
+			value, i = readQuoted(data, i)
+		} else {
+			end := bytes.IndexByte(data[i:], ',')
+			if end < 0 {
+				end = len(data) - i
+			}
+			value = strings.TrimRight(string(data[i:i+end]), "\r\n")
+			i += end
+		}
+
+		result[key] = value
+
+		// пропускаем разделитель
+		for i < len(data) && (data[i] == ',' || data[i] == '\r' || data[i] == '\n') {
+			i++
+		}
+	}
+
+	return result
+}
+
+func readQuoted(data []byte, start int) (string, int) {
+	quote := data[start]
+
+	var b strings.Builder
+	i := start + 1
+	for i < len(data) {
+		if data[i] == quote {
+			if i+1 < len(data) && data[i+1] == quote {
+				b.WriteByte(quote)
+				i += 2
+				continue
+			}
+			return b.String(), i + 1
+		}
+
+		b.WriteByte(data[i])
+		i++
+	}
+
+	return b.String(), i
+}
+
+// FileHour час, за который записан файл журнала (имя файла yyMMddHH.log)
+func FileHour(path string) (time.Time, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	t, err := time.ParseInLocation("06010215", name, time.Local)
+	return t, err == nil
+}
@@ -0,0 +1,179 @@
+package tj
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// максимальный объем, читаемый из одного файла за один проход
+const maxChunk = 64 << 20
+
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// Tailer читает новые события из каталогов технологического журнала (<каталог>/<процесс>_<pid>/yyMMddHH.log).
+// Позиции чтения файлов сохраняются в statePath, что бы после перезапуска продолжить с того же места
+type Tailer struct {
+	mx        sync.Mutex
+	dirs      []string
+	statePath string
+	offsets   map[string]int64
+	started   bool
+}
+
+func NewTailer(dirs []string, statePath string) *Tailer {
+	return &Tailer{
+		dirs:      dirs,
+		statePath: statePath,
+		offsets:   map[string]int64{},
+	}
+}
+
+// Poll читает события, записанные с прошлого вызова. Последнее событие файла читается только после того,
+// как за ним появится следующее (оно может дописываться), либо после ротации файла
+func (t *Tailer) Poll() ([]*Event, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if !t.started {
+		t.started = true
+
+		// если сохраненных позиций нет, то начинаем с конца уже существующих файлов, что бы не учитывать старые события
+		if err := t.loadState(); err != nil {
+			files, _ := t.files()
+			for _, f := range files {
+				if info, err := os.Stat(f); err == nil {
+					t.offsets[f] = info.Size()
+				}
+			}
+		}
+	}
+
+	files, err := t.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Event
+	var lastErr error
+	for i, f := range files {
+		// файл закрыт, если в том же каталоге уже есть файл за следующий час
+		closed := i+1 < len(files) && filepath.Dir(files[i+1]) == filepath.Dir(f)
+
+		events, err := t.read(f, closed)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result = append(result, events...)
+	}
+
+	// удаленные файлы больше не отслеживаем
+	for f := range t.offsets {
+		if !slices.Contains(files, f) {
+			delete(t.offsets, f)
+		}
+	}
+
+	if err := t.saveState(); err != nil {
+		lastErr = err
+	}
+
+	return result, lastErr
+}
+
+func (t *Tailer) files() ([]string, error) {
+	var result []string
+	for _, dir := range t.dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*", "*.log"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ошибка получения файлов журнала из %q", dir)
+		}
+		result = append(result, files...)
+	}
+
+	// имена файлов yyMMddHH.log, поэтому сортировка по имени это сортировка по времени в рамках каталога
+	slices.Sort(result)
+	return result, nil
+}
+
+func (t *Tailer) read(path string, closed bool) ([]*Event, error) {
+	hour, ok := FileHour(path)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ошибка открытия файла %q", path)
+	}
+	defer f.Close()
+
+	offset := t.offsets[path]
+	if info, err := f.Stat(); err == nil && info.Size() < offset {
+		offset = 0 // файл перезаписан
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, errors.Wrapf(err, "ошибка позиционирования в файле %q", path)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxChunk))
+	if err != nil {
+		return nil, errors.Wrapf(err, "ошибка чтения файла %q", path)
+	}
+
+	if offset == 0 && bytes.HasPrefix(data, bom) {
+		data = data[len(bom):]
+		offset += int64(len(bom))
+	}
+
+	// разбираем только завершенные события, незавершенное дочитаем в следующий раз
+	complete := len(data)
+	if !closed || len(data) == maxChunk {
+		starts := eventStarts(data)
+		switch {
+		case len(starts) > 1:
+			complete = starts[len(starts)-1]
+		case len(data) == maxChunk:
+			// одно событие больше maxChunk, что бы не зависнуть на нем считаем его прочитанным
+		default:
+			complete = 0
+		}
+	}
+
+	t.offsets[path] = offset + int64(complete)
+	return ParseEvents(data[:complete], hour), nil
+}
+
+func (t *Tailer) loadState() error {
+	if t.statePath == "" {
+		return errors.New("не задан файл состояния")
+	}
+
+	data, err := os.ReadFile(t.statePath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &t.offsets)
+}
+
+func (t *Tailer) saveState() error {
+	if t.statePath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(t.offsets)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(os.WriteFile(t.statePath, data, 0o644), "ошибка сохранения состояния чтения журнала")
+}
@@ -0,0 +1,153 @@
+package tj
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLog = `45:31.831006-15000,CALL,1,process=rphost,p:processName=hrm,OSThread=1234,t:clientID=5,Context='Форма.Вызов : ОбщийМодуль.Модуль
+ОбщийМодуль.Модуль.Модуль : 10 : Процедура()',Memory=123
+45:32.000001-2500,DBPOSTGRS,3,process=rphost,p:processName=hrm,Sql="SELECT 1, 'a'
+FROM ""T""",Rows=1
+45:33.100000-0,EXCP,1,process=rphost,Exception=DataBaseException,Descr='Ошибка ''в'' запросе'
+`
+
+func Test_ParseEvents(t *testing.T) {
+	hour, ok := FileHour("/tj/rphost_123/25030314.log")
+	require.True(t, ok)
+
+	events := ParseEvents([]byte(testLog), hour)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, "CALL", events[0].Name)
+	assert.Equal(t, 1, events[0].Level)
+	assert.Equal(t, time.Millisecond*15, events[0].Duration)
+	assert.Equal(t, time.Date(2025, 3, 3, 14, 45, 31, 831006000, time.Local), events[0].Time)
+	assert.Equal(t, "hrm", events[0].Infobase())
+	assert.Equal(t, "ОбщийМодуль.Модуль.Модуль : 10 : Процедура()", events[0].Context())
+	assert.Equal(t, "123", events[0].Props["Memory"])
+
+	assert.Equal(t, "DBPOSTGRS", events[1].Name)
+	assert.Equal(t, "SELECT 1, 'a'\nFROM \"T\"", events[1].Props["Sql"])
+	assert.Equal(t, "1", events[1].Props["Rows"])
+
+	assert.Equal(t, "Ошибка 'в' запросе", events[2].Props["Descr"])
+
+	// старый формат: десятитысячные доли секунды
+	events = ParseEvents([]byte("01:02.0003-20000,TLOCK,2,p:processName=zup\n"), hour)
+	require.Len(t, events, 1)
+	assert.Equal(t, time.Second*2, events[0].Duration)
+	assert.Equal(t, "zup", events[0].Infobase())
+}
+
+func Test_Tailer(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "rphost_123"), 0o755))
+
+	file1 := filepath.Join(dir, "rphost_123", "25030314.log")
+	require.NoError(t, os.WriteFile(file1, []byte("\xEF\xBB\xBF00:01.000000-1,CALL,1,p:processName=old\n"), 0o644))
+
+	state := filepath.Join(dir, "state.json")
+	tailer := NewTailer([]string{dir}, state)
+
+	// существующие файлы при первом запуске пропускаются
+	events, err := tailer.Poll()
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	appendFile(t, file1, "00:02.000000-1,CALL,1,p:processName=hrm\n00:03.000000-1,EXCP,1,Descr='многострочное\n")
+	events, err = tailer.Poll()
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) { // последнее событие может дописываться
+		assert.Equal(t, "hrm", events[0].Infobase())
+	}
+
+	// после перезапуска продолжаем с сохраненной позиции
+	tailer = NewTailer([]string{dir}, state)
+	appendFile(t, file1, "описание'\n00:04.000000-1,CALL,1\n")
+	events, err = tailer.Poll()
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "EXCP", events[0].Name)
+		assert.Equal(t, "многострочное\nописание", events[0].Props["Descr"])
+	}
+
+	// ротация: с появлением нового файла старый дочитывается полностью
+	file2 := filepath.Join(dir, "rphost_123", "25030315.log")
+	require.NoError(t, os.WriteFile(file2, []byte("\xEF\xBB\xBF00:01.000000-1,TTIMEOUT,1\n00:02.000000-1,CALL,1\n"), 0o644))
+	events, err = tailer.Poll()
+	require.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "CALL", events[0].Name)
+		assert.Equal(t, "TTIMEOUT", events[1].Name)
+	}
+
+	// удаленные файлы забываются
+	require.NoError(t, os.Remove(file1))
+	_, err = tailer.Poll()
+	require.NoError(t, err)
+	assert.NotContains(t, tailer.offsets, file1)
+}
+
+func Test_LogCfg(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logcfg.xml")
+	require.NoError(t, os.WriteFile(path, []byte("<config/>"), 0o644))
+
+	cfg := &settings.TechLog{
+		ConfDir:    dir,
+		Location:   "/var/log/1c/tj",
+		History:    24,
+		Properties: []string{"all"},
+		Events: []settings.TechLogEvent{
+			{Name: "CALL", Duration: time.Second},
+			{Name: "EXCP", Infobase: "hrm"},
+		},
+	}
+
+	data, err := RenderLogCfg(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<log location="/var/log/1c/tj" history="24">`)
+	assert.Contains(t, string(data), `<eq property="name" value="call"></eq>`)
+	assert.Contains(t, string(data), `<ge property="durationus" value="1000000"></ge>`)
+	assert.Contains(t, string(data), `<eq property="p:processname" value="hrm"></eq>`)
+	assert.Contains(t, string(data), `<property name="all"></property>`)
+
+	logcfg := NewLogCfg()
+	require.NoError(t, logcfg.Apply(cfg))
+	current, _ := os.ReadFile(path)
+	assert.Equal(t, data, current)
+
+	// повторное применение (SIGHUP) не затирает сохраненный исходный файл
+	cfg.History = 48
+	require.NoError(t, logcfg.Apply(cfg))
+	current, _ = os.ReadFile(path)
+	assert.Contains(t, string(current), `history="48"`)
+
+	require.NoError(t, logcfg.Rollback())
+	current, _ = os.ReadFile(path)
+	assert.Equal(t, "<config/>", string(current))
+	assert.NoFileExists(t, path+backupExt)
+
+	// исходного файла не было - при откате сформированный удаляется
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, logcfg.Apply(cfg))
+	assert.FileExists(t, path)
+	require.NoError(t, logcfg.Apply(nil))
+	assert.NoFileExists(t, path)
+}
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+}
@@ -0,0 +1,307 @@
+package settings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// источники учетных данных пользователей БД
+const (
+	CredentialsREST   = "rest"   // сервис из секции DBCredentials
+	CredentialsFile   = "file"   // yaml/json файл со списком баз, перечитывается при изменении
+	CredentialsEnv    = "env"    // переменные окружения по шаблону имени
+	CredentialsStatic = "static" // один пользователь для всех баз
+	CredentialsExec   = "exec"   // внешняя команда, которая выводит json со списком баз
+)
+
+// CredentialProvider описание источника учетных данных БД в секции Credentials.
+// Источники опрашиваются по порядку, используется первый, который вернул пользователя для базы
+type CredentialProvider struct {
+	Type string `yaml:"Type"`
+
+	// file - путь к файлу
+	Path string `yaml:"Path"`
+
+	// static - имя пользователя и пароль,
+	// env - шаблоны имен переменных окружения, {base} заменяется на имя базы в верхнем регистре (IB_{base}_USER)
+	User     string `yaml:"User"`
+	Password string `yaml:"Password"`
+
+	// exec - команда и ее аргументы
+	Command string        `yaml:"Command"`
+	Args    []string      `yaml:"Args"`
+	Timeout time.Duration `yaml:"Timeout" default:"30s"`
+	// exec - как долго используется результат команды, после этого команда запускается повторно
+	Refresh time.Duration `yaml:"Refresh" default:"1h"`
+}
+
+type credentialSource interface {
+	// lookup получает настройки, из которых вызван, источник не должен хранить ссылку на них:
+	// при перечитывании настроек их содержимое копируется в действующий объект (см. app.reloadWatcher)
+	lookup(s *Settings, ibname string) (login, pass string)
+	// refresh принудительное обновление данных (если для базы не нашлось пользователя)
+	refresh()
+}
+
+// HasDBCredentials настроен ли хотя бы один источник учетных данных БД
+func (s *Settings) HasDBCredentials() bool {
+	return len(s.Credentials) > 0 || (s.DBCredentials != nil && s.DBCredentials.URL != "")
+}
+
+// useREST используется ли REST сервис для получения учетных данных
+func (s *Settings) useREST() bool {
+	if s.DBCredentials == nil || s.DBCredentials.URL == "" {
+		return false
+	}
+	if len(s.Credentials) == 0 {
+		return true
+	}
+
+	for _, p := range s.Credentials {
+		if p != nil && p.Type == CredentialsREST {
+			return true
+		}
+	}
+
+	return false
+}
+
+// initCredentials формирует цепочку источников учетных данных из секции Credentials,
+// если секция не заполнена используется только REST
+func (s *Settings) initCredentials() error {
+	s.credentials = nil
+	if len(s.Credentials) == 0 {
+		s.credentials = []credentialSource{new(restCredentials)}
+		return nil
+	}
+
+	for i, p := range s.Credentials {
+		if p == nil {
+			continue
+		}
+
+		var source credentialSource
+		switch p.Type {
+		case CredentialsREST:
+			source = new(restCredentials)
+		case CredentialsFile:
+			if p.Path == "" {
+				return fmt.Errorf("Credentials[%d]: для источника %q не задан Path", i, p.Type)
+			}
+			source = &fileCredentials{path: p.Path}
+		case CredentialsEnv:
+			if p.User == "" {
+				return fmt.Errorf("Credentials[%d]: для источника %q не задан шаблон User", i, p.Type)
+			}
+			source = &envCredentials{user: p.User, pass: p.Password}
+		case CredentialsStatic:
+			if p.User == "" {
+				return fmt.Errorf("Credentials[%d]: для источника %q не задан User", i, p.Type)
+			}
+			source = &staticCredentials{user: p.User, pass: p.Password}
+		case CredentialsExec:
+			if p.Command == "" {
+				return fmt.Errorf("Credentials[%d]: для источника %q не задан Command", i, p.Type)
+			}
+			source = &execCredentials{command: p.Command, args: p.Args, timeout: p.Timeout, ttl: p.Refresh}
+		default:
+			return fmt.Errorf("Credentials[%d]: неизвестный источник учетных данных %q", i, p.Type)
+		}
+
+		s.credentials = append(s.credentials, source)
+	}
+
+	return nil
+}
+
+func (s *Settings) credentialSources() []credentialSource {
+	if s.credentials == nil {
+		return []credentialSource{new(restCredentials)}
+	}
+
+	return s.credentials
+}
+
+// refreshCredentials принудительно обновляет данные источников (кроме REST, он обновляется отдельно)
+func (s *Settings) refreshCredentials() {
+	for _, source := range s.credentialSources() {
+		source.refresh()
+	}
+}
+
+func findBase(bases []Bases, ibname string) (login, pass string) {
+	for _, base := range bases {
+		if strings.EqualFold(base.Name, ibname) {
+			return base.UserName, base.UserPass
+		}
+	}
+
+	return "", ""
+}
+
+// restCredentials данные, полученные от REST (см. GetDBCredentials)
+type restCredentials struct{}
+
+func (c *restCredentials) lookup(s *Settings, ibname string) (string, string) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return findBase(s.bases, ibname)
+}
+
+func (c *restCredentials) refresh() {}
+
+// fileCredentials список баз из yaml или json файла, файл перечитывается если изменилось время его модификации
+type fileCredentials struct {
+	mx      sync.Mutex
+	path    string
+	modTime time.Time
+	bases   []Bases
+}
+
+func (c *fileCredentials) lookup(_ *Settings, ibname string) (string, string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if err := c.load(); err != nil {
+		logger.DefaultLogger.Error(err)
+	}
+
+	return findBase(c.bases, ibname)
+}
+
+func (c *fileCredentials) load() error {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return errors.Wrap(err, "файл учетных данных недоступен")
+	}
+	if info.ModTime().Equal(c.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return errors.Wrap(err, "ошибка чтения файла учетных данных")
+	}
+
+	// json является подмножеством yaml, поэтому один разбор подходит для обоих форматов
+	var bases []Bases
+	if err := yaml.Unmarshal(data, &bases); err != nil {
+		return errors.Wrapf(err, "не удалось десериализовать файл учетных данных %q", c.path)
+	}
+
+	logger.DefaultLogger.With("path", c.path, "bases", len(bases)).Info("загружен файл учетных данных")
+	c.bases, c.modTime = bases, info.ModTime()
+	return nil
+}
+
+func (c *fileCredentials) refresh() {}
+
+// envCredentials имена переменных окружения формируются по шаблону, {base} заменяется на имя базы
+type envCredentials struct {
+	user, pass string
+}
+
+func (c *envCredentials) lookup(_ *Settings, ibname string) (string, string) {
+	name := envName(ibname)
+	return os.Getenv(strings.ReplaceAll(c.user, "{base}", name)), os.Getenv(strings.ReplaceAll(c.pass, "{base}", name))
+}
+
+func (c *envCredentials) refresh() {}
+
+// envName имя базы в виде, допустимом для имени переменной окружения: верхний регистр, вместо спецсимволов "_"
+func envName(ibname string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, ibname)
+}
+
+// staticCredentials один пользователь для всех баз
+type staticCredentials struct {
+	user, pass string
+}
+
+func (c *staticCredentials) lookup(*Settings, string) (string, string) {
+	return c.user, c.pass
+}
+
+func (c *staticCredentials) refresh() {}
+
+// execCredentials список баз из вывода внешней команды, формат как у REST: [{"Name":"","UserName":"","UserPass":""}]
+type execCredentials struct {
+	mx       sync.Mutex
+	command  string
+	args     []string
+	timeout  time.Duration
+	ttl      time.Duration
+	loadedAt time.Time
+	bases    []Bases
+}
+
+func (c *execCredentials) lookup(_ *Settings, ibname string) (string, string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.loadedAt.IsZero() || (c.ttl > 0 && time.Since(c.loadedAt) > c.ttl) {
+		c.load()
+	}
+
+	return findBase(c.bases, ibname)
+}
+
+func (c *execCredentials) refresh() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.load()
+}
+
+func (c *execCredentials) load() {
+	// при ошибке команда повторяется не раньше чем через ttl, что бы не запускать ее на каждую базу
+	c.loadedAt = time.Now()
+
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = time.Second * 30
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stderr := new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, c.command, c.args...)
+	cmd.Stderr = stderr
+
+	logger.DefaultLogger.With("command", c.command).Info("получаем учетные данные БД внешней командой")
+	data, err := cmd.Output()
+	if err != nil {
+		logger.DefaultLogger.Error(errors.Wrapf(err, "ошибка выполнения команды %q: %s", c.command, strings.TrimSpace(stderr.String())))
+		return
+	}
+
+	var bases []Bases
+	if err := json.Unmarshal(data, &bases); err != nil {
+		logger.DefaultLogger.Error(errors.Wrap(err, "не удалось десериализовать вывод команды"))
+		return
+	}
+
+	c.bases = bases
+}
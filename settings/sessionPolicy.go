@@ -0,0 +1,184 @@
+package settings
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// режимы работы правил завершения сеансов
+const (
+	PolicyDryRun    = "dry-run"   // подходящие сеансы только записываются в журнал аудита
+	PolicyTerminate = "terminate" // подходящие сеансы завершаются (rac session terminate)
+)
+
+// SessionPolicies правила принудительного завершения сеансов. Если секция не задана, сеансы не завершаются
+type SessionPolicies struct {
+	Mode     string        `yaml:"Mode" default:"dry-run"`
+	Interval time.Duration `yaml:"Interval" default:"1m"`
+	// файл журнала аудита (строка json на каждое действие), если не задан действия пишутся только в лог экспортера
+	AuditLog string `yaml:"AuditLog"`
+	// сообщение, которое увидит пользователь завершенного сеанса
+	Message string `yaml:"Message" default:"Сеанс завершен администратором"`
+
+	// базы (имя или идентификатор) и пользователи, сеансы которых можно завершать (пусто - все), и которые не завершаются никогда
+	AllowBases []string `yaml:"AllowBases"`
+	DenyBases  []string `yaml:"DenyBases"`
+	AllowUsers []string `yaml:"AllowUsers"`
+	DenyUsers  []string `yaml:"DenyUsers"`
+
+	Rules []*SessionRule `yaml:"Rules"`
+}
+
+// SessionRule правило завершения сеансов, сеанс подходит если выполняются все заданные условия
+type SessionRule struct {
+	Name    string `yaml:"Name"`
+	Message string `yaml:"Message"` // если не задано - Message секции
+
+	AppID           []string      `yaml:"AppID"`           // приложения (app-id), например Designer
+	Hibernated      time.Duration `yaml:"Hibernated"`      // сеанс спит дольше
+	MemoryCurrent   ByteSize      `yaml:"MemoryCurrent"`   // память текущего вызова больше
+	DurationCurrent time.Duration `yaml:"DurationCurrent"` // текущий вызов выполняется дольше
+	// условия должны выполняться столько опросов подряд
+	Polls int `yaml:"Polls" default:"1"`
+	// время обслуживания "22:00-06:00", в это время правило не действует
+	MaintenanceWindow string `yaml:"MaintenanceWindow"`
+
+	windowFrom, windowTo time.Duration
+}
+
+// ByteSize размер в байтах, в настройках задается числом или строкой с единицами: 512MB, 10GB
+type ByteSize int64
+
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	size, err := ParseByteSize(v)
+	if err != nil {
+		return err
+	}
+
+	*b = size
+	return nil
+}
+
+// ParseByteSize разбор размера с единицами KB, MB, GB, TB (по 1024)
+func ParseByteSize(v string) (ByteSize, error) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+
+	multiplier := int64(1)
+	for i, unit := range []string{"KB", "MB", "GB", "TB"} {
+		if strings.HasSuffix(v, unit) {
+			multiplier = 1 << (10 * (i + 1))
+			v = strings.TrimSpace(strings.TrimSuffix(v, unit))
+			break
+		}
+	}
+	v = strings.TrimSuffix(v, "B")
+
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("некорректный размер %q", v)
+	}
+
+	return ByteSize(n * float64(multiplier)), nil
+}
+
+// initSessionPolicies проверка правил завершения сеансов
+func (s *Settings) initSessionPolicies() error {
+	p := s.SessionPolicies
+	if p == nil {
+		return nil
+	}
+
+	if p.Mode != PolicyDryRun && p.Mode != PolicyTerminate {
+		return fmt.Errorf("SessionPolicies: неизвестный режим %q, допустимо %s или %s", p.Mode, PolicyDryRun, PolicyTerminate)
+	}
+	// нативный клиент RAS не завершает сеансы, команда выполняется утилитой rac
+	if p.Mode == PolicyTerminate && s.RAC_Transport() == TransportNative && s.RAC_Path() == "" {
+		return fmt.Errorf("SessionPolicies: для режима %s с RAC.Transport: %s нужен RAC.Path, сеансы завершаются утилитой rac", PolicyTerminate, TransportNative)
+	}
+
+	for i, r := range p.Rules {
+		if r == nil {
+			continue
+		}
+		if r.Name == "" {
+			return fmt.Errorf("SessionPolicies.Rules[%d]: не задано имя правила", i)
+		}
+		if len(r.AppID) == 0 && r.Hibernated <= 0 && r.MemoryCurrent <= 0 && r.DurationCurrent <= 0 {
+			return fmt.Errorf("SessionPolicies.Rules[%d]: для правила %q не задано ни одного условия", i, r.Name)
+		}
+		if r.MaintenanceWindow != "" {
+			from, to, ok := strings.Cut(r.MaintenanceWindow, "-")
+			var errFrom, errTo error
+			r.windowFrom, errFrom = parseClock(from)
+			r.windowTo, errTo = parseClock(to)
+			if !ok || errFrom != nil || errTo != nil {
+				return fmt.Errorf("SessionPolicies.Rules[%d]: некорректное время обслуживания %q, ожидается вида 22:00-06:00", i, r.MaintenanceWindow)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseClock(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// InMaintenance попадает ли время во время обслуживания правила (окно может переходить через полночь)
+func (r *SessionRule) InMaintenance(t time.Time) bool {
+	if r.MaintenanceWindow == "" {
+		return false
+	}
+
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if r.windowFrom <= r.windowTo {
+		return clock >= r.windowFrom && clock < r.windowTo
+	}
+
+	return clock >= r.windowFrom || clock < r.windowTo
+}
+
+// GetMessage сообщение пользователю завершенного сеанса
+func (r *SessionRule) GetMessage(p *SessionPolicies) string {
+	if r.Message != "" {
+		return r.Message
+	}
+
+	return p.Message
+}
+
+// Allowed можно ли завершать сеансы пользователя в базе (списки Allow/Deny). База проверяется по имени и идентификатору.
+// Если заданы списки баз, а имя базы не определено (реестр баз еще не получен), сеанс не завершается
+func (p *SessionPolicies) Allowed(base, guid, user string) bool {
+	contains := func(list []string, v string) bool {
+		return v != "" && slices.ContainsFunc(list, func(item string) bool { return strings.EqualFold(item, v) })
+	}
+
+	if (len(p.AllowBases) > 0 || len(p.DenyBases) > 0) && base == "" {
+		return false
+	}
+	if contains(p.DenyBases, base) || contains(p.DenyBases, guid) || contains(p.DenyUsers, user) {
+		return false
+	}
+	if len(p.AllowBases) > 0 && !contains(p.AllowBases, base) && !contains(p.AllowBases, guid) {
+		return false
+	}
+	if len(p.AllowUsers) > 0 && !contains(p.AllowUsers, user) {
+		return false
+	}
+
+	return true
+}
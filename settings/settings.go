@@ -8,7 +8,7 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
@@ -27,10 +27,17 @@ const (
 	KindSummary         TypeMetricKind = "Summary"
 	KindGauge           TypeMetricKind = "Gauge"
 	KindNativeHistogram TypeMetricKind = "NativeHistogram"
+	KindHistogram       TypeMetricKind = "Histogram"
 )
 
 type TypeHostLabelFrom string
 
+// способ обращения к RAS
+const (
+	TransportRAC    = "rac"    // запуск утилиты rac
+	TransportNative = "native" // встроенный клиент протокола RAS
+)
+
 type Settings struct {
 	LogDir       string `yaml:"LogDir"`
 	SettingsPath string
@@ -47,19 +54,50 @@ type Settings struct {
 		TLSSkipVerify bool   `yaml:"TLSSkipVerify" json:"TLSSkipVerify,omitempty"`
 	} `yaml:"DBCredentials"`
 
+	// источники учетных данных пользователей БД в порядке опроса, если не задан используется только DBCredentials
+	Credentials []*CredentialProvider `yaml:"Credentials"`
+
 	RAC *struct {
 		Path  string `yaml:"Path"`
 		Port  string `yaml:"Port"`
 		Host  string `yaml:"Host"`
 		Login string `yaml:"Login"`
 		Pass  string `yaml:"Pass"`
+
+		Transport string `yaml:"Transport"`
 	} `yaml:"RAC"`
 
+	// список RAS (и кластеров на них) за которыми нужно наблюдать, если не задан используются параметры из секции RAC
+	Clusters []*Cluster `yaml:"Clusters"`
+
+	// модули для /probe: набор экспортеров и учетные данные с которыми они опрашивают переданный RAS
+	ProbeModules map[string]*ProbeModule `yaml:"ProbeModules"`
+
 	MetricKinds *struct {
 		Session      []TypeMetricKind `yaml:"Session" default:"[\"Summary\"]"`
 		SessionsData []TypeMetricKind `yaml:"SessionsData" default:"[\"Summary\"]" `
 	} `yaml:"MetricKinds" default:"{\"Session\": [\"Summary\"], \"SessionsData\": [\"Summary\"]}"`
 
+	// настройки технологического журнала, по ним формируется logcfg.xml
+	TechLog *TechLog `yaml:"TechLog"`
+
+	// интервал фонового сбора метрик, если не задан данные собираются в момент запроса prometheus.
+	// Для отдельного экспортера может быть переопределен свойством Interval
+	CollectInterval time.Duration `yaml:"CollectInterval"`
+
+	// максимальное количество одновременных обращений к RAS всех экспортеров, 0 (по умолчанию) - без ограничений.
+	// Таймаут команды задается свойством Timeout экспортера (по умолчанию 15s)
+	RACConcurrency int `yaml:"RACConcurrency"`
+
+	// время, в течение которого результат команды rac (session list, process list...) используется всеми экспортерами, 0 - не кешировать
+	RACCacheTTL time.Duration `yaml:"RACCacheTTL" default:"5s"`
+
+	// приостановка обращений к недоступному RAS
+	RASBreaker *Breaker `yaml:"RASBreaker" default:"{}"`
+
+	// правила принудительного завершения сеансов
+	SessionPolicies *SessionPolicies `yaml:"SessionPolicies"`
+
 	LabelModes *struct {
 		MetricNamePrefix string `yaml:"MetricNamePrefix"`
 	} `yaml:"LabelModes"`
@@ -68,13 +106,58 @@ type Settings struct {
 	// login, pass string        `yaml:"-"`
 	bases []Bases `yaml:"-"`
 
+	credentials []credentialSource `yaml:"-"`
+
 	LogLevel int `yaml:"LogLevel" default:"4"` // Уровень логирования от 2 до 6, где 2 - ошибка, 3 - предупреждение, 4 - информация, 5 - дебаг, 6 - трейс
 }
 
+// Cluster адрес RAS и учетные данные администратора кластера
+type Cluster struct {
+	Host  string `yaml:"Host"`
+	Port  string `yaml:"Port"`
+	Login string `yaml:"Login"`
+	Pass  string `yaml:"Pass"`
+	// имя или идентификатор кластера, если не задан будут использоваться все кластеры зарегистрированные на RAS
+	Name string `yaml:"Name"`
+}
+
+// ProbeModule настройки модуля /probe
+type ProbeModule struct {
+	Exporters []string `yaml:"Exporters"`
+	Login     string   `yaml:"Login"`
+	Pass      string   `yaml:"Pass"`
+	// имя или идентификатор кластера, если не задан будут опрошены все кластеры RAS
+	Cluster string `yaml:"Cluster"`
+}
+
+// TechLog описание технологического журнала, которое записывается в logcfg.xml
+type TechLog struct {
+	ConfDir    string         `yaml:"ConfDir"`  // каталог conf платформы, в него пишется logcfg.xml
+	Location   string         `yaml:"Location"` // каталог журнала
+	History    int            `yaml:"History" default:"24"`
+	Events     []TechLogEvent `yaml:"Events"`
+	Properties []string       `yaml:"Properties" default:"[\"all\"]"`
+}
+
+// TechLogEvent событие технологического журнала
+type TechLogEvent struct {
+	Name     string        `yaml:"Name"`
+	Duration time.Duration `yaml:"Duration"` // минимальная длительность события, если не задана - пишутся все
+	Infobase string        `yaml:"Infobase"` // если задан, пишутся события только этой базы
+}
+
+// Breaker после Failures ошибок подключения подряд обращения к RAS приостанавливаются на Backoff,
+// при повторных неудачах пауза удваивается вплоть до MaxBackoff. Failures = 0 - не используется
+type Breaker struct {
+	Failures   int           `yaml:"Failures" default:"3"`
+	Backoff    time.Duration `yaml:"Backoff" default:"10s"`
+	MaxBackoff time.Duration `yaml:"MaxBackoff" default:"5m"`
+}
+
 type Bases struct {
-	Name     string `json:"Name,omitempty"`
-	UserName string `json:"UserName,omitempty"`
-	UserPass string `json:"UserPass,omitempty"`
+	Name     string `json:"Name,omitempty" yaml:"Name"`
+	UserName string `json:"UserName,omitempty" yaml:"UserName"`
+	UserPass string `json:"UserPass,omitempty" yaml:"UserPass"`
 }
 
 func LoadSettings(filePath string) (*Settings, error) {
@@ -105,23 +188,31 @@ func LoadSettings(filePath string) (*Settings, error) {
 		s.RAC.Pass = pass
 	}
 
+	if err := s.initCredentials(); err != nil {
+		return nil, err
+	}
+	if err := s.initSessionPolicies(); err != nil {
+		return nil, err
+	}
+
 	s.SettingsPath = filePath
 	return s, nil
 }
 
+// GetLogPass учетные данные пользователя базы из первого источника цепочки Credentials, в котором они нашлись
 func (s *Settings) GetLogPass(ibname string) (login, pass string) {
+	// источники опрашиваются без блокировки, что бы долгая внешняя команда не задерживала обновление данных REST
 	s.mx.RLock()
-	defer s.mx.RUnlock()
+	sources := s.credentialSources()
+	s.mx.RUnlock()
 
-	for _, base := range s.bases {
-		if strings.EqualFold(base.Name, ibname) {
-			pass = base.UserPass
-			login = base.UserName
-			break
+	for _, source := range sources {
+		if login, pass = source.lookup(s, ibname); login != "" {
+			return login, pass
 		}
 	}
 
-	return
+	return "", ""
 }
 
 func (s *Settings) RAC_Path() string {
@@ -159,6 +250,13 @@ func (s *Settings) RAC_Pass() string {
 	return ""
 }
 
+func (s *Settings) RAC_Transport() string {
+	if s.RAC != nil && s.RAC.Transport != "" {
+		return s.RAC.Transport
+	}
+	return TransportRAC
+}
+
 func (s *Settings) GetMetricNamePrefix() string {
 	if s.LabelModes != nil {
 		return s.LabelModes.MetricNamePrefix
@@ -166,34 +264,78 @@ func (s *Settings) GetMetricNamePrefix() string {
 	return ""
 }
 
-func (s *Settings) GetRASHostPort() string {
+// GetClusters возвращает список RAS за которыми нужно наблюдать, если секция Clusters не заполнена используется RAS из секции RAC
+func (s *Settings) GetClusters() []*Cluster {
+	if len(s.Clusters) > 0 {
+		return s.Clusters
+	}
+
+	return []*Cluster{s.racCluster()}
+}
 
-	rasHostPort := s.RAC_Host() + ":"
-	rasPort := s.RAC_Port()
-	if rasPort == "" {
-		rasPort = "1545"
+func (s *Settings) racCluster() *Cluster {
+	return &Cluster{
+		Host:  s.RAC_Host(),
+		Port:  s.RAC_Port(),
+		Login: s.RAC_Login(),
+		Pass:  s.RAC_Pass(),
 	}
-	rasHostPort += rasPort
-	return rasHostPort
 }
 
+func (c *Cluster) RASHostPort() string {
+	port := c.Port
+	if port == "" {
+		port = "1545"
+	}
+
+	return c.Host + ":" + port
+}
+
+// String уникальный ключ описания кластера
+func (c *Cluster) String() string {
+	return c.RASHostPort() + "/" + c.Name
+}
+
+// DBCredentialsHook вызывается после каждого обращения к REST за учетными данными БД
+var DBCredentialsHook func(bases int, err error)
+
+// GetDBCredentials периодически обновляет учетные данные БД: запрашивает REST (если он используется)
+// и перезапускает внешние команды источников exec. Через cForce обновление можно запросить принудительно
 func (s *Settings) GetDBCredentials(ctx context.Context, cForce chan struct{}) {
-	if s.DBCredentials == nil || s.DBCredentials.URL == "" {
+	if !s.HasDBCredentials() {
 		return
 	}
 
+	rest := s.useREST()
 	get := func() {
-		s.mx.Lock()
-		defer s.mx.Unlock()
+		if !rest {
+			return
+		}
 
 		logger.DefaultLogger.With("URL", s.DBCredentials.URL).Info("обращаемся к REST")
 		tlsConf := &tls.Config{InsecureSkipVerify: s.DBCredentials.TLSSkipVerify}
+
+		// запрос выполняется без блокировки, что бы не задерживать получение учетных данных экспортерами
+		var bases []Bases
 		data, err := request(s.DBCredentials.URL, s.DBCredentials.User, s.DBCredentials.Password, tlsConf)
 		if err != nil {
-			logger.DefaultLogger.Error(errors.Wrap(err, "ошибка получения данных по БД"))
+			err = errors.Wrap(err, "ошибка получения данных по БД")
+		} else if err = json.Unmarshal(data, &bases); err != nil {
+			err = errors.Wrap(err, "не удалось десериализовать данные от REST")
 		}
-		if err := json.Unmarshal(data, &s.bases); err != nil {
-			logger.DefaultLogger.Error(errors.Wrap(err, "не удалось десериализовать данные от REST"))
+
+		s.mx.Lock()
+		if err == nil {
+			s.bases = bases
+		}
+		count := len(s.bases)
+		s.mx.Unlock()
+
+		if err != nil {
+			logger.DefaultLogger.Error(err)
+		}
+		if DBCredentialsHook != nil {
+			DBCredentialsHook(count, err)
 		}
 	}
 
@@ -206,10 +348,11 @@ f:
 	for {
 		select {
 		case <-cForce:
-			logger.DefaultLogger.Info("Принудительно запрашиваем список баз из REST")
+			logger.DefaultLogger.Info("Принудительно обновляем учетные данные БД")
 			get()
+			s.refreshCredentials()
 		case <-timer.C:
-			logger.DefaultLogger.Info("Планово запрашиваем список баз из REST")
+			logger.DefaultLogger.Info("Планово обновляем учетные данные БД")
 			get()
 		case <-ctx.Done():
 			break f
@@ -226,6 +369,99 @@ func (s *Settings) GetProperty(explorerName string, propertyName string, default
 	}
 }
 
+// GetPropertyStrings свойство экспортера в виде списка строк (допускается и одиночное значение)
+func (s *Settings) GetPropertyStrings(explorerName string, propertyName string) []string {
+	var result []string
+	for _, v := range toSlice(s.GetProperty(explorerName, propertyName, nil)) {
+		result = append(result, fmt.Sprint(v))
+	}
+
+	return result
+}
+
+// GetCustomExporters имена экспортеров, описанных в настройках командой rac (свойство Command)
+func (s *Settings) GetCustomExporters() []string {
+	var result []string
+	for _, item := range s.Exporters {
+		if _, ok := item.Property["Command"]; ok {
+			result = append(result, item.Name)
+		}
+	}
+
+	return result
+}
+
+// GetMetricKinds виды метрик экспортера. Берутся из свойства MetricKinds экспортера,
+// для session и sessions_data также из секции MetricKinds, по умолчанию Summary
+func (s *Settings) GetMetricKinds(explorerName string) []TypeMetricKind {
+	var kinds []TypeMetricKind
+	for _, v := range s.GetPropertyStrings(explorerName, "MetricKinds") {
+		kinds = append(kinds, TypeMetricKind(v))
+	}
+
+	if len(kinds) == 0 && s.MetricKinds != nil {
+		switch explorerName {
+		case "session":
+			kinds = s.MetricKinds.Session
+		case "sessions_data":
+			kinds = s.MetricKinds.SessionsData
+		}
+	}
+
+	if len(kinds) == 0 {
+		kinds = []TypeMetricKind{KindSummary}
+	}
+
+	return kinds
+}
+
+// GetHistogramBuckets границы бакетов классической гистограммы из свойства Buckets экспортера, nil если не заданы
+func (s *Settings) GetHistogramBuckets(explorerName string) []float64 {
+	var buckets []float64
+	for _, v := range toSlice(s.GetProperty(explorerName, "Buckets", nil)) {
+		if f, err := strconv.ParseFloat(fmt.Sprint(v), 64); err == nil {
+			buckets = append(buckets, f)
+		}
+	}
+
+	return buckets
+}
+
+// GetCollectInterval интервал фонового сбора экспортера: свойство Interval ("30s" или число секунд),
+// затем собственный интервал экспортера defaultValue (если он есть), затем общий CollectInterval
+func (s *Settings) GetCollectInterval(explorerName string, defaultValue time.Duration) time.Duration {
+	if defaultValue <= 0 {
+		defaultValue = s.CollectInterval
+	}
+
+	return s.GetPropertyDuration(explorerName, "Interval", defaultValue)
+}
+
+// GetPropertyDuration свойство экспортера в виде длительности: строка вида "30s" или число секунд
+func (s *Settings) GetPropertyDuration(explorerName string, propertyName string, defaultValue time.Duration) time.Duration {
+	switch v := s.GetProperty(explorerName, propertyName, nil).(type) {
+	case int:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+
+	return defaultValue
+}
+
+// GetPropertyInt свойство экспортера в виде целого числа
+func (s *Settings) GetPropertyInt(explorerName string, propertyName string, defaultValue int) int {
+	if v, err := strconv.Atoi(fmt.Sprint(s.GetProperty(explorerName, propertyName, defaultValue))); err == nil {
+		return v
+	}
+
+	return defaultValue
+}
+
 func (s *Settings) GetExporters() map[string]map[string]interface{} {
 	result := map[string]map[string]interface{}{}
 	for _, item := range s.Exporters {
@@ -235,6 +471,23 @@ func (s *Settings) GetExporters() map[string]map[string]interface{} {
 	return result
 }
 
+func toSlice(v interface{}) []interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		return val
+	case []string:
+		result := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			result = append(result, item)
+		}
+		return result
+	case nil:
+		return nil
+	default:
+		return []interface{}{val}
+	}
+}
+
 func request(url, log, pass string, tlsConf *tls.Config) ([]byte, error) {
 	cl := &http.Client{
 		Timeout: time.Minute,
@@ -6,14 +6,18 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/LazarenkoA/prometheus_1C_exporter/logger"
 	"github.com/agiledragon/gomonkey/v2"
+	"github.com/creasty/defaults"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 )
 
 func Test_GetDeactivateAndReset(t *testing.T) {
@@ -71,7 +75,11 @@ func Test_LoadSettings(t *testing.T) {
 	t.Run("pass", func(t *testing.T) {
 		s, err := LoadSettings("../examples_settings.yaml")
 		assert.NoError(t, err)
-		assert.NotNil(t, s)
+		if assert.NotNil(t, s) && assert.NotNil(t, s.RASBreaker) {
+			assert.Equal(t, 3, s.RASBreaker.Failures)
+			assert.Equal(t, time.Second*10, s.RASBreaker.Backoff)
+			assert.Zero(t, s.RACConcurrency)
+		}
 	})
 	t.Run("pass env", func(t *testing.T) {
 		assert.NoError(t, os.Setenv("RAC_LOGIN", "test"))
@@ -106,6 +114,214 @@ func Test_GetLogPass(t *testing.T) {
 	login, pass := s.GetLogPass("test")
 	assert.Equal(t, "user1", login)
 	assert.Equal(t, "1111", pass)
+
+	t.Run("reload", func(t *testing.T) {
+		// при перечитывании настроек новые настройки копируются в действующий объект, данные REST пишутся уже в него
+		news := &Settings{mx: new(sync.RWMutex), Credentials: []*CredentialProvider{{Type: CredentialsREST}}}
+		assert.NoError(t, news.initCredentials())
+
+		*s = *news
+		s.bases = []Bases{{Name: "test", UserName: "user3", UserPass: "3333"}}
+
+		login, pass := s.GetLogPass("test")
+		assert.Equal(t, "user3", login)
+		assert.Equal(t, "3333", pass)
+	})
+}
+
+func Test_CredentialsChain(t *testing.T) {
+	logger.InitLogger("", 4)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "bases.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("- Name: hrm\n  UserName: fileUser\n  UserPass: '111'\n"), os.ModePerm))
+	t.Setenv("IB_ACC_PROD_USER", "envUser")
+	t.Setenv("IB_ACC_PROD_PASSWORD", "222")
+
+	s := &Settings{
+		mx:    new(sync.RWMutex),
+		bases: []Bases{{Name: "rest", UserName: "restUser"}},
+	}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Credentials:
+  - Type: file
+    Path: `+file+`
+  - Type: env
+    User: IB_{base}_USER
+    Password: IB_{base}_PASSWORD
+  - Type: static
+    User: admin
+`), s))
+	assert.NoError(t, s.initCredentials())
+	assert.True(t, s.HasDBCredentials())
+	assert.False(t, s.useREST())
+
+	check := func(base, login, pass string) {
+		l, p := s.GetLogPass(base)
+		assert.Equal(t, login, l, base)
+		assert.Equal(t, pass, p, base)
+	}
+
+	check("HRM", "fileUser", "111")
+	check("acc-prod", "envUser", "222")
+	check("rest", "admin", "") // rest не указан в цепочке
+
+	t.Run("file changed", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(file, []byte(`[{"Name":"hrm","UserName":"newUser","UserPass":"333"}]`), os.ModePerm))
+		assert.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Minute)))
+
+		check("hrm", "newUser", "333")
+	})
+	t.Run("exec", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip()
+		}
+
+		s.Credentials = []*CredentialProvider{{Type: CredentialsREST}, {Type: CredentialsExec, Command: "echo", Args: []string{`[{"Name":"exec","UserName":"execUser","UserPass":"444"}]`}}}
+		assert.NoError(t, s.initCredentials())
+
+		check("rest", "restUser", "")
+		check("exec", "execUser", "444")
+		check("hrm", "", "")
+	})
+	t.Run("error", func(t *testing.T) {
+		s.Credentials = []*CredentialProvider{{Type: "vault"}}
+		assert.EqualError(t, s.initCredentials(), "Credentials[0]: неизвестный источник учетных данных \"vault\"")
+
+		s.Credentials = []*CredentialProvider{{Type: CredentialsFile}}
+		assert.EqualError(t, s.initCredentials(), "Credentials[0]: для источника \"file\" не задан Path")
+	})
+}
+
+func Test_SessionPolicies(t *testing.T) {
+	s := &Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+SessionPolicies:
+  DenyUsers: [Администратор]
+  AllowBases: [hrm, acc]
+  Rules:
+    - Name: memory
+      MemoryCurrent: 10GB
+      Polls: 3
+    - Name: designer
+      AppID: [Designer]
+      MaintenanceWindow: "22:00-06:00"
+`), s))
+	assert.NoError(t, defaults.Set(s))
+	assert.NoError(t, s.initSessionPolicies())
+
+	p := s.SessionPolicies
+	assert.Equal(t, PolicyDryRun, p.Mode)
+	assert.Equal(t, time.Minute, p.Interval)
+	assert.Equal(t, ByteSize(10<<30), p.Rules[0].MemoryCurrent)
+	assert.Equal(t, 3, p.Rules[0].Polls)
+	assert.Equal(t, 1, p.Rules[1].Polls)
+	assert.Equal(t, p.Message, p.Rules[1].GetMessage(p))
+
+	day := func(hour int) time.Time { return time.Date(2025, 3, 3, hour, 0, 0, 0, time.Local) }
+	assert.True(t, p.Rules[1].InMaintenance(day(23)))
+	assert.True(t, p.Rules[1].InMaintenance(day(5)))
+	assert.False(t, p.Rules[1].InMaintenance(day(6)))
+	assert.False(t, p.Rules[0].InMaintenance(day(23)))
+
+	assert.True(t, p.Allowed("HRM", "ib1", "Иванов"))
+	assert.False(t, p.Allowed("hrm", "ib1", "Администратор"))
+	assert.False(t, p.Allowed("buh", "ib2", "Иванов"))
+	assert.False(t, p.Allowed("", "ib1", "Иванов")) // имя базы не определено
+	p.DenyBases = []string{"ib1"}
+	assert.False(t, p.Allowed("hrm", "IB1", "Иванов"))
+
+	size, err := ParseByteSize("512mb")
+	assert.NoError(t, err)
+	assert.Equal(t, ByteSize(512<<20), size)
+
+	p.Rules = append(p.Rules, &SessionRule{Name: "empty"})
+	assert.EqualError(t, s.initSessionPolicies(), `SessionPolicies.Rules[2]: для правила "empty" не задано ни одного условия`)
+	p.Rules = p.Rules[:2]
+
+	// нативный клиент RAS сеансы не завершает
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+RAC:
+  Transport: native
+`), s))
+	p.Mode = PolicyTerminate
+	assert.EqualError(t, s.initSessionPolicies(), "SessionPolicies: для режима terminate с RAC.Transport: native нужен RAC.Path, сеансы завершаются утилитой rac")
+	p.Mode = PolicyDryRun
+	assert.NoError(t, s.initSessionPolicies())
+}
+
+func Test_GetClusters(t *testing.T) {
+	s := &Settings{}
+	s.RAC = &struct {
+		Path  string `yaml:"Path"`
+		Port  string `yaml:"Port"`
+		Host  string `yaml:"Host"`
+		Login string `yaml:"Login"`
+		Pass  string `yaml:"Pass"`
+
+		Transport string `yaml:"Transport"`
+	}{Host: "srv", Login: "admin"}
+
+	clusters := s.GetClusters()
+	if assert.Len(t, clusters, 1) {
+		assert.Equal(t, "srv:1545", clusters[0].RASHostPort())
+		assert.Equal(t, "admin", clusters[0].Login)
+	}
+
+	s.Clusters = []*Cluster{{Host: "srv1", Port: "1645"}, {Host: "srv2", Name: "main"}}
+	clusters = s.GetClusters()
+	if assert.Len(t, clusters, 2) {
+		assert.Equal(t, "srv1:1645/", clusters[0].String())
+		assert.Equal(t, "srv2:1545/main", clusters[1].String())
+	}
+}
+
+func Test_GetMetricKinds(t *testing.T) {
+	s := &Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+Exporters:
+  - Name: cpu
+    Property:
+      MetricKinds: [Summary, Histogram]
+      Buckets: [0.5, 1, 10]
+  - Name: disk
+MetricKinds:
+  SessionsData: [NativeHistogram]
+`), s))
+
+	assert.Equal(t, []TypeMetricKind{KindSummary, KindHistogram}, s.GetMetricKinds("cpu"))
+	assert.Equal(t, []TypeMetricKind{KindSummary}, s.GetMetricKinds("disk"))
+	assert.Equal(t, []TypeMetricKind{KindNativeHistogram}, s.GetMetricKinds("sessions_data"))
+	assert.Equal(t, []TypeMetricKind{KindSummary}, s.GetMetricKinds("session"))
+
+	assert.Equal(t, []float64{0.5, 1, 10}, s.GetHistogramBuckets("cpu"))
+	assert.Nil(t, s.GetHistogramBuckets("disk"))
+}
+
+func Test_GetCollectInterval(t *testing.T) {
+	s := &Settings{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+CollectInterval: 30s
+Exporters:
+  - Name: cpu
+    Property:
+      Interval: 10s
+  - Name: disk
+    Property:
+      Interval: 15
+      Timeout: 1m
+`), s))
+
+	assert.Equal(t, time.Second*10, s.GetCollectInterval("cpu", 0))
+	assert.Equal(t, time.Second*15, s.GetCollectInterval("disk", 0))
+	assert.Equal(t, time.Second*30, s.GetCollectInterval("session", 0))
+	assert.Equal(t, time.Second*5, s.GetCollectInterval("sessions_data", time.Second*5))
+
+	s.CollectInterval = 0
+	assert.Zero(t, s.GetCollectInterval("session", 0))
+
+	assert.Equal(t, time.Minute, s.GetPropertyDuration("disk", "Timeout", time.Second))
+	assert.Equal(t, time.Second, s.GetPropertyDuration("cpu", "Timeout", time.Second))
 }
 
 // go test -fuzz=Fuzz .\settings\...